@@ -3,10 +3,19 @@ package args
 
 import (
 	"errors"
+	"strings"
+
 	sdkArgs "github.com/newrelic/infra-integrations-sdk/v3/args"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 )
 
+// Values of the Output argument
+const (
+	OutputSDK  = "sdk"
+	OutputOTLP = "otlp"
+	OutputBoth = "both"
+)
+
 // ArgumentList struct that holds all PostgreSQL arguments
 type ArgumentList struct {
 	sdkArgs.DefaultArgumentList
@@ -22,17 +31,45 @@ type ArgumentList struct {
 	SSLCertLocation                      string `default:"" help:"Absolute path to PEM encoded client cert file"`
 	SSLKeyLocation                       string `default:"" help:"Absolute path to PEM encoded client key file"`
 	Timeout                              string `default:"10" help:"Maximum wait for connection, in seconds. Set 0 for no timeout"`
+	MaxOpenConnections                   int    `default:"5" help:"Maximum number of open connections to each database, shared by all collectors during a run. Set 0 for no limit"`
+	MaxIdleConnections                   int    `default:"2" help:"Maximum number of idle connections kept open to each database during a run"`
+	RunTimeout                           int    `default:"110" help:"Maximum duration of a run in seconds. Queries still running when it expires are cancelled. Set 0 for no limit"`
+	MetricsQueryTimeout                  int    `default:"30" help:"Maximum duration in seconds of each metrics query"`
+	MetricsQueryTimeouts                 string `default:"{}" help:"A JSON object of query timeouts in seconds by definition name, or by type (instance, database, lock, table, bloat, index, pgbouncer, custom), overriding METRICS_QUERY_TIMEOUT"`
+	Concurrency                          int    `default:"4" help:"Maximum number of databases and query monitoring collectors collected in parallel. Set 1 to collect serially"`
 	CustomMetricsQuery                   string `default:"" help:"A SQL query to collect custom metrics. Must have the columns metric_name, metric_type, and metric_value. Additional columns are added as attributes"`
 	CustomMetricsConfig                  string `default:"" help:"YAML configuration with one or more custom SQL queries to collect"`
+	MetricDefinitionsDir                 string `default:"" help:"Directory of YAML metric definitions overriding or extending the built-in ones by name"`
 	EnableSSL                            bool   `default:"false" help:"If true will use SSL encryption, false will not use encryption"`
 	TrustServerCertificate               bool   `default:"false" help:"If true server certificate is not verified for SSL. If false certificate will be verified against supplied certificate"`
 	Pgbouncer                            bool   `default:"false" help:"Collects metrics from PgBouncer instance. Assumes connection is through PgBouncer."`
 	CollectDbLockMetrics                 bool   `default:"false" help:"If true, enables collection of lock metrics for the specified database. (Note: requires that the 'tablefunc' extension is installed)"` //nolint: stylecheck
 	CollectBloatMetrics                  bool   `default:"true" help:"Enable collecting bloat metrics which can be performance intensive"`
 	ShowVersion                          bool   `default:"false" help:"Print build information and exit"`
+	Daemon                               bool   `default:"false" help:"Keep running and publish each group of metrics at its own interval instead of collecting once and exiting"`
+	DaemonMetricsInterval                int    `default:"15" help:"Interval in seconds between collections of instance, database, table, index, PgBouncer and custom metrics in daemon mode"`
+	DaemonBloatInterval                  int    `default:"600" help:"Interval in seconds between collections of bloat metrics in daemon mode"`
+	DaemonInventoryInterval              int    `default:"3600" help:"Interval in seconds between collections of inventory in daemon mode"`
+	DaemonQueryMonitoringInterval        int    `default:"60" help:"Interval in seconds between collections of query performance metrics in daemon mode"`
+	DaemonDiscoveryInterval              int    `default:"600" help:"Interval in seconds between discoveries of the server version, databases, tables, indexes and extensions in daemon and exporter modes"`
+	Exporter                             bool   `default:"false" help:"Serve the metrics in the Prometheus text exposition format. Runs along daemon mode when both are enabled"`
+	ExporterListenAddress                string `default:":9187" help:"Address the Prometheus exporter listens on"`
+	ExporterMetricsPath                  string `default:"/metrics" help:"HTTP path of the Prometheus metrics"`
+	Output                               string `default:"sdk" help:"Destination of the metrics: sdk to publish them with the integration payload, otlp to export them as OpenTelemetry metrics, or both"`
+	OtlpEndpoint                         string `default:"" help:"URL of the OTLP/HTTP metrics endpoint, e.g. http://localhost:4318/v1/metrics"`
+	OtlpHeaders                          string `default:"{}" help:"A JSON object of HTTP headers sent with each OTLP export, e.g. for authentication"`
+	OtlpFile                             string `default:"" help:"Path of a file the OTLP metrics are appended to as JSON lines, e.g. for testing"`
 	EnableQueryMonitoring                bool   `default:"false" help:"Enable collection of detailed query performance metrics."`
 	QueryMonitoringResponseTimeThreshold int    `default:"500" help:"Threshold in milliseconds for query response time. If response time for the individual query exceeds this threshold, the individual query is reported in metrics"`
 	QueryMonitoringCountThreshold        int    `default:"20" help:"The number of records for each query performance metrics"`
+	QueryMonitoringExplainAnalyze        bool   `default:"false" help:"If true, execution plans of SELECT statements are captured with EXPLAIN ANALYZE inside a read-only transaction that is always rolled back"`
+	QueryMonitoringExplainAnalyzeTimeout int    `default:"5000" help:"Statement timeout in milliseconds applied to each EXPLAIN ANALYZE"`
+	QueryMonitoringExplainDenylist       string `default:"[]" help:"A JSON array of regular expressions. Queries matching any of them are never run with EXPLAIN ANALYZE"`
+	QueryMonitoringExplainRateLimit      int    `default:"5" help:"Maximum number of EXPLAIN statements per second sent to the server"`
+	QueryMonitoringExplainInterval       int    `default:"600" help:"Minimum interval in seconds before the execution plan of the same query is captured again, unless its statistics change significantly. Set 0 to capture it on every run"`
+	QueryMonitoringLongRunningThreshold  int    `default:"300" help:"Threshold in seconds for the running time of a statement or transaction. Sessions exceeding it are reported as long running sessions"`
+	QueryMonitoringFilterRules           string `default:"{}" help:"A JSON object with include and exclude rules and query ID allow and deny lists that select the queries reported by query monitoring"`
+	QueryMonitoringErrorLogPath          string `default:"" help:"Path of the local PostgreSQL log file. When pg_stat_monitor is not enabled, query errors are counted from its ERROR lines"`
 }
 
 // Validate validates PostgreSQl arguments
@@ -43,6 +80,49 @@ func (al ArgumentList) Validate() error {
 	if err := al.validateSSL(); err != nil {
 		return err
 	}
+	if err := al.validateLongRunning(); err != nil {
+		return err
+	}
+	if err := al.validateOutput(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// OutputsSDK returns whether metrics are published with the integration payload
+func (al ArgumentList) OutputsSDK() bool {
+	return al.Output == "" || al.Output == OutputSDK || al.Output == OutputBoth
+}
+
+// OutputsOTLP returns whether metrics are exported as OpenTelemetry metrics
+func (al ArgumentList) OutputsOTLP() bool {
+	return al.Output == OutputOTLP || al.Output == OutputBoth
+}
+
+func (al ArgumentList) validateOutput() error {
+	switch al.Output {
+	case "", OutputSDK, OutputOTLP, OutputBoth:
+	default:
+		return errors.New("invalid configuration: output must be sdk, otlp or both")
+	}
+	if al.OutputsOTLP() && al.OtlpEndpoint == "" && al.OtlpFile == "" {
+		return errors.New("invalid configuration: must specify an OTLP endpoint or file when using the otlp output")
+	}
+	return nil
+}
+
+func (al ArgumentList) validateLongRunning() error {
+	if !al.Daemon && !al.Exporter {
+		return nil
+	}
+	if al.Exporter && !strings.HasPrefix(al.ExporterMetricsPath, "/") {
+		return errors.New("invalid configuration: exporter metrics path must start with /")
+	}
+	for _, interval := range []int{al.DaemonMetricsInterval, al.DaemonBloatInterval, al.DaemonInventoryInterval, al.DaemonQueryMonitoringInterval, al.DaemonDiscoveryInterval} {
+		if interval <= 0 {
+			return errors.New("invalid configuration: daemon intervals must be greater than 0 seconds")
+		}
+	}
 	return nil
 }
 
@@ -86,6 +86,62 @@ func TestValidate(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"Daemon with intervals",
+			&ArgumentList{
+				Username:                      "user",
+				Password:                      "password",
+				Daemon:                        true,
+				DaemonMetricsInterval:         15,
+				DaemonBloatInterval:           600,
+				DaemonInventoryInterval:       3600,
+				DaemonQueryMonitoringInterval: 60,
+				DaemonDiscoveryInterval:       600,
+			},
+			false,
+		},
+		{
+			"Daemon with zero interval",
+			&ArgumentList{
+				Username:                      "user",
+				Password:                      "password",
+				Daemon:                        true,
+				DaemonMetricsInterval:         0,
+				DaemonBloatInterval:           600,
+				DaemonInventoryInterval:       3600,
+				DaemonQueryMonitoringInterval: 60,
+				DaemonDiscoveryInterval:       600,
+			},
+			true,
+		},
+		{
+			"OTLP output with endpoint",
+			&ArgumentList{
+				Username:     "user",
+				Password:     "password",
+				Output:       OutputOTLP,
+				OtlpEndpoint: "http://localhost:4318/v1/metrics",
+			},
+			false,
+		},
+		{
+			"OTLP output without endpoint or file",
+			&ArgumentList{
+				Username: "user",
+				Password: "password",
+				Output:   OutputBoth,
+			},
+			true,
+		},
+		{
+			"Unknown output",
+			&ArgumentList{
+				Username: "user",
+				Password: "password",
+				Output:   "prometheus",
+			},
+			true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,69 @@
+// Package capabilities describes the catalog views, columns and functions collectors require. Forks and managed
+// services report a version number but may lack or rename catalog objects, so collectors declare what they use
+// and are skipped on servers that miss it.
+package capabilities
+
+import (
+	"context"
+	"fmt"
+)
+
+// Capability is a catalog object of the server: a view or table, a column of one, or a function
+type Capability struct {
+	Relation string `yaml:"relation"`
+	Column   string `yaml:"column"`
+	Function string `yaml:"function"`
+}
+
+// Relation is the capability of having the view or table name
+func Relation(name string) Capability {
+	return Capability{Relation: name}
+}
+
+// Column is the capability of having column in the view or table relation
+func Column(relation, column string) Capability {
+	return Capability{Relation: relation, Column: column}
+}
+
+// Function is the capability of having the function name
+func Function(name string) Capability {
+	return Capability{Function: name}
+}
+
+func (c Capability) String() string {
+	switch {
+	case c.Function != "":
+		return "function " + c.Function
+	case c.Column != "":
+		return "column " + c.Relation + "." + c.Column
+	default:
+		return "relation " + c.Relation
+	}
+}
+
+// Validate checks the capability names either a relation, with an optional column, or a function
+func (c Capability) Validate() error {
+	if (c.Relation == "") == (c.Function == "") {
+		return fmt.Errorf("a capability needs either a relation or a function")
+	}
+	if c.Function != "" && c.Column != "" {
+		return fmt.Errorf("function %s cannot have a column", c.Function)
+	}
+	return nil
+}
+
+// Prober checks whether a server has a capability
+type Prober interface {
+	Has(ctx context.Context, c Capability) bool
+}
+
+// Missing returns the first capability of required that prober lacks, false when it has all of them. Without a
+// prober every capability is missing.
+func Missing(ctx context.Context, prober Prober, required []Capability) (Capability, bool) {
+	for _, c := range required {
+		if prober == nil || !prober.Has(ctx, c) {
+			return c, true
+		}
+	}
+	return Capability{}, false
+}
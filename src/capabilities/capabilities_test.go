@@ -0,0 +1,48 @@
+package capabilities
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testProber map[Capability]bool
+
+func (p testProber) Has(_ context.Context, c Capability) bool {
+	return p[c]
+}
+
+func TestCapability_String(t *testing.T) {
+	assert.Equal(t, "relation pg_stat_io", Relation("pg_stat_io").String())
+	assert.Equal(t, "column pg_stat_io.evictions", Column("pg_stat_io", "evictions").String())
+	assert.Equal(t, "function get_histogram_timings", Function("get_histogram_timings").String())
+}
+
+func TestCapability_Validate(t *testing.T) {
+	assert.NoError(t, Relation("pg_stat_io").Validate())
+	assert.NoError(t, Column("pg_stat_io", "evictions").Validate())
+	assert.NoError(t, Function("crosstab").Validate())
+	assert.Error(t, Capability{}.Validate())
+	assert.Error(t, Capability{Column: "evictions"}.Validate())
+	assert.Error(t, Capability{Relation: "pg_proc", Function: "crosstab"}.Validate())
+	assert.Error(t, Capability{Function: "crosstab", Column: "x"}.Validate())
+}
+
+func TestMissing(t *testing.T) {
+	required := []Capability{Relation("pg_stat_io"), Function("crosstab")}
+
+	_, missing := Missing(context.Background(), testProber{Relation("pg_stat_io"): true, Function("crosstab"): true}, required)
+	assert.False(t, missing)
+
+	capability, missing := Missing(context.Background(), testProber{Relation("pg_stat_io"): true}, required)
+	assert.True(t, missing)
+	assert.Equal(t, Function("crosstab"), capability)
+
+	capability, missing = Missing(context.Background(), nil, required)
+	assert.True(t, missing, "capabilities are missing without a prober")
+	assert.Equal(t, Relation("pg_stat_io"), capability)
+
+	_, missing = Missing(context.Background(), nil, nil)
+	assert.False(t, missing)
+}
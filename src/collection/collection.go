@@ -2,6 +2,7 @@
 package collection
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -38,7 +39,7 @@ type ignoreList map[string]struct{}
 // objects to be collected. If collection_list is a JSON array, it collects every object in
 // each of the databases listed in the array. If it is a hash, it collects only the objects
 // listed
-func BuildCollectionList(al args.ArgumentList, ci connection.Info) (DatabaseList, error) {
+func BuildCollectionList(ctx context.Context, al args.ArgumentList, ci connection.Info) (DatabaseList, error) {
 	var dbList DatabaseList
 	var dbNames []string
 	var err error
@@ -55,7 +56,7 @@ func BuildCollectionList(al args.ArgumentList, ci connection.Info) (DatabaseList
 
 	switch {
 	case strings.ToLower(al.CollectionList) == "all":
-		if dbNames, err = getAllDatabaseNames(ci); err != nil {
+		if dbNames, err = getAllDatabaseNames(ctx, ci); err != nil {
 			return nil, fmt.Errorf("failed to get all databases names: %w", err)
 		}
 
@@ -70,7 +71,7 @@ func BuildCollectionList(al args.ArgumentList, ci connection.Info) (DatabaseList
 	}
 
 	if len(dbNames) != 0 {
-		if dbList, err = buildCollectionListFromDatabaseNames(dbNames, ignoreDBList, ignoreTableList, ci); err != nil {
+		if dbList, err = buildCollectionListFromDatabaseNames(ctx, dbNames, ignoreDBList, ignoreTableList, ci); err != nil {
 			return nil, err
 		}
 	}
@@ -97,7 +98,7 @@ func parseIgnoreList(list string) (ignoreList, error) {
 	return ignoreMap, nil
 }
 
-func getAllDatabaseNames(ci connection.Info) ([]string, error) {
+func getAllDatabaseNames(ctx context.Context, ci connection.Info) ([]string, error) {
 	con, err := ci.NewConnection(ci.DatabaseName())
 	if err != nil {
 		return nil, err
@@ -107,7 +108,7 @@ func getAllDatabaseNames(ci connection.Info) ([]string, error) {
 	var dataModel []struct {
 		DatabaseName sql.NullString `db:"datname"`
 	}
-	err = con.Query(&dataModel, allDBQuery)
+	err = con.QueryContext(ctx, &dataModel, allDBQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +123,7 @@ func getAllDatabaseNames(ci connection.Info) ([]string, error) {
 	return databaseNames, nil
 }
 
-func buildCollectionListFromDatabaseNames(dbnames []string, ignoreDBList, ignoreTableList ignoreList, ci connection.Info) (DatabaseList, error) {
+func buildCollectionListFromDatabaseNames(ctx context.Context, dbnames []string, ignoreDBList, ignoreTableList ignoreList, ci connection.Info) (DatabaseList, error) {
 	databaseList := DatabaseList{}
 	for _, db := range dbnames {
 		if _, ok := ignoreDBList[db]; ok {
@@ -136,7 +137,7 @@ func buildCollectionListFromDatabaseNames(dbnames []string, ignoreDBList, ignore
 		}
 		defer con.Close()
 
-		schemaList, err := buildSchemaListForDatabase(con, ignoreTableList)
+		schemaList, err := buildSchemaListForDatabase(ctx, con, ignoreTableList)
 		if err != nil {
 			log.Error("Failed to build schema list for database '%s': %s", db, err)
 			continue
@@ -151,7 +152,7 @@ func buildCollectionListFromDatabaseNames(dbnames []string, ignoreDBList, ignore
 	return databaseList, nil
 }
 
-func buildSchemaListForDatabase(con *connection.PGSQLConnection, ignoreTableList ignoreList) (SchemaList, error) {
+func buildSchemaListForDatabase(ctx context.Context, con *connection.PGSQLConnection, ignoreTableList ignoreList) (SchemaList, error) {
 	schemaList := make(SchemaList)
 
 	var dataModel []struct {
@@ -159,7 +160,7 @@ func buildSchemaListForDatabase(con *connection.PGSQLConnection, ignoreTableList
 		TableName  sql.NullString `db:"table_name"`
 		IndexName  sql.NullString `db:"index_name"`
 	}
-	err := con.Query(&dataModel, dbSchemaQuery)
+	err := con.QueryContext(ctx, &dataModel, dbSchemaQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,116 @@
+package connection
+
+import (
+	"context"
+	"sync"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nri-postgresql/src/capabilities"
+)
+
+const (
+	relationQuery = `
+    SELECT -- CAPABILITY_RELATION
+           count(*) AS found
+      FROM pg_class
+     WHERE oid = to_regclass($1);`
+
+	columnQuery = `
+    SELECT -- CAPABILITY_COLUMN
+           count(*) AS found
+      FROM pg_attribute
+     WHERE attrelid = to_regclass($1)
+       AND attname = $2
+       AND NOT attisdropped;`
+
+	functionQuery = `
+    SELECT -- CAPABILITY_FUNCTION
+           count(*) AS found
+      FROM pg_proc
+     WHERE proname = $1;`
+)
+
+// capabilityCache keeps the capabilities checked on a database until it is reset, so each one is queried once.
+// Concurrent callers of a capability wait for the check in progress instead of querying it again.
+type capabilityCache struct {
+	mu     sync.Mutex
+	checks map[capabilities.Capability]*capabilityCheck
+}
+
+// capabilityCheck is the check of one capability, with its result once done is closed
+type capabilityCheck struct {
+	done  chan struct{}
+	found bool
+	err   error
+}
+
+func newCapabilityCache() *capabilityCache {
+	return &capabilityCache{checks: make(map[capabilities.Capability]*capabilityCheck)}
+}
+
+// has returns the cached result for c, running check on a miss without holding the lock. Failed checks are not
+// cached. It returns false when ctx is done before the check in progress finishes.
+func (c *capabilityCache) has(ctx context.Context, capability capabilities.Capability, check func(context.Context, capabilities.Capability) (bool, error)) bool {
+	c.mu.Lock()
+	result, checked := c.checks[capability]
+	if !checked {
+		result = &capabilityCheck{done: make(chan struct{})}
+		c.checks[capability] = result
+	}
+	c.mu.Unlock()
+
+	if !checked {
+		result.found, result.err = check(ctx, capability)
+		if result.err != nil {
+			log.Warn("Failure checking %s: %+v", capability, result.err)
+			c.forget(capability, result)
+		}
+		close(result.done)
+		return result.found && result.err == nil
+	}
+	select {
+	case <-result.done:
+		return result.found && result.err == nil
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// forget removes the failed check of capability, unless the cache was reset since it started
+func (c *capabilityCache) forget(capability capabilities.Capability, result *capabilityCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checks[capability] == result {
+		delete(c.checks, capability)
+	}
+}
+
+func (c *capabilityCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = make(map[capabilities.Capability]*capabilityCheck)
+}
+
+// Has checks to see if the current database has the catalog view, column
+// or function of the capability. Pooled connections check each capability
+// once, until the capabilities are reset through Info.
+func (p PGSQLConnection) Has(ctx context.Context, capability capabilities.Capability) bool {
+	if p.capabilities == nil {
+		return newCapabilityCache().has(ctx, capability, p.checkCapability)
+	}
+	return p.capabilities.has(ctx, capability, p.checkCapability)
+}
+
+func (p PGSQLConnection) checkCapability(ctx context.Context, capability capabilities.Capability) (bool, error) {
+	var found int
+	var err error
+	switch {
+	case capability.Function != "":
+		err = p.connection.GetContext(ctx, &found, functionQuery, capability.Function)
+	case capability.Column != "":
+		err = p.connection.GetContext(ctx, &found, columnQuery, capability.Relation, capability.Column)
+	default:
+		err = p.connection.GetContext(ctx, &found, relationQuery, capability.Relation)
+	}
+	return found > 0, err
+}
@@ -0,0 +1,86 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nri-postgresql/src/capabilities"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func Test_PGSQLConnection_Has(t *testing.T) {
+	conn, mock := CreateMockSQL(t)
+
+	mock.ExpectQuery(".*CAPABILITY_RELATION.*").WithArgs("pg_stat_io").
+		WillReturnRows(sqlmock.NewRows([]string{"found"}).AddRow(1))
+	mock.ExpectQuery(".*CAPABILITY_COLUMN.*").WithArgs("pg_stat_activity", "query_id").
+		WillReturnRows(sqlmock.NewRows([]string{"found"}).AddRow(1))
+	mock.ExpectQuery(".*CAPABILITY_COLUMN.*").WithArgs("pg_stat_activity", "missing").
+		WillReturnRows(sqlmock.NewRows([]string{"found"}).AddRow(0))
+	mock.ExpectQuery(".*CAPABILITY_FUNCTION.*").WithArgs("get_histogram_timings").
+		WillReturnRows(sqlmock.NewRows([]string{"found"}).AddRow(0))
+	mock.ExpectQuery(".*CAPABILITY_COLUMN.*").WillReturnError(errors.New("query failed"))
+
+	assert.True(t, conn.Has(context.Background(), capabilities.Relation("pg_stat_io")))
+	assert.True(t, conn.Has(context.Background(), capabilities.Column("pg_stat_activity", "query_id")))
+	assert.False(t, conn.Has(context.Background(), capabilities.Column("pg_stat_activity", "missing")))
+	assert.False(t, conn.Has(context.Background(), capabilities.Function("get_histogram_timings")))
+	assert.False(t, conn.Has(context.Background(), capabilities.Column("pg_stat_activity", "other")))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_PGSQLConnection_Has_Cached(t *testing.T) {
+	conn, mock := CreateMockSQL(t)
+	conn.capabilities = newCapabilityCache()
+	io := capabilities.Relation("pg_stat_io")
+
+	mock.ExpectQuery(".*CAPABILITY_RELATION.*").WillReturnError(errors.New("query failed"))
+	mock.ExpectQuery(".*CAPABILITY_RELATION.*").WithArgs("pg_stat_io").
+		WillReturnRows(sqlmock.NewRows([]string{"found"}).AddRow(0))
+	mock.ExpectQuery(".*CAPABILITY_RELATION.*").WithArgs("pg_stat_io").
+		WillReturnRows(sqlmock.NewRows([]string{"found"}).AddRow(1))
+
+	assert.False(t, conn.Has(context.Background(), io), "a failed check is not cached")
+	assert.False(t, conn.Has(context.Background(), io))
+	assert.False(t, conn.Has(context.Background(), io), "the result is cached")
+
+	conn.capabilities.reset()
+	assert.True(t, conn.Has(context.Background(), io), "the capability is checked again after a reset")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_capabilityCache_CheckedOnce(t *testing.T) {
+	cache := newCapabilityCache()
+	io := capabilities.Relation("pg_stat_io")
+	release := make(chan struct{})
+	var checks atomic.Int32
+	check := func(context.Context, capabilities.Capability) (bool, error) {
+		checks.Add(1)
+		<-release
+		return true, nil
+	}
+
+	results := make(chan bool, 3)
+	for range 3 {
+		go func() { results <- cache.has(context.Background(), io, check) }()
+	}
+	assert.Eventually(t, func() bool { return checks.Load() == 1 }, time.Second, time.Millisecond)
+
+	// the lock is not held during the check, so other capabilities are not blocked by it
+	other := func(context.Context, capabilities.Capability) (bool, error) { return false, nil }
+	assert.False(t, cache.has(context.Background(), capabilities.Relation("pg_stat_wal"), other))
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, cache.has(canceled, io, check), "waiting for the check stops when the context is done")
+
+	close(release)
+	for range 3 {
+		assert.True(t, <-results)
+	}
+	assert.Equal(t, int32(1), checks.Load(), "concurrent callers share one check")
+}
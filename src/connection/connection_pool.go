@@ -0,0 +1,78 @@
+package connection
+
+import (
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+// poolManager keeps one bounded pool per database for the whole run, so every collector shares the
+// connections of a database instead of opening its own
+type poolManager struct {
+	mu     sync.Mutex
+	pools  map[string]*sqlx.DB
+	caches map[string]*capabilityCache
+}
+
+func newPoolManager() *poolManager {
+	return &poolManager{pools: make(map[string]*sqlx.DB), caches: make(map[string]*capabilityCache)}
+}
+
+// pool returns the pool of database, creating it with open the first time it is requested
+func (m *poolManager) pool(database string, open func() (*sqlx.DB, error)) (*sqlx.DB, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if db, ok := m.pools[database]; ok {
+		return db, nil
+	}
+	db, err := open()
+	if err != nil {
+		return nil, err
+	}
+	m.pools[database] = db
+	return db, nil
+}
+
+// capabilities returns the capability cache of database, shared by the connections of its pool
+func (m *poolManager) capabilities(database string) *capabilityCache {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cache, ok := m.caches[database]
+	if !ok {
+		cache = newCapabilityCache()
+		m.caches[database] = cache
+	}
+	return cache
+}
+
+func (m *poolManager) resetCapabilities() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, cache := range m.caches {
+		cache.reset()
+	}
+}
+
+// openConnections returns the connections open in every pool, in use or idle
+func (m *poolManager) openConnections() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	open := 0
+	for _, db := range m.pools {
+		open += db.Stats().OpenConnections
+	}
+	return open
+}
+
+func (m *poolManager) closeAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for database, db := range m.pools {
+		if err := db.Close(); err != nil {
+			log.Warn("Unable to close PostgreSQL connection pool of database %s: %s", database, err.Error())
+		}
+		delete(m.pools, database)
+	}
+	m.caches = make(map[string]*capabilityCache)
+}
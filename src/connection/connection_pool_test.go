@@ -0,0 +1,57 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_connectionInfo_NewConnection_SharesPoolPerDatabase(t *testing.T) {
+	ci := DefaultConnectionInfo(&args.ArgumentList{Hostname: "localhost", Port: "5432", MaxOpenConnections: 3, MaxIdleConnections: 1})
+	defer ci.Close()
+
+	first, err := ci.NewConnection("db1")
+	assert.NoError(t, err)
+	second, err := ci.NewConnection("db1")
+	assert.NoError(t, err)
+	other, err := ci.NewConnection("db2")
+	assert.NoError(t, err)
+
+	assert.Same(t, first.connection, second.connection)
+	assert.NotSame(t, first.connection, other.connection)
+	assert.Equal(t, 3, first.connection.Stats().MaxOpenConnections)
+
+	// Closing a pooled connection leaves the pool open for the other collectors
+	first.Close()
+	third, err := ci.NewConnection("db1")
+	assert.NoError(t, err)
+	assert.Same(t, second.connection, third.connection)
+}
+
+func Test_connectionInfo_Close(t *testing.T) {
+	ci := DefaultConnectionInfo(&args.ArgumentList{Hostname: "localhost", Port: "5432"})
+	first, err := ci.NewConnection("db1")
+	assert.NoError(t, err)
+
+	ci.Close()
+	second, err := ci.NewConnection("db1")
+	assert.NoError(t, err)
+	assert.NotSame(t, first.connection, second.connection, "a new pool is opened after the pools are closed")
+	ci.Close()
+}
+
+func Test_connectionInfo_NewConnection_SharesCapabilitiesPerDatabase(t *testing.T) {
+	ci := DefaultConnectionInfo(&args.ArgumentList{Hostname: "localhost", Port: "5432"})
+	defer ci.Close()
+
+	first, err := ci.NewConnection("db1")
+	assert.NoError(t, err)
+	second, err := ci.NewConnection("db1")
+	assert.NoError(t, err)
+	other, err := ci.NewConnection("db2")
+	assert.NoError(t, err)
+
+	assert.Same(t, first.capabilities, second.capabilities)
+	assert.NotSame(t, first.capabilities, other.capabilities)
+}
@@ -3,6 +3,7 @@ package connection
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/url"
 
@@ -13,6 +14,9 @@ import (
 	"github.com/newrelic/nri-postgresql/src/args"
 )
 
+// ApplicationName identifies the sessions of the integration in pg_stat_activity
+const ApplicationName = "nri-postgresql"
+
 const (
 	extensionsQuery = `
     SELECT -- EXTENSIONS_LIST
@@ -25,6 +29,10 @@ const (
 // PGSQLConnection represents a wrapper around a PostgreSQL connection
 type PGSQLConnection struct {
 	connection *sqlx.DB
+	// pooled connections belong to the pool of their database and are closed with it
+	pooled bool
+	// capabilities caches the capabilities checked on the database, nil to check them on every call
+	capabilities *capabilityCache
 }
 
 // Info holds all the information needed from the user to create a new connection
@@ -32,6 +40,9 @@ type Info interface {
 	NewConnection(database string) (*PGSQLConnection, error)
 	HostPort() (string, string)
 	DatabaseName() string
+	OpenConnections() int
+	ResetCapabilities()
+	Close()
 }
 
 type connectionInfo struct {
@@ -46,6 +57,9 @@ type connectionInfo struct {
 	SSLRootCertLocation    string
 	SSLKeyLocation         string
 	TrustServerCertificate bool
+	MaxOpenConnections     int
+	MaxIdleConnections     int
+	pools                  *poolManager
 }
 
 // DefaultConnectionInfo takes an argument list and constructs a default connection out of it
@@ -62,21 +76,49 @@ func DefaultConnectionInfo(al *args.ArgumentList) Info {
 		SSLRootCertLocation:    al.SSLRootCertLocation,
 		SSLKeyLocation:         al.SSLKeyLocation,
 		TrustServerCertificate: al.TrustServerCertificate,
+		MaxOpenConnections:     al.MaxOpenConnections,
+		MaxIdleConnections:     al.MaxIdleConnections,
+		pools:                  newPoolManager(),
 	}
 }
 
-// NewConnection creates a new PGSQLConnection from args
+// NewConnection returns a PGSQLConnection backed by the shared pool of the database, opening the pool on first use
 func (ci *connectionInfo) NewConnection(database string) (*PGSQLConnection, error) {
-	db, err := sqlx.Open("postgres", createConnectionURL(ci, database))
+	db, err := ci.pools.pool(database, func() (*sqlx.DB, error) {
+		db, err := sqlx.Open("postgres", createConnectionURL(ci, database))
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(ci.MaxOpenConnections)
+		db.SetMaxIdleConns(ci.MaxIdleConnections)
+		return db, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	return &PGSQLConnection{
-		connection: db,
+		connection:   db,
+		pooled:       true,
+		capabilities: ci.pools.capabilities(database),
 	}, nil
 }
 
+// OpenConnections returns the connections open to the server in the pools of every database
+func (ci *connectionInfo) OpenConnections() int {
+	return ci.pools.openConnections()
+}
+
+// ResetCapabilities forgets the capabilities checked on every database, so they are checked again
+func (ci *connectionInfo) ResetCapabilities() {
+	ci.pools.resetCapabilities()
+}
+
+// Close closes the connection pools of every database
+func (ci *connectionInfo) Close() {
+	ci.pools.closeAll()
+}
+
 func (ci *connectionInfo) HostPort() (string, string) {
 	return ci.Host, ci.Port
 }
@@ -86,8 +128,12 @@ func (ci *connectionInfo) DatabaseName() string {
 }
 
 // Close closes the PosgreSQL connection. If an error occurs
-// it is logged as a warning.
+// it is logged as a warning. Pooled connections stay open until
+// the pools are closed through Info.
 func (p PGSQLConnection) Close() {
+	if p.pooled {
+		return
+	}
 	if err := p.connection.Close(); err != nil {
 		log.Warn("Unable to close PostgreSQL Connection: %s", err.Error())
 	}
@@ -113,6 +159,16 @@ func (p PGSQLConnection) QueryxContext(ctx context.Context, query string) (*sqlx
 	return p.connection.QueryxContext(ctx, query)
 }
 
+// BeginTxx starts a transaction with the given options
+func (p PGSQLConnection) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	return p.connection.BeginTxx(ctx, opts)
+}
+
+// Connx returns a single connection from the pool, for statements that depend on session state
+func (p PGSQLConnection) Connx(ctx context.Context) (*sqlx.Conn, error) {
+	return p.connection.Connx(ctx)
+}
+
 type extensions map[string]map[string]bool
 
 type extensionRow struct {
@@ -120,9 +176,9 @@ type extensionRow struct {
 	ExtensionName string `db:"extension"`
 }
 
-func (p PGSQLConnection) getExtensions() (extensions, error) {
+func (p PGSQLConnection) getExtensions(ctx context.Context) (extensions, error) {
 	var extensionRows []*extensionRow
-	if err := p.Query(&extensionRows, extensionsQuery); err != nil {
+	if err := p.QueryContext(ctx, &extensionRows, extensionsQuery); err != nil {
 		log.Warn("Failure acquiring list of extensions: %+v", err)
 		return nil, err
 	}
@@ -143,8 +199,8 @@ func (p PGSQLConnection) getExtensions() (extensions, error) {
 
 // HaveExtensionInSchema checks to see if the given Extension is
 // installed on the current database in the given schema
-func (p PGSQLConnection) HaveExtensionInSchema(extensionName, schemaName string) bool {
-	extensions, err := p.getExtensions()
+func (p PGSQLConnection) HaveExtensionInSchema(ctx context.Context, extensionName, schemaName string) bool {
+	extensions, err := p.getExtensions(ctx)
 	if err != nil {
 		return false
 	}
@@ -172,6 +228,7 @@ func createConnectionURL(ci *connectionInfo, database string) string {
 
 	query := url.Values{}
 	query.Add("connect_timeout", ci.Timeout)
+	query.Add("application_name", ApplicationName)
 
 	// SSL settings
 	if ci.EnableSSL {
@@ -43,3 +43,14 @@ func (mi *MockInfo) NewConnection(database string) (*PGSQLConnection, error) {
 	args := mi.Called(database)
 	return args.Get(0).(*PGSQLConnection), args.Error(1)
 }
+
+// OpenConnections returns 0, mock connections are not pooled
+func (mi *MockInfo) OpenConnections() int {
+	return 0
+}
+
+// ResetCapabilities does nothing, mock connections check capabilities on every call
+func (mi *MockInfo) ResetCapabilities() {}
+
+// Close does nothing, mock connections are closed by the test
+func (mi *MockInfo) Close() {}
@@ -0,0 +1,254 @@
+// Package daemon keeps the integration running and collects each group of metrics at its own interval
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/collection"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/inventory"
+	"github.com/newrelic/nri-postgresql/src/metrics"
+	"github.com/newrelic/nri-postgresql/src/otlp"
+	queryperformancemonitoring "github.com/newrelic/nri-postgresql/src/query-performance-monitoring"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
+)
+
+// group is a set of collectors run together at the same interval
+type group struct {
+	name     string
+	interval time.Duration
+	collect  func(ctx context.Context)
+	next     time.Time
+}
+
+// daemon keeps the connections and the state discovered from the server between collections
+type daemon struct {
+	args             args.ArgumentList
+	pgIntegration    *integration.Integration
+	ci               connection.Info
+	version          *semver.Version
+	databaseList     collection.DatabaseList
+	queryPerformance *queryperformancemonitoring.QueryPerformance
+	otlpExporter     *otlp.Exporter
+}
+
+// Run collects every group when it is due and publishes the payload of each cycle to stdout, until ctx is done.
+// Metrics are also exported by otlpExporter when it is not nil.
+func Run(ctx context.Context, al args.ArgumentList, pgIntegration *integration.Integration, ci connection.Info, otlpExporter *otlp.Exporter) {
+	d := &daemon{args: al, pgIntegration: pgIntegration, ci: ci, otlpExporter: otlpExporter}
+	groups := d.groups()
+	for {
+		d.runDue(ctx, groups, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(nextDue(groups))):
+		}
+	}
+}
+
+// groups returns the groups enabled by the arguments. Discovery comes first, so the other groups use its results.
+func (d *daemon) groups() []*group {
+	groups := []*group{{name: "discovery", interval: seconds(d.args.DaemonDiscoveryInterval), collect: d.discover}}
+	if d.args.HasMetrics() {
+		groups = append(groups, &group{name: "metrics", interval: seconds(d.args.DaemonMetricsInterval), collect: d.collectMetrics})
+		if d.args.CollectBloatMetrics {
+			groups = append(groups, &group{name: "bloat", interval: seconds(d.args.DaemonBloatInterval), collect: d.collectBloat})
+		}
+	}
+	if d.args.HasInventory() {
+		groups = append(groups, &group{name: "inventory", interval: seconds(d.args.DaemonInventoryInterval), collect: d.collectInventory})
+	}
+	if d.args.EnableQueryMonitoring {
+		groups = append(groups, &group{name: "query monitoring", interval: seconds(d.args.DaemonQueryMonitoringInterval), collect: d.collectQueryPerformance})
+	}
+	return groups
+}
+
+// runDue collects the groups due at now within one run deadline and publishes what they collected, along with the
+// self-metrics of the cycle
+func (d *daemon) runDue(ctx context.Context, groups []*group, now time.Time) {
+	cycleCtx, cancel := d.cycleContext(ctx)
+	defer cancel()
+	defer selfmetrics.Reset()
+
+	cycleStart := time.Now()
+	for _, g := range groups {
+		if now.Before(g.next) {
+			continue
+		}
+		start := time.Now()
+		g.collect(cycleCtx)
+		log.Debug("%s collected in %s", g.name, time.Since(start))
+		g.next = now.Add(g.interval)
+	}
+
+	if instance, err := d.instanceEntity(); err != nil {
+		log.Error("Error creating instance entity: %s", err.Error())
+	} else if err := selfmetrics.Populate(instance, time.Since(cycleStart), d.ci.OpenConnections()); err != nil {
+		log.Error("Error populating self-metrics: %s", err.Error())
+	}
+	if err := d.pgIntegration.Publish(); err != nil {
+		log.Error(err.Error())
+	}
+}
+
+func (d *daemon) cycleContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.args.RunTimeout > 0 {
+		return context.WithTimeout(ctx, seconds(d.args.RunTimeout))
+	}
+	return context.WithCancel(ctx)
+}
+
+// discover detects the server version, the databases, tables and indexes to collect, and the extensions used by
+// query monitoring. The catalog capabilities are checked again after each discovery. The previous results are kept
+// when discovery fails.
+func (d *daemon) discover(ctx context.Context) {
+	con, err := d.ci.NewConnection(d.ci.DatabaseName())
+	if err != nil {
+		log.Error("Discovery failed: error creating connection to PostgreSQL: %s", err.Error())
+		return
+	}
+	defer con.Close()
+
+	version, err := metrics.CollectVersion(ctx, con)
+	if err != nil {
+		log.Error("Discovery failed: error collecting version number: %s", err.Error())
+		return
+	}
+	d.ci.ResetCapabilities()
+	databaseList, err := collection.BuildCollectionList(ctx, d.args, d.ci)
+	if err != nil {
+		log.Error("Discovery failed: error creating list of entities to collect: %s", err)
+		return
+	}
+	d.version, d.databaseList = version, databaseList
+
+	if d.args.EnableQueryMonitoring {
+		d.queryPerformance = queryperformancemonitoring.NewQueryPerformance(ctx, d.args, d.pgIntegration, databaseList, d.ci)
+	}
+}
+
+func (d *daemon) collectMetrics(ctx context.Context) {
+	if d.version == nil {
+		log.Debug("Skipping metrics collection: server not discovered yet")
+		return
+	}
+	con, err := d.ci.NewConnection(d.ci.DatabaseName())
+	if err != nil {
+		log.Error("Metrics collection failed: error creating connection to PostgreSQL: %s", err.Error())
+		return
+	}
+	defer con.Close()
+
+	// Bloat is collected by its own group
+	var samples []metrics.Sample
+	if d.otlpExporter != nil {
+		// The bloat group exports with the start time read here
+		if startTime, err := metrics.CollectStatsStartTime(ctx, con); err != nil {
+			log.Warn("Error collecting the start time of the statistics counters: %s", err.Error())
+		} else {
+			d.otlpExporter.SetStartTime(startTime)
+		}
+		samples = metrics.CollectSamples(ctx, d.ci, con, d.version, d.databaseList, d.args.Pgbouncer, d.args.CollectDbLockMetrics, false)
+		d.exportOTLP(ctx, samples)
+	}
+	if !d.args.OutputsSDK() {
+		return
+	}
+	instance, err := d.instanceEntity()
+	if err != nil {
+		log.Error("Error creating instance entity: %s", err.Error())
+		return
+	}
+	if d.otlpExporter != nil {
+		// Both outputs are built from the same rows
+		metrics.PopulateSamples(samples, instance, d.pgIntegration)
+		if d.args.CustomMetricsQuery != "" {
+			metrics.PopulateCustomMetrics(ctx, d.args.CustomMetricsQuery, d.pgIntegration, con, d.ci, instance)
+		}
+	} else {
+		metrics.PopulateMetricsForVersion(ctx, d.ci, con, d.version, d.databaseList, instance, d.pgIntegration, d.args.Pgbouncer, d.args.CollectDbLockMetrics, false, d.args.CustomMetricsQuery)
+	}
+	if d.args.CustomMetricsConfig != "" {
+		metrics.PopulateCustomMetricsFromFile(ctx, d.ci, d.args.CustomMetricsConfig, d.pgIntegration)
+	}
+}
+
+func (d *daemon) collectBloat(ctx context.Context) {
+	if d.version == nil {
+		log.Debug("Skipping bloat collection: server not discovered yet")
+		return
+	}
+	if d.otlpExporter == nil {
+		if d.args.OutputsSDK() {
+			metrics.PopulateTableBloatMetrics(ctx, d.databaseList, d.version, d.pgIntegration, d.ci)
+		}
+		return
+	}
+	samples := metrics.CollectTableBloatSamples(ctx, d.ci, d.version, d.databaseList)
+	d.exportOTLP(ctx, samples)
+	if d.args.OutputsSDK() {
+		instance, err := d.instanceEntity()
+		if err != nil {
+			log.Error("Error creating instance entity: %s", err.Error())
+			return
+		}
+		metrics.PopulateSamples(samples, instance, d.pgIntegration)
+	}
+}
+
+func (d *daemon) exportOTLP(ctx context.Context, samples []metrics.Sample) {
+	if err := d.otlpExporter.Export(ctx, samples, time.Now()); err != nil {
+		log.Error("OTLP export failed: %s", err.Error())
+	}
+}
+
+func (d *daemon) collectInventory(ctx context.Context) {
+	instance, err := d.instanceEntity()
+	if err != nil {
+		log.Error("Error creating instance entity: %s", err.Error())
+		return
+	}
+	con, err := d.ci.NewConnection(d.ci.DatabaseName())
+	if err != nil {
+		log.Error("Inventory collection failed: error creating connection to PostgreSQL: %s", err.Error())
+		return
+	}
+	defer con.Close()
+	inventory.PopulateInventory(ctx, instance, con)
+}
+
+func (d *daemon) collectQueryPerformance(ctx context.Context) {
+	if d.queryPerformance == nil {
+		log.Debug("Skipping query monitoring: not supported by the server or not discovered yet")
+		return
+	}
+	d.queryPerformance.Collect(ctx)
+}
+
+// instanceEntity returns the instance entity, which has to be requested again after every publish
+func (d *daemon) instanceEntity() (*integration.Entity, error) {
+	return d.pgIntegration.Entity(fmt.Sprintf("%s:%s", d.args.Hostname, d.args.Port), "pg-instance")
+}
+
+// nextDue returns the earliest time a group is due
+func nextDue(groups []*group) time.Time {
+	var next time.Time
+	for _, g := range groups {
+		if next.IsZero() || g.next.Before(next) {
+			next = g.next
+		}
+	}
+	return next
+}
+
+func seconds(value int) time.Duration {
+	return time.Duration(value) * time.Second
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDue(t *testing.T) {
+	var payloads bytes.Buffer
+	pgIntegration, _ := integration.New("test", "test", integration.Writer(&payloads), integration.InMemoryStore())
+	d := &daemon{args: args.ArgumentList{RunTimeout: 10, Hostname: "localhost", Port: "5432"}, pgIntegration: pgIntegration, ci: &connection.MockInfo{}}
+
+	collected := map[string]int{}
+	newGroup := func(name string, interval time.Duration) *group {
+		return &group{name: name, interval: interval, collect: func(ctx context.Context) {
+			_, hasDeadline := ctx.Deadline()
+			assert.True(t, hasDeadline)
+			collected[name]++
+			entity, _ := pgIntegration.Entity("localhost:5432", "pg-instance")
+			entity.NewMetricSet("PostgresqlInstanceSample")
+		}}
+	}
+	groups := []*group{newGroup("metrics", 15*time.Second), newGroup("bloat", 10*time.Minute)}
+
+	start := time.Now()
+	d.runDue(context.Background(), groups, start)
+	assert.Equal(t, map[string]int{"metrics": 1, "bloat": 1}, collected)
+	assert.Empty(t, pgIntegration.Entities, "the cycle is published")
+	assert.Contains(t, payloads.String(), "PostgresqlInstanceSample")
+	assert.Contains(t, payloads.String(), "PostgresIntegrationSample", "the self-metrics of the cycle are published")
+	assert.Equal(t, start.Add(15*time.Second), nextDue(groups))
+
+	d.runDue(context.Background(), groups, start.Add(15*time.Second))
+	assert.Equal(t, map[string]int{"metrics": 2, "bloat": 1}, collected)
+	assert.Equal(t, start.Add(30*time.Second), nextDue(groups))
+}
+
+func TestGroups(t *testing.T) {
+	d := &daemon{args: args.ArgumentList{
+		CollectBloatMetrics:           false,
+		EnableQueryMonitoring:         true,
+		DaemonMetricsInterval:         15,
+		DaemonInventoryInterval:       3600,
+		DaemonQueryMonitoringInterval: 60,
+		DaemonDiscoveryInterval:       600,
+	}}
+
+	var names []string
+	for _, g := range d.groups() {
+		names = append(names, g.name)
+	}
+	assert.Equal(t, []string{"discovery", "metrics", "inventory", "query monitoring"}, names)
+}
@@ -0,0 +1,123 @@
+// Package exporter serves the integration metrics over HTTP in the Prometheus text exposition format
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/collection"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/metrics"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Exporter collects the metrics of every entity on each scrape. The server version and the databases, tables and
+// indexes to collect are discovered on the first scrape and again every DaemonDiscoveryInterval seconds.
+type Exporter struct {
+	args         args.ArgumentList
+	ci           connection.Info
+	mu           sync.Mutex
+	version      *semver.Version
+	databaseList collection.DatabaseList
+	discoveredAt time.Time
+}
+
+// New returns an Exporter collecting from the server of ci
+func New(al args.ArgumentList, ci connection.Info) *Exporter {
+	return &Exporter{args: al, ci: ci}
+}
+
+// Serve listens on ExporterListenAddress and serves the metrics on ExporterMetricsPath until ctx is done
+func Serve(ctx context.Context, al args.ArgumentList, ci connection.Info) error {
+	mux := http.NewServeMux()
+	mux.Handle(al.ExporterMetricsPath, New(al, ci))
+	server := &http.Server{Addr: al.ExporterListenAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error("Error shutting down the exporter: %s", err.Error())
+		}
+	}()
+
+	log.Info("Serving Prometheus metrics on %s%s", al.ExporterListenAddress, al.ExporterMetricsPath)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP collects the metrics and writes them in the Prometheus text exposition format. Scrapes are served one
+// at a time, and each records its self-metrics on its own so they are not mixed with those of a daemon run.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stats := selfmetrics.NewStats()
+	ctx := selfmetrics.WithStats(r.Context(), stats)
+	if e.args.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(e.args.RunTimeout)*time.Second)
+		defer cancel()
+	}
+
+	con, err := e.ci.NewConnection(e.ci.DatabaseName())
+	if err != nil {
+		log.Error("Scrape failed: error creating connection to PostgreSQL: %s", err.Error())
+		http.Error(w, "error connecting to PostgreSQL", http.StatusServiceUnavailable)
+		return
+	}
+	defer con.Close()
+
+	e.discover(ctx, con)
+	if e.version == nil {
+		http.Error(w, "PostgreSQL server not discovered", http.StatusServiceUnavailable)
+		return
+	}
+
+	samples := metrics.CollectSamples(ctx, e.ci, con, e.version, e.databaseList, e.args.Pgbouncer, e.args.CollectDbLockMetrics, e.args.CollectBloatMetrics)
+	rows, errs, timeouts := stats.Totals()
+	log.Debug("Scrape read %d rows with %d errors and %d timeouts", rows, errs, timeouts)
+	var body bytes.Buffer
+	if err := writeTextFormat(&body, samples); err != nil {
+		log.Error("Scrape failed: error formatting metrics: %s", err.Error())
+		http.Error(w, "error formatting metrics", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		log.Debug("Error writing scrape response: %s", err.Error())
+	}
+}
+
+// discover detects the server version and the entities to collect when they are missing or older than the
+// discovery interval, after which the catalog capabilities are checked again. The previous results are kept when
+// discovery fails.
+func (e *Exporter) discover(ctx context.Context, con *connection.PGSQLConnection) {
+	if e.version != nil && time.Since(e.discoveredAt) < time.Duration(e.args.DaemonDiscoveryInterval)*time.Second {
+		return
+	}
+	version, err := metrics.CollectVersion(ctx, con)
+	if err != nil {
+		log.Error("Discovery failed: error collecting version number: %s", err.Error())
+		return
+	}
+	e.ci.ResetCapabilities()
+	databaseList, err := collection.BuildCollectionList(ctx, e.args, e.ci)
+	if err != nil {
+		log.Error("Discovery failed: error creating list of entities to collect: %s", err)
+		return
+	}
+	e.version, e.databaseList, e.discoveredAt = version, databaseList, time.Now()
+}
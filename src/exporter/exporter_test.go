@@ -0,0 +1,49 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
+	"github.com/stretchr/testify/assert"
+	tmock "github.com/stretchr/testify/mock"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestExporter_ServeHTTP(t *testing.T) {
+	testConnection, mock := connection.CreateMockSQL(t)
+	mock.ExpectQuery(".*server_version.*").
+		WillReturnRows(sqlmock.NewRows([]string{"server_version"}).AddRow("9.0.5"))
+	mock.ExpectQuery(".*scheduled_checkpoints_performed.*").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"scheduled_checkpoints_performed",
+			"requested_checkpoints_performed",
+			"buffers_written_during_checkpoint",
+			"buffers_written_by_background_writer",
+			"background_writer_stops",
+			"buffers_written_by_backend",
+			"buffers_allocated",
+		}).AddRow(1, 2, 3, 4, 5, 6, 7))
+
+	ci := &connection.MockInfo{}
+	ci.On("NewConnection", tmock.Anything).Return(testConnection, nil)
+	e := New(args.ArgumentList{CollectionList: "{}", DaemonDiscoveryInterval: 600}, ci)
+
+	selfmetrics.Reset()
+	defer selfmetrics.Reset()
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, contentType, recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "# TYPE postgresql_bgwriter_checkpoints_scheduled_total counter\n")
+	assert.Contains(t, recorder.Body.String(), `postgresql_bgwriter_buffers_allocated_total{host="testhost",port="1234"} 7`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	rows, _, _ := selfmetrics.FromContext(context.Background()).Totals()
+	assert.Zero(t, rows, "scrapes do not record into the statistics of the daemon")
+}
@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/nri-postgresql/src/metrics"
+)
+
+const metricPrefix = "postgresql_"
+
+var (
+	invalidNameCharsRegex = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+	camelCaseRegex        = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	labelValueEscaper     = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// family is a Prometheus metric family: every series of one metric name
+type family struct {
+	help       string
+	metricType string
+	series     map[string]float64
+}
+
+// writeTextFormat writes the metrics of samples in the Prometheus text exposition format. Each field with a
+// metric_name tag is a metric, with the ID attributes of the entity and the attribute fields of the row as labels.
+// Rate and delta source types are counters, reported with their cumulative value; other source types are gauges.
+func writeTextFormat(w io.Writer, samples []metrics.Sample) error {
+	families := make(map[string]*family)
+	for _, sample := range samples {
+		attributes, values := sample.Values()
+		labels := make(map[string]string, len(attributes))
+		for key, value := range attributes {
+			labels[snakeCase(key)] = value
+		}
+		series := formatLabels(labels)
+		for _, value := range values {
+			name, metricType := familyName(value.MetricName, value.SourceType)
+			f, ok := families[name]
+			if !ok {
+				f = &family{help: value.MetricName, metricType: metricType, series: make(map[string]float64)}
+				families[name] = f
+			}
+			f.series[series] = value.Value
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := families[name]
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.metricType); err != nil {
+			return err
+		}
+		series := make([]string, 0, len(f.series))
+		for labels := range f.series {
+			series = append(series, labels)
+		}
+		sort.Strings(series)
+		for _, labels := range series {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", name, labels, strconv.FormatFloat(f.series[labels], 'g', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// familyName converts a metric_name tag to a Prometheus metric name, e.g. table.liveRows to
+// postgresql_table_live_rows. Counters drop the PerSecond suffix of their rate name and end in _total.
+func familyName(metricName string, sourceType string) (string, string) {
+	if sourceType == "rate" || sourceType == "delta" {
+		return metricPrefix + snakeCase(strings.TrimSuffix(metricName, "PerSecond")) + "_total", "counter"
+	}
+	return metricPrefix + snakeCase(metricName), "gauge"
+}
+
+func snakeCase(name string) string {
+	name = camelCaseRegex.ReplaceAllString(name, "${1}_${2}")
+	return strings.Trim(strings.ToLower(invalidNameCharsRegex.ReplaceAllString(name, "_")), "_")
+}
+
+// formatLabels returns the labels sorted by name, e.g. {host="localhost",port="5432"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
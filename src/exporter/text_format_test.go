@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/newrelic/nri-postgresql/src/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTextFormat(t *testing.T) {
+	database := "db\"1"
+	samples := []metrics.Sample{
+		{
+			Namespace:    "pg-table",
+			IDAttributes: map[string]string{"host": "localhost", "port": "5432", "pg-database": database, "pg-table": "table1"},
+			Row: metrics.Row{
+				Columns: map[string]interface{}{
+					"database":   database,
+					"n_live_tup": int64(12),
+					"seq_scan":   []byte("3"),
+					"n_dead_tup": nil,
+					"state":      "active",
+					"ratio":      0.5,
+				},
+				Metrics: []metrics.MetricColumn{
+					{Column: "n_live_tup", MetricName: "table.liveRows", SourceType: "gauge"},
+					{Column: "seq_scan", MetricName: "table.sequentialScansPerSecond", SourceType: "rate"},
+					{Column: "n_dead_tup", MetricName: "table.deadRows", SourceType: "gauge"},
+					{Column: "state", MetricName: "state", SourceType: "attribute"},
+				},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, writeTextFormat(&out, samples))
+
+	labels := `{host="localhost",pg_database="db\"1",pg_table="table1",port="5432",state="active"}`
+	expected := "# HELP postgresql_table_live_rows table.liveRows\n" +
+		"# TYPE postgresql_table_live_rows gauge\n" +
+		"postgresql_table_live_rows" + labels + " 12\n" +
+		"# HELP postgresql_table_sequential_scans_total table.sequentialScansPerSecond\n" +
+		"# TYPE postgresql_table_sequential_scans_total counter\n" +
+		"postgresql_table_sequential_scans_total" + labels + " 3\n"
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFamilyName(t *testing.T) {
+	name, metricType := familyName("bgwriter.checkpointsScheduledPerSecond", "rate")
+	assert.Equal(t, "postgresql_bgwriter_checkpoints_scheduled_total", name)
+	assert.Equal(t, "counter", metricType)
+
+	name, metricType = familyName("db.connections.max", "gauge")
+	assert.Equal(t, "postgresql_db_connections_max", name)
+	assert.Equal(t, "gauge", metricType)
+}
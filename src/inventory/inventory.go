@@ -7,11 +7,13 @@ import (
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	"github.com/newrelic/nri-postgresql/src/connection"
-	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/selfmetrics"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 )
 
 const (
 	configQuery = `SELECT name, setting, boot_val, reset_val FROM pg_settings`
+	// collectorName identifies the inventory in the self-metrics
+	collectorName = "inventory"
 )
 
 type configQueryRow struct {
@@ -26,12 +28,15 @@ func PopulateInventory(ctx context.Context, entity *integration.Entity, connecti
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	
-	selfmetrics.IncQueries()
-	
+	stats := selfmetrics.FromContext(ctx)
+	start := time.Now()
 	configRows := make([]*configQueryRow, 0)
 	if err := connection.QueryContext(ctx, &configRows, configQuery); err != nil {
 		log.Error("Failed to execute config query: %v", err)
+		stats.RecordError(collectorName, err)
 	}
+	stats.Observe(collectorName, time.Since(start))
+	stats.AddRows(collectorName, len(configRows))
 
 	for _, row := range configRows {
 		logInventoryFailure(entity.SetInventoryItem(row.Name+"/setting", "value", row.Setting))
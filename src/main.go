@@ -5,8 +5,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
 	"strings"
+	"syscall"
+	"time"
 
 	queryperformancemonitoring "github.com/newrelic/nri-postgresql/src/query-performance-monitoring"
 
@@ -15,8 +18,12 @@ import (
 	"github.com/newrelic/nri-postgresql/src/args"
 	"github.com/newrelic/nri-postgresql/src/collection"
 	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/daemon"
+	"github.com/newrelic/nri-postgresql/src/exporter"
 	"github.com/newrelic/nri-postgresql/src/inventory"
 	"github.com/newrelic/nri-postgresql/src/metrics"
+	"github.com/newrelic/nri-postgresql/src/otlp"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 )
 
 const (
@@ -59,8 +66,51 @@ func main() {
 		os.Exit(1)
 	}
 
+	metrics.SetQueryTimeouts(args.MetricsQueryTimeout, args.MetricsQueryTimeouts)
+	metrics.SetConcurrency(args.Concurrency)
+	if err := metrics.LoadDefinitions(args.MetricDefinitionsDir); err != nil {
+		log.Error("Error loading metric definitions: %s", err.Error())
+		os.Exit(1)
+	}
+
 	connectionInfo := connection.DefaultConnectionInfo(&args)
-	collectionList, err := collection.BuildCollectionList(args, connectionInfo)
+	defer connectionInfo.Close()
+
+	var otlpExporter *otlp.Exporter
+	if args.OutputsOTLP() {
+		otlpExporter, err = otlp.New(args, integrationVersion)
+		if err != nil {
+			log.Error("Configuration error for OTLP output: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if args.Daemon || args.Exporter {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if args.Exporter {
+			go func() {
+				if err := exporter.Serve(ctx, args, connectionInfo); err != nil {
+					log.Error("Prometheus exporter failed: %s", err.Error())
+					stop()
+				}
+			}()
+		}
+		if args.Daemon {
+			daemon.Run(ctx, args, pgIntegration, connectionInfo, otlpExporter)
+		}
+		<-ctx.Done()
+		return
+	}
+
+	runStart := time.Now()
+	ctx := context.Background()
+	if args.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(args.RunTimeout)*time.Second)
+		defer cancel()
+	}
+	collectionList, err := collection.BuildCollectionList(ctx, args, connectionInfo)
 	if err != nil {
 		log.Error("Error creating list of entities to collect: %s", err)
 		os.Exit(1)
@@ -72,9 +122,13 @@ func main() {
 	}
 
 	if args.HasMetrics() {
-		metrics.PopulateMetrics(connectionInfo, collectionList, instance, pgIntegration, args.Pgbouncer, args.CollectDbLockMetrics, args.CollectBloatMetrics, args.CustomMetricsQuery)
-		if args.CustomMetricsConfig != "" {
-			metrics.PopulateCustomMetricsFromFile(connectionInfo, args.CustomMetricsConfig, pgIntegration)
+		if otlpExporter != nil {
+			exportOTLP(ctx, args, connectionInfo, collectionList, otlpExporter, instance, pgIntegration)
+		} else {
+			metrics.PopulateMetrics(ctx, connectionInfo, collectionList, instance, pgIntegration, args.Pgbouncer, args.CollectDbLockMetrics, args.CollectBloatMetrics, args.CustomMetricsQuery)
+		}
+		if args.OutputsSDK() && args.CustomMetricsConfig != "" {
+			metrics.PopulateCustomMetricsFromFile(ctx, connectionInfo, args.CustomMetricsConfig, pgIntegration)
 		}
 	}
 
@@ -84,8 +138,6 @@ func main() {
 			log.Error("Inventory collection failed: error creating connection to PostgreSQL: %s", err.Error())
 		} else {
 			defer con.Close()
-			// Create a context for the inventory collection
-			ctx := context.Background()
 			inventory.PopulateInventory(ctx, instance, con)
 		}
 	}
@@ -95,6 +147,56 @@ func main() {
 	}
 
 	if args.EnableQueryMonitoring {
-		queryperformancemonitoring.QueryPerformanceMain(args, pgIntegration, collectionList)
+		queryperformancemonitoring.QueryPerformanceMain(ctx, args, pgIntegration, collectionList, connectionInfo)
+	}
+
+	publishSelfMetrics(pgIntegration, args, connectionInfo, time.Since(runStart))
+}
+
+// publishSelfMetrics publishes the PostgresIntegrationSample of every collector of the run on the instance entity
+func publishSelfMetrics(pgIntegration *integration.Integration, args args.ArgumentList, ci connection.Info, runDuration time.Duration) {
+	instance, err := pgIntegration.Entity(fmt.Sprintf("%s:%s", args.Hostname, args.Port), "pg-instance")
+	if err != nil {
+		log.Error("Error creating instance entity: %s", err.Error())
+		return
+	}
+	if err := selfmetrics.Populate(instance, runDuration, ci.OpenConnections()); err != nil {
+		log.Error("Error populating self-metrics: %s", err.Error())
+		return
+	}
+	if err := pgIntegration.Publish(); err != nil {
+		log.Error(err.Error())
+	}
+}
+
+// exportOTLP collects the metrics of every entity and exports them as OpenTelemetry metrics. When the SDK output is
+// enabled too, the same rows are added to the entities of pgIntegration.
+func exportOTLP(ctx context.Context, args args.ArgumentList, ci connection.Info, collectionList collection.DatabaseList, otlpExporter *otlp.Exporter, instance *integration.Entity, pgIntegration *integration.Integration) {
+	con, err := ci.NewConnection(ci.DatabaseName())
+	if err != nil {
+		log.Error("OTLP export failed: error creating connection to PostgreSQL: %s", err.Error())
+		return
+	}
+	defer con.Close()
+
+	version, err := metrics.CollectVersion(ctx, con)
+	if err != nil {
+		log.Error("OTLP export failed: error collecting version number: %s", err.Error())
+		return
+	}
+	if startTime, err := metrics.CollectStatsStartTime(ctx, con); err != nil {
+		log.Warn("Error collecting the start time of the statistics counters: %s", err.Error())
+	} else {
+		otlpExporter.SetStartTime(startTime)
+	}
+	samples := metrics.CollectSamples(ctx, ci, con, version, collectionList, args.Pgbouncer, args.CollectDbLockMetrics, args.CollectBloatMetrics)
+	if err := otlpExporter.Export(ctx, samples, time.Now()); err != nil {
+		log.Error("OTLP export failed: %s", err.Error())
+	}
+	if args.OutputsSDK() {
+		metrics.PopulateSamples(samples, instance, pgIntegration)
+		if args.CustomMetricsQuery != "" {
+			metrics.PopulateCustomMetrics(ctx, args.CustomMetricsQuery, pgIntegration, con, ci, instance)
+		}
 	}
 }
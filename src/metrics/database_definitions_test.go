@@ -40,8 +40,7 @@ func Test_insertDatabaseNames(t *testing.T) {
 	t.Parallel()
 
 	testDefinition := &QueryDefinition{
-		query:      `SELECT * FROM test WHERE database IN (%DATABASES%);`,
-		dataModels: &[]struct{}{},
+		query: `SELECT * FROM test WHERE database IN (%DATABASES%);`,
 	}
 
 	databaseList := collection.DatabaseList{"test1": {}, "test2": {}}
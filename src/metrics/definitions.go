@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nri-postgresql/src/capabilities"
+	"github.com/newrelic/nri-postgresql/src/versions"
+	yaml "gopkg.in/yaml.v3"
+)
+
+//go:embed definitions/*.yml
+var builtinDefinitionFiles embed.FS
+
+// definitionEntities maps each definition type to the entity its rows are reported on
+var definitionEntities = map[string]string{
+	"instance":  "pg-instance",
+	"database":  "pg-database",
+	"lock":      "pg-database",
+	"table":     "pg-table",
+	"bloat":     "pg-table",
+	"index":     "pg-index",
+	"pgbouncer": "pgbouncer",
+}
+
+// definitionPlaceholders maps the definition types collected for a list of objects to the placeholder their query
+// must contain
+var definitionPlaceholders = map[string]string{
+	"database": "%DATABASES%",
+	"lock":     "%DATABASES%",
+	"table":    "%SCHEMA_TABLES%",
+	"bloat":    "%SCHEMA_TABLES%",
+	"index":    "%SCHEMA_TABLE_INDEXES%",
+}
+
+type definitionsYAML struct {
+	Definitions []*definitionYAML `yaml:"definitions"`
+}
+
+// definitionYAML is a metric definition as written in a definitions file
+type definitionYAML struct {
+	versions.Range `yaml:",inline"`
+
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Entity string `yaml:"entity"`
+	// Requires are the capabilities the server must have, checked on the connection the query runs on
+	Requires []capabilities.Capability `yaml:"requires"`
+	Disabled bool                      `yaml:"disabled"`
+	Query    string                    `yaml:"query"`
+	Metrics  []MetricColumn            `yaml:"metrics"`
+}
+
+// definitions are the metric definitions in use, the built-in ones with the overrides of LoadDefinitions
+var definitions = mustLoadBuiltinDefinitions()
+
+func mustLoadBuiltinDefinitions() []*definitionYAML {
+	files, err := builtinDefinitionFiles.ReadDir("definitions")
+	if err != nil {
+		panic(err)
+	}
+	var builtin []*definitionYAML
+	for _, file := range files {
+		content, err := builtinDefinitionFiles.ReadFile("definitions/" + file.Name())
+		if err != nil {
+			panic(err)
+		}
+		fileDefinitions, err := parseDefinitions(file.Name(), content)
+		if err != nil {
+			panic(err)
+		}
+		builtin = append(builtin, fileDefinitions...)
+	}
+	if err := checkUniqueNames(builtin); err != nil {
+		panic(err)
+	}
+	return builtin
+}
+
+// LoadDefinitions validates the *.yml and *.yaml definition files of dir and merges them into the built-in
+// definitions: a definition replaces the built-in one with the same name, or is added if there is none. An empty
+// dir keeps the built-in definitions.
+func LoadDefinitions(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading metric definitions directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".yml" || ext == ".yaml") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var overrides []*definitionYAML
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("reading metric definitions file: %w", err)
+		}
+		fileDefinitions, err := parseDefinitions(name, content)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, fileDefinitions...)
+	}
+	if err := checkUniqueNames(overrides); err != nil {
+		return err
+	}
+
+	merged := append([]*definitionYAML{}, definitions...)
+	for _, override := range overrides {
+		replaced := false
+		for i, def := range merged {
+			if def.Name == override.Name {
+				merged[i], replaced = override, true
+				log.Debug("Metric definition %s overridden from %s", override.Name, dir)
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, override)
+			log.Debug("Metric definition %s added from %s", override.Name, dir)
+		}
+	}
+	definitions = merged
+	return nil
+}
+
+func parseDefinitions(file string, content []byte) ([]*definitionYAML, error) {
+	var parsed definitionsYAML
+	if err := yaml.Unmarshal(content, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing metric definitions file %s: %w", file, err)
+	}
+	for _, def := range parsed.Definitions {
+		if err := def.validate(); err != nil {
+			return nil, fmt.Errorf("invalid metric definition %q in %s: %w", def.Name, file, err)
+		}
+	}
+	return parsed.Definitions, nil
+}
+
+func checkUniqueNames(defs []*definitionYAML) error {
+	seen := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		if seen[def.Name] {
+			return fmt.Errorf("metric definition %q is defined more than once", def.Name)
+		}
+		seen[def.Name] = true
+	}
+	return nil
+}
+
+// validate checks the definition. A disabled definition only needs a name.
+func (d *definitionYAML) validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if d.Disabled {
+		return nil
+	}
+
+	entity, ok := definitionEntities[d.Type]
+	if !ok {
+		return fmt.Errorf("unknown type %q", d.Type)
+	}
+	if d.Entity != entity {
+		return fmt.Errorf("entity of a %s definition must be %s, not %q", d.Type, entity, d.Entity)
+	}
+
+	if strings.TrimSpace(d.Query) == "" {
+		return fmt.Errorf("query is required")
+	}
+	if placeholder, ok := definitionPlaceholders[d.Type]; ok && !strings.Contains(d.Query, placeholder) {
+		return fmt.Errorf("query of a %s definition must contain %s", d.Type, placeholder)
+	}
+
+	if err := d.Range.Validate(); err != nil {
+		return err
+	}
+	for _, capability := range d.Requires {
+		if err := capability.Validate(); err != nil {
+			return err
+		}
+	}
+	// the PgBouncer admin console has neither a server version nor a catalog to probe
+	if d.Type == "pgbouncer" && (d.Range != versions.Range{} || len(d.Requires) > 0) {
+		return fmt.Errorf("pgbouncer definitions cannot have version bounds or required capabilities")
+	}
+
+	if len(d.Metrics) == 0 {
+		return fmt.Errorf("metrics are required")
+	}
+	columns := make(map[string]bool, len(d.Metrics))
+	for _, m := range d.Metrics {
+		if m.Column == "" || m.MetricName == "" {
+			return fmt.Errorf("column and metric_name are required in every metric")
+		}
+		if columns[m.Column] {
+			return fmt.Errorf("column %s is mapped more than once", m.Column)
+		}
+		columns[m.Column] = true
+		if _, err := metric.SourceTypeForName(m.SourceType); err != nil {
+			return fmt.Errorf("column %s: %w", m.Column, err)
+		}
+	}
+	return nil
+}
+
+// definitionsFor returns the enabled definitions of definitionType whose range contains version, in the order they
+// are defined. Their required capabilities are checked when their query runs.
+func definitionsFor(definitionType string, version *semver.Version) []*QueryDefinition {
+	var queryDefinitions []*QueryDefinition
+	for _, def := range definitions {
+		if def.Disabled || def.Type != definitionType || !def.Range.Contains(version) {
+			continue
+		}
+		queryDefinitions = append(queryDefinitions, &QueryDefinition{
+			name:           def.Name,
+			definitionType: def.Type,
+			query:          def.Query,
+			metrics:        def.Metrics,
+			requires:       def.Requires,
+		})
+	}
+	return queryDefinitions
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/nri-postgresql/src/capabilities"
+	"github.com/newrelic/nri-postgresql/src/collection"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// withDefinitionsDir writes files to a directory, and restores the built-in definitions after the test
+func withDefinitionsDir(t *testing.T, files map[string]string) string {
+	builtin := definitions
+	t.Cleanup(func() { definitions = builtin })
+
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	return dir
+}
+
+func TestBuiltinDefinitions(t *testing.T) {
+	types := map[string]int{}
+	for _, def := range definitions {
+		assert.NoError(t, def.validate(), def.Name)
+		types[def.Type]++
+	}
+	assert.Equal(t, map[string]int{"instance": 6, "database": 3, "lock": 1, "table": 1, "bloat": 2, "index": 1, "pgbouncer": 2}, types)
+}
+
+func TestLoadDefinitions_Empty(t *testing.T) {
+	builtin := definitions
+	assert.NoError(t, LoadDefinitions(""))
+	assert.Equal(t, builtin, definitions)
+}
+
+func TestLoadDefinitions(t *testing.T) {
+	dir := withDefinitionsDir(t, map[string]string{
+		"override.yml": `
+definitions:
+  - name: index
+    type: index
+    entity: pg-index
+    query: SELECT -- CUSTOMINDEX %SCHEMA_TABLE_INDEXES%
+    metrics:
+      - {column: index_size, metric_name: index.sizeInBytes, source_type: gauge}
+  - name: instance_io_v17
+    disabled: true
+`,
+		"extend.yaml": `
+definitions:
+  - name: instance_wal
+    type: instance
+    entity: pg-instance
+    min_version: "14"
+    query: SELECT wal_records FROM pg_stat_wal;
+    metrics:
+      - {column: wal_records, metric_name: wal.recordsPerSecond, source_type: rate}
+`,
+		"README.md": "not a definitions file",
+	})
+	require.NoError(t, LoadDefinitions(dir))
+
+	index := generateIndexDefinitions(collection.SchemaList{"s": {"t": {"i"}}}, nil)
+	require.Len(t, index, 1)
+	assert.Equal(t, "SELECT -- CUSTOMINDEX 's.t.i'", index[0].GetQuery())
+	assert.Equal(t, "index", index[0].GetName())
+
+	v17 := semver.MustParse("17.2.0")
+	assert.Equal(t, append(definitionQueries(t, "instance_bgwriter_v17", "instance_checkpointer_v17"), "SELECT wal_records FROM pg_stat_wal;"),
+		queriesOf(generateInstanceDefinitions(&v17)))
+	assert.Equal(t, "instance_wal", generateInstanceDefinitions(&v17)[2].GetName())
+
+	v13 := semver.MustParse("13.0.0")
+	assert.Len(t, generateInstanceDefinitions(&v13), 3)
+}
+
+func TestLoadDefinitions_Invalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+	}{
+		{"missing name", `{type: instance, entity: pg-instance, query: SELECT 1, metrics: [{column: a, metric_name: a, source_type: gauge}]}`},
+		{"unknown type", `{name: x, type: view, entity: pg-instance, query: SELECT 1, metrics: [{column: a, metric_name: a, source_type: gauge}]}`},
+		{"wrong entity", `{name: x, type: table, entity: pg-index, query: "%SCHEMA_TABLES%", metrics: [{column: a, metric_name: a, source_type: gauge}]}`},
+		{"missing query", `{name: x, type: instance, entity: pg-instance, metrics: [{column: a, metric_name: a, source_type: gauge}]}`},
+		{"missing placeholder", `{name: x, type: database, entity: pg-database, query: SELECT 1, metrics: [{column: a, metric_name: a, source_type: gauge}]}`},
+		{"invalid version", `{name: x, type: instance, entity: pg-instance, min_version: 9.x, query: SELECT 1, metrics: [{column: a, metric_name: a, source_type: gauge}]}`},
+		{"invalid capability", `{name: x, type: instance, entity: pg-instance, requires: [{column: evictions}], query: SELECT 1, metrics: [{column: a, metric_name: a, source_type: gauge}]}`},
+		{"pgbouncer version", `{name: x, type: pgbouncer, entity: pgbouncer, min_version: "1", query: SHOW LISTS;, metrics: [{column: a, metric_name: a, source_type: gauge}]}`},
+		{"inverted range", `{name: x, type: instance, entity: pg-instance, min_version: "12", max_version: "9.6", query: SELECT 1, metrics: [{column: a, metric_name: a, source_type: gauge}]}`},
+		{"no metrics", `{name: x, type: instance, entity: pg-instance, query: SELECT 1}`},
+		{"invalid source type", `{name: x, type: instance, entity: pg-instance, query: SELECT 1, metrics: [{column: a, metric_name: a, source_type: counter}]}`},
+		{"duplicate column", `{name: x, type: instance, entity: pg-instance, query: SELECT 1, metrics: [{column: a, metric_name: a, source_type: gauge}, {column: a, metric_name: b, source_type: gauge}]}`},
+		{"duplicate name", `{name: x, disabled: true}, {name: x, disabled: true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builtin := definitions
+			dir := withDefinitionsDir(t, map[string]string{"invalid.yml": "definitions: [" + tt.definition + "]"})
+			assert.Error(t, LoadDefinitions(dir))
+			assert.Equal(t, builtin, definitions, "definitions are unchanged")
+		})
+	}
+
+	assert.Error(t, LoadDefinitions(filepath.Join(t.TempDir(), "missing")))
+}
+
+func TestQueryDefinition_Requires(t *testing.T) {
+	testConnection, mock := connection.CreateMockSQL(t)
+	mock.ExpectQuery(".*CAPABILITY_COLUMN.*").WithArgs("pg_stat_io", "evictions").
+		WillReturnRows(sqlmock.NewRows([]string{"columns"}).AddRow(0))
+
+	definition := &QueryDefinition{
+		name:     "instance",
+		query:    "SELECT evictions FROM pg_stat_io;",
+		requires: []capabilities.Capability{capabilities.Column("pg_stat_io", "evictions")},
+	}
+	rows, err := queryDefinition(context.Background(), testConnection, definition)
+	assert.NoError(t, err)
+	assert.Empty(t, rows)
+	assert.NoError(t, mock.ExpectationsWereMet(), "the query is skipped")
+}
+
+func TestRow_populateMetricSet(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testEntity, _ := testIntegration.Entity("testInstance", "instance")
+	ms := testEntity.NewMetricSet("TestSample")
+
+	row := Row{
+		Columns: map[string]interface{}{"size": []byte("12.5"), "count": int64(3), "mode": []byte("session"), "missing": nil},
+		Metrics: []MetricColumn{
+			{Column: "size", MetricName: "sizeInBytes", SourceType: "gauge"},
+			{Column: "count", MetricName: "count", SourceType: "GAUGE"},
+			{Column: "mode", MetricName: "mode", SourceType: "attribute"},
+			{Column: "missing", MetricName: "missing", SourceType: "gauge"},
+			{Column: "absent", MetricName: "absent", SourceType: "gauge"},
+		},
+	}
+	require.NoError(t, row.populateMetricSet(ms))
+
+	assert.Equal(t, map[string]interface{}{
+		"event_type":  "TestSample",
+		"sizeInBytes": 12.5,
+		"count":       float64(3),
+		"mode":        "session",
+	}, ms.Metrics)
+}
@@ -7,41 +7,65 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// definitionQueries returns the queries of the built-in definitions with the given names
+func definitionQueries(t *testing.T, names ...string) []string {
+	queries := make([]string, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, def := range definitions {
+			if def.Name == name {
+				queries = append(queries, def.Query)
+				found = true
+			}
+		}
+		assert.True(t, found, "definition %s not found", name)
+	}
+	return queries
+}
+
+func queriesOf(queryDefinitions []*QueryDefinition) []string {
+	queries := make([]string, 0, len(queryDefinitions))
+	for _, def := range queryDefinitions {
+		queries = append(queries, def.GetQuery())
+	}
+	return queries
+}
+
 func Test_generateInstanceDefinitions(t *testing.T) {
 	tests := []struct {
 		name            string
 		version         string
-		expectedQueries []*QueryDefinition
+		expectedQueries []string
 	}{
 		{
 			name:            "PostgreSQL 9.0",
 			version:         "9.0.0",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase},
+			expectedQueries: definitionQueries(t, "instance"),
 		},
 		{
 			name:            "PostgreSQL 9.1",
 			version:         "9.1.0",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91},
+			expectedQueries: definitionQueries(t, "instance", "instance_fsync"),
 		},
 		{
 			name:            "PostgreSQL 9.2",
 			version:         "9.2.0",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92},
+			expectedQueries: definitionQueries(t, "instance", "instance_fsync", "instance_checkpoint_time"),
 		},
 		{
 			name:            "PostgreSQL 10.2",
 			version:         "10.2.0",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92},
+			expectedQueries: definitionQueries(t, "instance", "instance_fsync", "instance_checkpoint_time"),
 		},
 		{
 			name:            "PostgreSQL 16.4",
 			version:         "16.4.2",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92},
+			expectedQueries: definitionQueries(t, "instance", "instance_fsync", "instance_checkpoint_time"),
 		},
 		{
 			name:            "PostgreSQL 17.0",
 			version:         "17.0.0",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase170, instanceDefinition170, instanceDefinitionInputOutput170},
+			expectedQueries: definitionQueries(t, "instance_bgwriter_v17", "instance_checkpointer_v17", "instance_io_v17"),
 		},
 	}
 
@@ -50,7 +74,10 @@ func Test_generateInstanceDefinitions(t *testing.T) {
 			version := semver.MustParse(tt.version)
 			queryDefinitions := generateInstanceDefinitions(&version)
 			assert.Equal(t, len(tt.expectedQueries), len(queryDefinitions))
-			assert.Equal(t, tt.expectedQueries, queryDefinitions)
+			assert.Equal(t, tt.expectedQueries, queriesOf(queryDefinitions))
+			for _, def := range queryDefinitions {
+				assert.Equal(t, "instance", def.definitionType)
+			}
 		})
 	}
 
@@ -60,9 +87,9 @@ func Test_generateInstanceDefinitionsOutOfOrder(t *testing.T) {
 	t.Run("PostgreSQL 17.5 order check", func(t *testing.T) {
 		version := semver.MustParse("17.5.0")
 		queryDefinitions := generateInstanceDefinitions(&version)
-		expectedQueries := []*QueryDefinition{instanceDefinitionInputOutput170, instanceDefinition170, instanceDefinitionBase170}
+		expectedQueries := definitionQueries(t, "instance_io_v17", "instance_checkpointer_v17", "instance_bgwriter_v17")
 
 		// This fails because order is different
-		assert.False(t, assert.ObjectsAreEqual(expectedQueries, queryDefinitions), "Query definitions should be in the correct order")
+		assert.False(t, assert.ObjectsAreEqual(expectedQueries, queriesOf(queryDefinitions)), "Query definitions should be in the correct order")
 	})
 }
@@ -2,16 +2,35 @@ package metrics
 
 import (
 	"fmt"
-	"reflect"
 	"strings"
 
+	"github.com/newrelic/nri-postgresql/src/capabilities"
 	"github.com/newrelic/nri-postgresql/src/collection"
 )
 
-// QueryDefinition holds the query and the unmarshall model
+// QueryDefinition holds the query and the metrics of its columns
 type QueryDefinition struct {
-	query      string
-	dataModels interface{}
+	// name is the name of the definition, e.g. table_bloat_v12, used in logs and self-metrics
+	name string
+	// definitionType is the type of the definition, e.g. table or bloat, whose timeout applies when name has none
+	definitionType string
+	query          string
+	metrics        []MetricColumn
+	// requires are the capabilities the connection must have for the query to run
+	requires []capabilities.Capability
+}
+
+// MetricColumn maps a column of a query to a metric
+type MetricColumn struct {
+	Column     string `yaml:"column"`
+	MetricName string `yaml:"metric_name"`
+	// SourceType is the source type name of the metric, e.g. gauge, rate or attribute
+	SourceType string `yaml:"source_type"`
+}
+
+// GetName returns the name of the QueryDefinition
+func (qd QueryDefinition) GetName() string {
+	return qd.name
 }
 
 // GetQuery returns the query of the QueryDefinition
@@ -19,10 +38,9 @@ func (qd QueryDefinition) GetQuery() string {
 	return qd.query
 }
 
-// GetDataModels returns the data models of the QueryDefinition
-func (qd QueryDefinition) GetDataModels() interface{} {
-	ptr := reflect.New(reflect.ValueOf(qd.dataModels).Type())
-	return ptr.Interface()
+// GetMetrics returns the metrics of the columns of the QueryDefinition
+func (qd QueryDefinition) GetMetrics() []MetricColumn {
+	return qd.metrics
 }
 
 func (qd QueryDefinition) insertDatabaseNames(databases collection.DatabaseList) *QueryDefinition {
@@ -38,8 +56,11 @@ func (qd QueryDefinition) insertDatabaseNames(databases collection.DatabaseList)
 	schemaDBString := strings.Join(schemaDBs, ",")
 
 	newDBDef := &QueryDefinition{
-		dataModels: qd.dataModels,
-		query:      strings.Replace(qd.query, `%DATABASES%`, schemaDBString, 1),
+		name:           qd.name,
+		definitionType: qd.definitionType,
+		metrics:        qd.metrics,
+		requires:       qd.requires,
+		query:          strings.Replace(qd.query, `%DATABASES%`, schemaDBString, 1),
 	}
 
 	return newDBDef
@@ -60,8 +81,11 @@ func (qd QueryDefinition) insertSchemaTables(schemaList collection.SchemaList) *
 	schemaTablesString := strings.Join(schemaTables, ",")
 
 	newTableDef := &QueryDefinition{
-		dataModels: qd.dataModels,
-		query:      strings.Replace(qd.query, `%SCHEMA_TABLES%`, schemaTablesString, 1),
+		name:           qd.name,
+		definitionType: qd.definitionType,
+		metrics:        qd.metrics,
+		requires:       qd.requires,
+		query:          strings.Replace(qd.query, `%SCHEMA_TABLES%`, schemaTablesString, 1),
 	}
 
 	return newTableDef
@@ -84,8 +108,11 @@ func (qd QueryDefinition) insertSchemaTableIndexes(schemaList collection.SchemaL
 	schemaTableIndexString := strings.Join(schemaTableIndexes, ",")
 
 	newIndexDef := &QueryDefinition{
-		dataModels: qd.dataModels,
-		query:      strings.Replace(qd.query, `%SCHEMA_TABLE_INDEXES%`, schemaTableIndexString, 1),
+		name:           qd.name,
+		definitionType: qd.definitionType,
+		metrics:        qd.metrics,
+		requires:       qd.requires,
+		query:          strings.Replace(qd.query, `%SCHEMA_TABLE_INDEXES%`, schemaTableIndexString, 1),
 	}
 
 	return newIndexDef
@@ -4,26 +4,44 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"reflect"
 	"regexp"
-	"sync"
+	"time"
 
 	"github.com/blang/semver/v4"
 	"github.com/newrelic/infra-integrations-sdk/v3/data/attribute"
 	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nri-postgresql/src/capabilities"
 	"github.com/newrelic/nri-postgresql/src/collection"
 	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/scheduler"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 	yaml "gopkg.in/yaml.v3"
 )
 
 const (
 	versionQuery = `SHOW server_version`
+	// statsStartTimeQuery is the time since which the cumulative counters of the statistics views count: the server
+	// start, or the latest reset of the database or background writer statistics
+	statsStartTimeQuery = `SELECT GREATEST(pg_postmaster_start_time(),
+		(SELECT MAX(stats_reset) FROM pg_stat_database),
+		(SELECT stats_reset FROM pg_stat_bgwriter)) AS stats_start_time`
+	// customQueryName is the definition name of custom queries for their timeout
+	customQueryName = "custom"
 )
 
+// collectionConcurrency is the number of databases whose tables and indexes are collected in parallel
+var collectionConcurrency = 1
+
+// SetConcurrency sets the number of databases collected in parallel. It must be called before the collection starts.
+func SetConcurrency(concurrency int) {
+	collectionConcurrency = concurrency
+}
+
 // PopulateMetrics collects metrics for each type
 func PopulateMetrics(
+	ctx context.Context,
 	ci connection.Info,
 	databaseList collection.DatabaseList,
 	instance *integration.Entity,
@@ -38,37 +56,51 @@ func PopulateMetrics(
 	}
 	defer con.Close()
 
-	// Pass background context as PopulateMetrics doesn't have a specific one
-	version, err := CollectVersion(context.Background(), con)
+	version, err := CollectVersion(ctx, con)
 	if err != nil {
 		log.Error("Metrics collection failed: error collecting version number: %s", err.Error())
 		return
 	}
 
-	PopulateInstanceMetrics(instance, version, con)
-	PopulateDatabaseMetrics(databaseList, version, i, con, ci)
+	PopulateMetricsForVersion(ctx, ci, con, version, databaseList, instance, i, collectPgBouncer, collectDbLocks, collectBloat, customMetricsQuery)
+}
+
+// PopulateMetricsForVersion collects metrics for each type from a server whose version is already known
+func PopulateMetricsForVersion(
+	ctx context.Context,
+	ci connection.Info,
+	con *connection.PGSQLConnection,
+	version *semver.Version,
+	databaseList collection.DatabaseList,
+	instance *integration.Entity,
+	i *integration.Integration,
+	collectPgBouncer, collectDbLocks, collectBloat bool,
+	customMetricsQuery string) {
+
+	PopulateInstanceMetrics(ctx, instance, version, con)
+	PopulateDatabaseMetrics(ctx, databaseList, version, i, con, ci)
 	if collectDbLocks {
-		PopulateDatabaseLockMetrics(databaseList, version, i, con, ci)
+		PopulateDatabaseLockMetrics(ctx, databaseList, version, i, con, ci)
 	}
-	PopulateTableMetrics(databaseList, version, i, ci, collectBloat)
-	PopulateIndexMetrics(databaseList, i, ci)
+	PopulateTableMetrics(ctx, databaseList, version, i, ci, collectBloat)
+	PopulateIndexMetrics(ctx, databaseList, version, i, ci)
 	if customMetricsQuery != "" {
-		PopulateCustomMetrics(customMetricsQuery, i, con, ci, instance)
+		PopulateCustomMetrics(ctx, customMetricsQuery, i, con, ci, instance)
 	}
 
 	if collectPgBouncer {
-		con, err = ci.NewConnection("pgbouncer")
+		pgBouncerCon, err := ci.NewConnection("pgbouncer")
 		if err != nil {
 			log.Error("Error creating connection to pgbouncer database: %s", err)
 		} else {
-			defer con.Close()
-			PopulatePgBouncerMetrics(i, con, ci)
+			defer pgBouncerCon.Close()
+			PopulatePgBouncerMetrics(ctx, i, pgBouncerCon, ci)
 		}
 	}
 }
 
 // PopulateCustomMetricsFromFile collects metrics defined by a custom config file
-func PopulateCustomMetricsFromFile(ci connection.Info, configFile string, psqlIntegration *integration.Integration) {
+func PopulateCustomMetricsFromFile(ctx context.Context, ci connection.Info, configFile string, psqlIntegration *integration.Integration) {
 	contents, err := ioutil.ReadFile(configFile)
 	if err != nil {
 		log.Error("Failed to read custom config file: %s", err)
@@ -82,26 +114,18 @@ func PopulateCustomMetricsFromFile(ci connection.Info, configFile string, psqlIn
 		return
 	}
 
-	// Semaphore to run 10 custom queries concurrently
-	sem := make(chan struct{}, 10)
-	wg := sync.WaitGroup{}
+	// Run 10 custom queries concurrently
+	queryScheduler := scheduler.New(10)
 	for _, config := range customYAML.Queries {
-		sem <- struct{}{}
-		wg.Add(1)
-		go func(cfg customMetricsConfig) {
-			defer wg.Done()
-			defer func() {
-				<-sem
-			}()
-
-			CollectCustomConfig(ci, cfg, psqlIntegration)
-		}(config)
+		queryScheduler.Go(func() {
+			CollectCustomConfig(ctx, ci, config, psqlIntegration)
+		})
 	}
-	wg.Wait()
+	queryScheduler.Wait()
 }
 
 // CollectCustomConfig collects metrics defined by a custom config
-func CollectCustomConfig(ci connection.Info, cfg customMetricsConfig, pgIntegration *integration.Integration) {
+func CollectCustomConfig(ctx context.Context, ci connection.Info, cfg customMetricsConfig, pgIntegration *integration.Integration) {
 	dbName := func() string {
 		if cfg.Database == "" {
 			return ci.DatabaseName()
@@ -116,14 +140,11 @@ func CollectCustomConfig(ci connection.Info, cfg customMetricsConfig, pgIntegrat
 	}
 	defer con.Close()
 
-	rows, err := con.Queryx(cfg.Query)
+	rows, err := queryMaps(ctx, con, customQueryName, customQueryName, cfg.Query)
 	if err != nil {
 		log.Error("Could not execute database query: %s", err.Error())
 		return
 	}
-	defer func() {
-		_ = rows.Close()
-	}()
 
 	host, port := ci.HostPort()
 	hostIDAttribute := integration.NewIDAttribute("host", host)
@@ -140,14 +161,7 @@ func CollectCustomConfig(ci connection.Info, cfg customMetricsConfig, pgIntegrat
 		return cfg.SampleName
 	}()
 
-	for rows.Next() {
-		row := make(map[string]interface{})
-		err := rows.MapScan(row)
-		if err != nil {
-			log.Error("Failed to scan custom query row: %s", err)
-			return
-		}
-
+	for _, row := range rows {
 		ms := databaseEntity.NewMetricSet(sampleName, attribute.Attribute{
 			Key: "database", Value: dbName,
 		})
@@ -221,6 +235,22 @@ type customMetricsConfig struct {
 	SampleName  string                `yaml:"sample_name"`
 }
 
+type statsStartTimeRow struct {
+	StartTime time.Time `db:"stats_start_time"`
+}
+
+// CollectStatsStartTime collects the time since which the cumulative counters of the server count
+func CollectStatsStartTime(ctx context.Context, connection *connection.PGSQLConnection) (time.Time, error) {
+	var startTimeRows []*statsStartTimeRow
+	if err := connection.QueryContext(ctx, &startTimeRows, statsStartTimeQuery); err != nil {
+		return time.Time{}, err
+	}
+	if len(startTimeRows) == 0 {
+		return time.Time{}, fmt.Errorf("no statistics start time returned")
+	}
+	return startTimeRows[0].StartTime, nil
+}
+
 type serverVersionRow struct {
 	Version string `db:"server_version"`
 }
@@ -263,44 +293,40 @@ func CollectVersion(ctx context.Context, connection *connection.PGSQLConnection)
 //}
 
 // PopulateInstanceMetrics populates the metrics for an instance
-func PopulateInstanceMetrics(instanceEntity *integration.Entity, version *semver.Version, connection *connection.PGSQLConnection) {
+func PopulateInstanceMetrics(ctx context.Context, instanceEntity *integration.Entity, version *semver.Version, connection *connection.PGSQLConnection) {
 	metricSet := instanceEntity.NewMetricSet("PostgresqlInstanceSample",
 		attribute.Attribute{Key: "displayName", Value: instanceEntity.Metadata.Name},
 		attribute.Attribute{Key: "entityName", Value: instanceEntity.Metadata.Namespace + ":" + instanceEntity.Metadata.Name},
 	)
 
 	for _, queryDef := range generateInstanceDefinitions(version) {
-		dataModels := queryDef.GetDataModels()
-		if err := connection.Query(dataModels, queryDef.GetQuery()); err != nil {
+		rows, err := queryDefinition(ctx, connection, queryDef)
+		if err != nil {
 			log.Error("Could not execute instance query: %s", err.Error())
 			continue
 		}
 
-		vp := reflect.Indirect(reflect.ValueOf(dataModels))
-
 		// Nothing was returned
-		if vp.Len() == 0 {
+		if len(rows) == 0 {
 			log.Debug("No data returned from instance query '%s'", queryDef.GetQuery())
 			continue
 		}
 
-		vpInterface := vp.Index(0).Interface()
-		err := metricSet.MarshalMetrics(vpInterface)
-		if err != nil {
+		if err := rows[0].populateMetricSet(metricSet); err != nil {
 			log.Error("Could not parse metrics from instance query result: %s", err.Error())
 		}
 	}
 }
 
 // PopulateDatabaseMetrics populates the metrics for a database
-func PopulateDatabaseMetrics(databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
+func PopulateDatabaseMetrics(ctx context.Context, databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
 	databaseDefinitions := generateDatabaseDefinitions(databases, version)
-	processDatabaseDefinitions(databaseDefinitions, pgIntegration, connection, ci)
+	processDatabaseDefinitions(ctx, databaseDefinitions, pgIntegration, connection, ci)
 }
 
 // PopulateDatabaseLockMetrics populates the lock metrics for a database
-func PopulateDatabaseLockMetrics(databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
-	if !connection.HaveExtensionInSchema("tablefunc", "public") {
+func PopulateDatabaseLockMetrics(ctx context.Context, databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
+	if !connection.HaveExtensionInSchema(ctx, "tablefunc", "public") {
 		log.Warn("Crosstab function not available; database lock metric gathering not possible.")
 		log.Warn("To enable database lock metrics, enable the 'tablefunc' extension on the public")
 		log.Warn("schema of your database. You can do so by:")
@@ -309,24 +335,21 @@ func PopulateDatabaseLockMetrics(databases collection.DatabaseList, version *sem
 		return
 	}
 
-	lockDefinitions := generateLockDefinitions(databases)
+	lockDefinitions := generateLockDefinitions(databases, version)
 
-	processDatabaseDefinitions(lockDefinitions, pgIntegration, connection, ci)
+	processDatabaseDefinitions(ctx, lockDefinitions, pgIntegration, connection, ci)
 }
 
-func processDatabaseDefinitions(definitions []*QueryDefinition, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
+func processDatabaseDefinitions(ctx context.Context, definitions []*QueryDefinition, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
 	for _, queryDef := range definitions {
-		// collect into model
-		dataModels := queryDef.GetDataModels()
-		if err := connection.Query(dataModels, queryDef.GetQuery()); err != nil {
+		rows, err := queryDefinition(ctx, connection, queryDef)
+		if err != nil {
 			log.Error("Could not execute database query: %s", err.Error())
 			continue
 		}
 
 		// for each row in the response
-		v := reflect.Indirect(reflect.ValueOf(dataModels))
-		for i := 0; i < v.Len(); i++ {
-			db := v.Index(i).Interface()
+		for _, db := range rows {
 			name, err := GetDatabaseName(db)
 			if err != nil {
 				log.Error("Unable to get database name: %s", err.Error())
@@ -344,7 +367,7 @@ func processDatabaseDefinitions(definitions []*QueryDefinition, pgIntegration *i
 				attribute.Attribute{Key: "entityName", Value: "database:" + databaseEntity.Metadata.Name},
 			)
 
-			if err := metricSet.MarshalMetrics(db); err != nil {
+			if err := db.populateMetricSet(metricSet); err != nil {
 				log.Error("Failed to database entity with metrics: %s", err.Error())
 			}
 
@@ -353,39 +376,63 @@ func processDatabaseDefinitions(definitions []*QueryDefinition, pgIntegration *i
 }
 
 // PopulateTableMetrics populates the metrics for a table
-func PopulateTableMetrics(databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, ci connection.Info, collectBloat bool) {
+func PopulateTableMetrics(ctx context.Context, databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, ci connection.Info, collectBloat bool) {
+	databaseScheduler := scheduler.New(collectionConcurrency)
 	for database, schemaList := range databases {
 		if len(schemaList) == 0 {
-			return
+			continue
 		}
 
-		// Create a new connection to the database
-		con, err := ci.NewConnection(database)
-		if err != nil {
-			log.Error("Failed to connect to database %s: %s", database, err.Error())
+		databaseScheduler.Go(func() {
+			// Create a new connection to the database
+			con, err := ci.NewConnection(database)
+			if err != nil {
+				log.Error("Failed to connect to database %s: %s", database, err.Error())
+				return
+			}
+			defer con.Close()
+			populateTableMetricsForDatabase(ctx, schemaList, version, con, pgIntegration, ci, collectBloat)
+		})
+	}
+	databaseScheduler.Wait()
+}
+
+// PopulateTableBloatMetrics populates the bloat metrics for a table
+func PopulateTableBloatMetrics(ctx context.Context, databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, ci connection.Info) {
+	databaseScheduler := scheduler.New(collectionConcurrency)
+	for database, schemaList := range databases {
+		if len(schemaList) == 0 {
 			continue
 		}
-		defer con.Close()
-		populateTableMetricsForDatabase(schemaList, version, con, pgIntegration, ci, collectBloat)
+
+		databaseScheduler.Go(func() {
+			con, err := ci.NewConnection(database)
+			if err != nil {
+				log.Error("Failed to connect to database %s: %s", database, err.Error())
+				return
+			}
+			defer con.Close()
+			populateTableDefinitions(ctx, generateTableBloatDefinitions(schemaList, version), con, pgIntegration, ci)
+		})
 	}
+	databaseScheduler.Wait()
 }
 
-func populateTableMetricsForDatabase(schemaList collection.SchemaList, version *semver.Version, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info, collectBloat bool) {
-	tableDefinitions := generateTableDefinitions(schemaList, version, collectBloat)
+func populateTableMetricsForDatabase(ctx context.Context, schemaList collection.SchemaList, version *semver.Version, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info, collectBloat bool) {
+	populateTableDefinitions(ctx, generateTableDefinitions(schemaList, version, collectBloat), con, pgIntegration, ci)
+}
 
-	// collect into model
+func populateTableDefinitions(ctx context.Context, tableDefinitions []*QueryDefinition, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info) {
 	for _, definition := range tableDefinitions {
 
-		dataModels := definition.GetDataModels()
-		if err := con.Query(dataModels, definition.GetQuery()); err != nil {
+		rows, err := queryDefinition(ctx, con, definition)
+		if err != nil {
 			log.Error("Could not execute table query: %s", err.Error())
 			return
 		}
 
 		// for each row in the response
-		v := reflect.Indirect(reflect.ValueOf(dataModels))
-		for i := 0; i < v.Len(); i++ {
-			row := v.Index(i).Interface()
+		for _, row := range rows {
 			dbName, err := GetDatabaseName(row)
 			if err != nil {
 				log.Error("Unable to get database name: %s", err.Error())
@@ -415,7 +462,7 @@ func populateTableMetricsForDatabase(schemaList collection.SchemaList, version *
 				attribute.Attribute{Key: "schema", Value: schemaName},
 			)
 
-			if err := metricSet.MarshalMetrics(row); err != nil {
+			if err := row.populateMetricSet(metricSet); err != nil {
 				log.Error("Failed to populate table entity with metrics: %s", err.Error())
 			}
 
@@ -424,34 +471,35 @@ func populateTableMetricsForDatabase(schemaList collection.SchemaList, version *
 }
 
 // PopulateIndexMetrics populates the metrics for an index
-func PopulateIndexMetrics(databases collection.DatabaseList, pgIntegration *integration.Integration, ci connection.Info) {
+func PopulateIndexMetrics(ctx context.Context, databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, ci connection.Info) {
+	databaseScheduler := scheduler.New(collectionConcurrency)
 	for database, schemaList := range databases {
-		con, err := ci.NewConnection(database)
-		if err != nil {
-			log.Error("Failed to create new connection to database %s: %s", database, err.Error())
-			continue
-		}
-		defer con.Close()
-		populateIndexMetricsForDatabase(schemaList, con, pgIntegration, ci)
+		databaseScheduler.Go(func() {
+			con, err := ci.NewConnection(database)
+			if err != nil {
+				log.Error("Failed to create new connection to database %s: %s", database, err.Error())
+				return
+			}
+			defer con.Close()
+			populateIndexMetricsForDatabase(ctx, schemaList, version, con, pgIntegration, ci)
+		})
 	}
+	databaseScheduler.Wait()
 }
 
-func populateIndexMetricsForDatabase(schemaList collection.SchemaList, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info) {
-	indexDefinitions := generateIndexDefinitions(schemaList)
+func populateIndexMetricsForDatabase(ctx context.Context, schemaList collection.SchemaList, version *semver.Version, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info) {
+	indexDefinitions := generateIndexDefinitions(schemaList, version)
 
 	for _, definition := range indexDefinitions {
 
-		// collect into model
-		dataModels := definition.GetDataModels()
-		if err := con.Query(dataModels, definition.GetQuery()); err != nil {
+		rows, err := queryDefinition(ctx, con, definition)
+		if err != nil {
 			log.Error("Could not execute index query: %s", err.Error())
 			return
 		}
 
 		// for each row in the response
-		v := reflect.Indirect(reflect.ValueOf(dataModels))
-		for i := 0; i < v.Len(); i++ {
-			row := v.Index(i).Interface()
+		for _, row := range rows {
 			dbName, err := GetDatabaseName(row)
 			if err != nil {
 				log.Error("Unable to get database name: %s", err.Error())
@@ -487,7 +535,7 @@ func populateIndexMetricsForDatabase(schemaList collection.SchemaList, con *conn
 				attribute.Attribute{Key: "table", Value: tableName},
 			)
 
-			if err := metricSet.MarshalMetrics(row); err != nil {
+			if err := row.populateMetricSet(metricSet); err != nil {
 				log.Error("Failed to populate index entity with metrics: %s", err.Error())
 			}
 
@@ -497,20 +545,18 @@ func populateIndexMetricsForDatabase(schemaList collection.SchemaList, con *conn
 }
 
 // PopulatePgBouncerMetrics populates pgbouncer metrics
-func PopulatePgBouncerMetrics(pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info) {
+func PopulatePgBouncerMetrics(ctx context.Context, pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info) {
 	pgbouncerDefs := generatePgBouncerDefinitions()
 
 	for _, definition := range pgbouncerDefs {
-		dataModels := definition.GetDataModels()
-		if err := con.Query(dataModels, definition.GetQuery()); err != nil {
+		rows, err := queryDefinition(ctx, con, definition)
+		if err != nil {
 			log.Error("Could not execute index query: %s", err.Error())
 			return
 		}
 
 		// for each row in the response
-		v := reflect.Indirect(reflect.ValueOf(dataModels))
-		for i := 0; i < v.Len(); i++ {
-			db := v.Index(i).Interface()
+		for _, db := range rows {
 			name, err := GetDatabaseName(db)
 			if err != nil {
 				log.Error("Unable to get database name: %s", err.Error())
@@ -530,7 +576,7 @@ func PopulatePgBouncerMetrics(pgIntegration *integration.Integration, con *conne
 				attribute.Attribute{Key: "host", Value: host},
 			)
 
-			if err := metricSet.MarshalMetrics(db); err != nil {
+			if err := db.populateMetricSet(metricSet); err != nil {
 				log.Error("Failed to populate pgbouncer entity with metrics: %s", err.Error())
 			}
 		}
@@ -538,24 +584,14 @@ func PopulatePgBouncerMetrics(pgIntegration *integration.Integration, con *conne
 }
 
 // PopulateCustomMetrics collects metrics from a custom query
-func PopulateCustomMetrics(customMetricsQuery string, pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info, instance *integration.Entity) {
-	rows, err := con.Queryx(customMetricsQuery)
+func PopulateCustomMetrics(ctx context.Context, customMetricsQuery string, pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info, instance *integration.Entity) {
+	rows, err := queryMaps(ctx, con, customQueryName, customQueryName, customMetricsQuery)
 	if err != nil {
 		log.Error("Could not execute database query: %s", err.Error())
 		return
 	}
-	defer func() {
-		_ = rows.Close()
-	}()
-
-	for rows.Next() {
-		row := make(map[string]interface{})
-		err := rows.MapScan(row)
-		if err != nil {
-			log.Error("Failed to scan custom query row: %s", err)
-			return
-		}
 
+	for _, row := range rows {
 		nameInterface, ok := row["metric_name"]
 		if !ok {
 			log.Error("Missing required column 'metric_name' in custom query")
@@ -619,3 +655,49 @@ func PopulateCustomMetrics(customMetricsQuery string, pgIntegration *integration
 		}
 	}
 }
+
+// queryDefinition returns the rows of the query of definition, read within the definition timeout. It returns no
+// rows when con lacks a capability the definition requires.
+func queryDefinition(ctx context.Context, con *connection.PGSQLConnection, definition *QueryDefinition) ([]Row, error) {
+	if capability, missing := capabilities.Missing(ctx, con, definition.requires); missing {
+		log.Debug("Skipping %s query: %s is missing", definition.GetName(), capability)
+		return nil, nil
+	}
+	columns, err := queryMaps(ctx, con, definition.GetName(), definition.definitionType, definition.GetQuery())
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, 0, len(columns))
+	for _, row := range columns {
+		rows = append(rows, Row{Columns: row, Metrics: definition.GetMetrics()})
+	}
+	return rows, nil
+}
+
+// queryMaps returns the rows of query as column maps, read within the timeout of the named definition of
+// definitionType
+func queryMaps(ctx context.Context, con *connection.PGSQLConnection, name, definitionType string, query string) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := withQueryTimeout(ctx, name, definitionType, func(ctx context.Context) error {
+		result, err := con.QueryxContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = result.Close()
+		}()
+		for result.Next() {
+			row := make(map[string]interface{})
+			if err := result.MapScan(row); err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+		if err := result.Err(); err != nil {
+			return err
+		}
+		selfmetrics.FromContext(ctx).AddRows(name, len(rows))
+		return nil
+	})
+	return rows, err
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -34,7 +35,7 @@ func TestPopulateInstanceMetrics(t *testing.T) {
 	mock.ExpectQuery(".*scheduled_checkpoints_performed.*").
 		WillReturnRows(instanceRows)
 
-	PopulateInstanceMetrics(testEntity, &version, testConnection)
+	PopulateInstanceMetrics(context.Background(), testEntity, &version, testConnection)
 
 	expected := map[string]interface{}{
 		"bgwriter.checkpointsScheduledPerSecond":             float64(0),
@@ -72,7 +73,7 @@ func TestPopulateInstanceMetrics_NoRows(t *testing.T) {
 	mock.ExpectQuery(".*scheduled_checkpoints_performed.*").
 		WillReturnRows(instanceRows)
 
-	PopulateInstanceMetrics(testEntity, &version, testConnection)
+	PopulateInstanceMetrics(context.Background(), testEntity, &version, testConnection)
 
 	expected := map[string]interface{}{
 		"displayName": "testInstance",
@@ -108,7 +109,7 @@ func TestPopulateDatabaseMetrics(t *testing.T) {
 		WillReturnRows(databaseRows)
 
 	ci := &connection.MockInfo{}
-	PopulateDatabaseMetrics(dbList, &version, testIntegration, testConnection, ci)
+	PopulateDatabaseMetrics(context.Background(), dbList, &version, testIntegration, testConnection, ci)
 
 	expected := map[string]interface{}{
 
@@ -160,7 +161,7 @@ func TestPopulateDatabaseLockMetrics_WithTablefuncExtension(t *testing.T) {
 	mock.ExpectQuery(".*LOCKS_DEFINITION.*").WillReturnRows(lockRows)
 
 	ci := &connection.MockInfo{}
-	PopulateDatabaseLockMetrics(dbList, &version, testIntegration, testConnection, ci)
+	PopulateDatabaseLockMetrics(context.Background(), dbList, &version, testIntegration, testConnection, ci)
 
 	expected := map[string]interface{}{
 		"db.locks.accessExclusiveLock":      float64(1),
@@ -193,7 +194,7 @@ func TestPopulateDatabaseLockMetrics_WithoutTablefuncExtension(t *testing.T) {
 	mock.ExpectQuery(".*EXTENSIONS_LIST.*").WillReturnRows(extensionRows)
 
 	ci := &connection.MockInfo{}
-	PopulateDatabaseLockMetrics(dbList, &version, testIntegration, testConnection, ci)
+	PopulateDatabaseLockMetrics(context.Background(), dbList, &version, testIntegration, testConnection, ci)
 	dbEntity, err := testIntegration.Entity("testDB", "pg-database", integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"))
 
 	assert.Nil(t, err)
@@ -253,7 +254,7 @@ func Test_populateTableMetricsForDatabase(t *testing.T) {
 
 	ci := &connection.MockInfo{}
 	version := semver.MustParse("12.0.0")
-	populateTableMetricsForDatabase(dbList["db1"], &version, testConnection, testIntegration, ci, true)
+	populateTableMetricsForDatabase(context.Background(), dbList["db1"], &version, testConnection, testIntegration, ci, true)
 
 	expectedBase := map[string]interface{}{
 		"table.totalSizeInBytes":                   float64(1),
@@ -316,7 +317,7 @@ func TestPopulateTableMetricsForDatabaseNoTables(t *testing.T) {
 
 	ci := &connection.MockInfo{}
 	version := semver.MustParse("10.0.0")
-	populateTableMetricsForDatabase(dbList["db1"], &version, testConnection, testIntegration, ci, true)
+	populateTableMetricsForDatabase(context.Background(), dbList["db1"], &version, testConnection, testIntegration, ci, true)
 
 	tableEntity, err := testIntegration.Entity("table1", "table")
 	assert.Nil(t, err)
@@ -369,8 +370,9 @@ func TestPopulateIndexMetricsForDatabase(t *testing.T) {
 		WillReturnRows(indexRows2)
 
 	ci := &connection.MockInfo{}
-	populateIndexMetricsForDatabase(dbList["db1"], testConnection, testIntegration, ci)
-	populateIndexMetricsForDatabase(dbList["db2"], testConnection, testIntegration, ci)
+	version := semver.MustParse("12.0.0")
+	populateIndexMetricsForDatabase(context.Background(), dbList["db1"], &version, testConnection, testIntegration, ci)
+	populateIndexMetricsForDatabase(context.Background(), dbList["db2"], &version, testConnection, testIntegration, ci)
 
 	expected := map[string]interface{}{
 		"database":                   "db1",
@@ -413,6 +415,44 @@ func TestPopulateIndexMetricsForDatabase(t *testing.T) {
 	assert.Equal(t, expected2, indexEntity2.Metrics[0].Metrics)
 }
 
+func TestPopulateIndexMetrics_Parallel(t *testing.T) {
+	defer SetConcurrency(1)
+	SetConcurrency(3)
+
+	testIntegration, _ := integration.New("test", "test")
+	dbList := collection.DatabaseList{}
+	ci := &connection.MockInfo{}
+	mocks := map[string]sqlmock.Sqlmock{}
+	for _, database := range []string{"db1", "db2", "db3", "db4"} {
+		dbList[database] = collection.SchemaList{
+			"schema1": collection.TableList{
+				"table1": []string{"index1"},
+			},
+		}
+		testConnection, mock := connection.CreateMockSQL(t)
+		mock.ExpectQuery(".*INDEXQUERY.*").
+			WillReturnRows(sqlmock.NewRows([]string{
+				"database",
+				"schema_name",
+				"table_name",
+				"index_name",
+				"index_size",
+				"tuples_read",
+				"tuples_fetched",
+			}).AddRow(database, "schema1", "table1", "index1", 1, 2, 3))
+		ci.On("NewConnection", database).Return(testConnection, nil)
+		mocks[database] = mock
+	}
+
+	version := semver.MustParse("12.0.0")
+	PopulateIndexMetrics(context.Background(), dbList, &version, testIntegration, ci)
+
+	assert.Len(t, testIntegration.Entities, 4)
+	for database, mock := range mocks {
+		assert.NoError(t, mock.ExpectationsWereMet(), database)
+	}
+}
+
 func TestPopulateIndexMetricsForDatabaseNoIndexes(t *testing.T) {
 	testIntegration, _ := integration.New("test", "test")
 
@@ -427,7 +467,8 @@ func TestPopulateIndexMetricsForDatabaseNoIndexes(t *testing.T) {
 	testConnection, _ := connection.CreateMockSQL(t)
 
 	ci := &connection.MockInfo{}
-	populateIndexMetricsForDatabase(dbList["db1"], testConnection, testIntegration, ci)
+	version := semver.MustParse("12.0.0")
+	populateIndexMetricsForDatabase(context.Background(), dbList["db1"], &version, testConnection, testIntegration, ci)
 
 	indexEntity, err := testIntegration.Entity("index1", "index")
 	assert.Nil(t, err)
@@ -692,7 +733,7 @@ func TestPopulatePgBouncerMetrics(t *testing.T) {
 				WillReturnRows(testCase.pgbouncerPoolsRows)
 
 			ci := &connection.MockInfo{}
-			PopulatePgBouncerMetrics(testIntegration, testConnection, ci)
+			PopulatePgBouncerMetrics(context.Background(), testIntegration, testConnection, ci)
 
 			id3 := integration.NewIDAttribute("host", "testhost")
 			id4 := integration.NewIDAttribute("port", "1234")
@@ -729,7 +770,7 @@ func TestPopulateMetrics(t *testing.T) {
 
 	instance, _ := testIntegration.Entity("testInstance", "instance")
 
-	PopulateMetrics(ci, dbList, instance, testIntegration, true, true, true, "")
+	PopulateMetrics(context.Background(), ci, dbList, instance, testIntegration, true, true, true, "")
 }
 
 func TestPopulateCustomMetricsFromFile(t *testing.T) {
@@ -760,7 +801,7 @@ queries:
 `)
 	assert.NoError(t, os.WriteFile(filepath.Join(dir, "customQueryConfig.yaml"), customQueryCfg, 0600))
 
-	PopulateCustomMetricsFromFile(ci, filepath.Join(dir, "customQueryConfig.yaml"), testIntegration)
+	PopulateCustomMetricsFromFile(context.Background(), ci, filepath.Join(dir, "customQueryConfig.yaml"), testIntegration)
 
 	assert.Len(t, testIntegration.Entities, 1)
 	assert.Len(t, testIntegration.Entities[0].Metrics, 1)
@@ -770,3 +811,56 @@ queries:
 	assert.Equal(t, float64(0.064), metricSet["float_metric"])
 	assert.Equal(t, "test-string", metricSet["string_metric"])
 }
+
+func TestPopulateSamples(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	instance, _ := testIntegration.Entity("testhost:1234", "pg-instance")
+	ids := func() map[string]string {
+		return map[string]string{"host": "testhost", "port": "1234"}
+	}
+	samples := []Sample{
+		{Namespace: "pg-instance", IDAttributes: ids(), Row: Row{
+			Columns: map[string]interface{}{"max_connections": int64(100)},
+			Metrics: []MetricColumn{{Column: "max_connections", MetricName: "instance.maxConnections", SourceType: "gauge"}},
+		}},
+		newSample("pg-database", ids(), Row{
+			Columns: map[string]interface{}{"database": "testDB", "active_connections": int64(1)},
+			Metrics: []MetricColumn{{Column: "active_connections", MetricName: "db.connections", SourceType: "gauge"}},
+		}),
+		newSample("pg-table", ids(), Row{
+			Columns: map[string]interface{}{"database": "testDB", "schema_name": "public", "table_name": "orders", "live_rows": int64(10)},
+			Metrics: []MetricColumn{{Column: "live_rows", MetricName: "table.liveRows", SourceType: "gauge"}},
+		}),
+	}
+
+	PopulateSamples(samples, instance, testIntegration)
+
+	assert.Equal(t, map[string]interface{}{
+		"instance.maxConnections": float64(100),
+		"displayName":             "testhost:1234",
+		"entityName":              "pg-instance:testhost:1234",
+		"event_type":              "PostgresqlInstanceSample",
+	}, instance.Metrics[0].Metrics)
+
+	dbEntity, err := testIntegration.Entity("testDB", "pg-database", integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"db.connections": float64(1),
+		"displayName":    "testDB",
+		"entityName":     "database:testDB",
+		"event_type":     "PostgresqlDatabaseSample",
+	}, dbEntity.Metrics[0].Metrics)
+
+	tableEntity, err := testIntegration.Entity("orders", "pg-table",
+		integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"),
+		integration.NewIDAttribute("pg-database", "testDB"), integration.NewIDAttribute("pg-schema", "public"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"table.liveRows": float64(10),
+		"displayName":    "orders",
+		"entityName":     "table:orders",
+		"database":       "testDB",
+		"schema":         "public",
+		"event_type":     "PostgresqlTableSample",
+	}, tableEntity.Metrics[0].Metrics)
+}
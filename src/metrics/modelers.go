@@ -10,18 +10,6 @@ type DatabaseModeler interface {
 	GetDatabaseName() (string, error)
 }
 
-type databaseBase struct {
-	Database *string `db:"database"`
-}
-
-// GetDatabaseName returns the database name for the object
-func (d databaseBase) GetDatabaseName() (string, error) {
-	if d.Database == nil {
-		return "", errors.New("database name not returned")
-	}
-	return *d.Database, nil
-}
-
 // GetDatabaseName returns the database name for the object
 func GetDatabaseName(dataModel interface{}) (string, error) {
 	v := reflect.ValueOf(dataModel)
@@ -43,18 +31,6 @@ type SchemaModeler interface {
 	GetSchemaName() (string, error)
 }
 
-type schemaBase struct {
-	Schema *string `db:"schema_name"`
-}
-
-// GetSchemaName returns a schema name
-func (d schemaBase) GetSchemaName() (string, error) {
-	if d.Schema == nil {
-		return "", errors.New("schema name not returned")
-	}
-	return *d.Schema, nil
-}
-
 // GetSchemaName returns a schema name
 func GetSchemaName(dataModel interface{}) (string, error) {
 	v := reflect.ValueOf(dataModel)
@@ -76,18 +52,6 @@ type TableModeler interface {
 	GetTableName() (string, error)
 }
 
-type tableBase struct {
-	Table *string `db:"table_name"`
-}
-
-// GetTableName returns the table name
-func (d tableBase) GetTableName() (string, error) {
-	if d.Table == nil {
-		return "", errors.New("table name not returned")
-	}
-	return *d.Table, nil
-}
-
 // GetTableName returns the table name
 func GetTableName(dataModel interface{}) (string, error) {
 	v := reflect.ValueOf(dataModel)
@@ -109,18 +73,6 @@ type IndexModeler interface {
 	GetIndexName() (string, error)
 }
 
-type indexBase struct {
-	Index *string `db:"index_name"`
-}
-
-// GetIndexName returns the index name
-func (d indexBase) GetIndexName() (string, error) {
-	if d.Index == nil {
-		return "", errors.New("index name not returned")
-	}
-	return *d.Index, nil
-}
-
 // GetIndexName returns the index name
 func GetIndexName(dataModel interface{}) (string, error) {
 	v := reflect.ValueOf(dataModel)
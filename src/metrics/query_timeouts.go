@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
+)
+
+// DefaultQueryTimeout applies to the definitions without a configured timeout
+const DefaultQueryTimeout = 30 * time.Second
+
+var (
+	// ErrQueryTimeout is returned when a query runs longer than the timeout of its definition
+	ErrQueryTimeout = errors.New("query timeout exceeded")
+	// ErrRunDeadlineExceeded is returned when a query is cancelled because the run deadline has passed
+	ErrRunDeadlineExceeded = errors.New("run deadline exceeded")
+)
+
+var (
+	queryTimeoutsMu sync.RWMutex
+	defaultTimeout  = DefaultQueryTimeout
+	queryTimeouts   = map[string]time.Duration{}
+)
+
+// SetQueryTimeouts sets the timeout of every query to defaultSeconds, overridden per definition name or type by the
+// JSON object overrides, for example {"bloat": 60, "instance_io_v17": 5}. Invalid values are skipped with a warning.
+func SetQueryTimeouts(defaultSeconds int, overrides string) {
+	queryTimeoutsMu.Lock()
+	defer queryTimeoutsMu.Unlock()
+
+	defaultTimeout = DefaultQueryTimeout
+	if defaultSeconds > 0 {
+		defaultTimeout = time.Duration(defaultSeconds) * time.Second
+	} else {
+		log.Warn("invalid query timeout %d, using default %s", defaultSeconds, DefaultQueryTimeout)
+	}
+
+	queryTimeouts = map[string]time.Duration{}
+	if overrides == "" {
+		return
+	}
+	var secondsByName map[string]int
+	if err := json.Unmarshal([]byte(overrides), &secondsByName); err != nil {
+		log.Warn("invalid query timeouts %s: %v", overrides, err)
+		return
+	}
+	for name, seconds := range secondsByName {
+		if seconds <= 0 {
+			log.Warn("invalid query timeout %d for %s, using default %s", seconds, name, defaultTimeout)
+			continue
+		}
+		queryTimeouts[name] = time.Duration(seconds) * time.Second
+	}
+}
+
+// queryTimeout returns the timeout of the queries of the named definition, or else of its type
+func queryTimeout(name, definitionType string) time.Duration {
+	queryTimeoutsMu.RLock()
+	defer queryTimeoutsMu.RUnlock()
+	if timeout, ok := queryTimeouts[name]; ok {
+		return timeout
+	}
+	if timeout, ok := queryTimeouts[definitionType]; ok {
+		return timeout
+	}
+	return defaultTimeout
+}
+
+// withQueryTimeout runs query with a context bounded by the timeout of the named definition of definitionType.
+// Timeouts are returned as ErrQueryTimeout, or ErrRunDeadlineExceeded when ctx itself has expired. The duration and
+// outcome are recorded in the self-metrics of the definition.
+func withQueryTimeout(ctx context.Context, name, definitionType string, query func(ctx context.Context) error) error {
+	timeout := queryTimeout(name, definitionType)
+	queryCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := selfmetrics.FromContext(ctx)
+	start := time.Now()
+	err := query(queryCtx)
+	stats.Observe(name, time.Since(start))
+	if err == nil {
+		return nil
+	}
+	switch {
+	case ctx.Err() != nil:
+		stats.RecordTimeout(name)
+		return fmt.Errorf("%w: %s query cancelled: %v", ErrRunDeadlineExceeded, name, err)
+	case errors.Is(queryCtx.Err(), context.DeadlineExceeded):
+		stats.RecordTimeout(name)
+		return fmt.Errorf("%w: %s query did not finish within %s: %v", ErrQueryTimeout, name, timeout, err)
+	}
+	stats.RecordError(name, err)
+	return err
+}
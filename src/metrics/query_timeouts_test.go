@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetQueryTimeouts(t *testing.T) {
+	defer SetQueryTimeouts(int(DefaultQueryTimeout.Seconds()), "")
+
+	SetQueryTimeouts(10, `{"bloat": 60, "table_bloat_v12": 90, "custom": 0}`)
+	assert.Equal(t, 60*time.Second, queryTimeout("table_bloat", "bloat"))
+	assert.Equal(t, 90*time.Second, queryTimeout("table_bloat_v12", "bloat"), "the name takes precedence over the type")
+	assert.Equal(t, 10*time.Second, queryTimeout("custom", "custom"))
+	assert.Equal(t, 10*time.Second, queryTimeout("table", "table"))
+
+	SetQueryTimeouts(0, "not json")
+	assert.Equal(t, DefaultQueryTimeout, queryTimeout("table_bloat", "bloat"))
+}
+
+func TestWithQueryTimeout(t *testing.T) {
+	defer SetQueryTimeouts(int(DefaultQueryTimeout.Seconds()), "")
+	SetQueryTimeouts(30, "")
+
+	waitForCancel := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	queryTimeoutsMu.Lock()
+	queryTimeouts["table"] = time.Millisecond
+	queryTimeoutsMu.Unlock()
+	err := withQueryTimeout(context.Background(), "table", "table", waitForCancel)
+	assert.True(t, errors.Is(err, ErrQueryTimeout))
+
+	runCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err = withQueryTimeout(runCtx, "index", "index", waitForCancel)
+	assert.True(t, errors.Is(err, ErrRunDeadlineExceeded))
+
+	queryErr := errors.New("syntax error")
+	err = withQueryTimeout(context.Background(), "index", "index", func(context.Context) error { return queryErr })
+	assert.Equal(t, queryErr, err)
+}
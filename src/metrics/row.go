@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+)
+
+// Row is a row returned by the query of a QueryDefinition, with the metrics of its columns
+type Row struct {
+	Columns map[string]interface{}
+	Metrics []MetricColumn
+}
+
+// GetDatabaseName returns the database column of the row
+func (r Row) GetDatabaseName() (string, error) {
+	return r.name("database")
+}
+
+// GetSchemaName returns the schema_name column of the row
+func (r Row) GetSchemaName() (string, error) {
+	return r.name("schema_name")
+}
+
+// GetTableName returns the table_name column of the row
+func (r Row) GetTableName() (string, error) {
+	return r.name("table_name")
+}
+
+// GetIndexName returns the index_name column of the row
+func (r Row) GetIndexName() (string, error) {
+	return r.name("index_name")
+}
+
+func (r Row) name(column string) (string, error) {
+	value, ok := r.Columns[column]
+	if !ok || value == nil {
+		return "", fmt.Errorf("%s not returned", column)
+	}
+	return toString(value), nil
+}
+
+// Attribute returns the value of column as an attribute, false if the column is missing or null
+func (r Row) Attribute(column string) (string, bool) {
+	value, ok := r.Columns[column]
+	if !ok || value == nil {
+		return "", false
+	}
+	return toString(value), true
+}
+
+// Number returns the value of column as a number, false if the column is missing, null or not numeric
+func (r Row) Number(column string) (float64, bool) {
+	switch v := r.Columns[column].(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case []byte, string:
+		number, err := strconv.ParseFloat(toString(v), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// populateMetricSet sets the metrics of the row in ms. Missing and null columns are skipped.
+func (r Row) populateMetricSet(ms *metric.Set) error {
+	for _, m := range r.Metrics {
+		sourceType, err := metric.SourceTypeForName(m.SourceType)
+		if err != nil {
+			return err
+		}
+		var value interface{}
+		var ok bool
+		if sourceType == metric.ATTRIBUTE {
+			value, ok = r.Attribute(m.Column)
+		} else {
+			value, ok = r.Number(m.Column)
+		}
+		if !ok {
+			continue
+		}
+		if err := ms.SetMetric(m.MetricName, value, sourceType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
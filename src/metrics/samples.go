@@ -0,0 +1,274 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/infra-integrations-sdk/v3/data/attribute"
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nri-postgresql/src/collection"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/scheduler"
+)
+
+// Sample is a row returned by a QueryDefinition, along with the namespace and ID attributes of the entity
+// it describes. The name of the entity is the ID attribute keyed by its namespace.
+type Sample struct {
+	Namespace    string
+	IDAttributes map[string]string
+	Row          Row
+}
+
+// CollectSamples runs the definitions of every entity type and returns their rows without populating any entity
+func CollectSamples(
+	ctx context.Context,
+	ci connection.Info,
+	con *connection.PGSQLConnection,
+	version *semver.Version,
+	databaseList collection.DatabaseList,
+	collectPgBouncer, collectDbLocks, collectBloat bool) []Sample {
+
+	host, port := ci.HostPort()
+	instanceIDs := func() map[string]string {
+		return map[string]string{"host": host, "port": port}
+	}
+
+	var samples []Sample
+	for _, row := range queryRows(ctx, con, generateInstanceDefinitions(version)) {
+		samples = append(samples, Sample{Namespace: "pg-instance", IDAttributes: instanceIDs(), Row: row})
+	}
+
+	databaseDefinitions := generateDatabaseDefinitions(databaseList, version)
+	if collectDbLocks && con.HaveExtensionInSchema(ctx, "tablefunc", "public") {
+		databaseDefinitions = append(databaseDefinitions, generateLockDefinitions(databaseList, version)...)
+	}
+	for _, row := range queryRows(ctx, con, databaseDefinitions) {
+		samples = append(samples, newSample("pg-database", instanceIDs(), row))
+	}
+
+	samples = append(samples, collectDatabaseSamples(ctx, ci, databaseList, func(schemaList collection.SchemaList) map[string][]*QueryDefinition {
+		return map[string][]*QueryDefinition{
+			"pg-table": generateTableDefinitions(schemaList, version, collectBloat),
+			"pg-index": generateIndexDefinitions(schemaList, version),
+		}
+	})...)
+
+	if collectPgBouncer {
+		pgBouncerCon, err := ci.NewConnection("pgbouncer")
+		if err != nil {
+			log.Error("Error creating connection to pgbouncer database: %s", err)
+		} else {
+			defer pgBouncerCon.Close()
+			for _, row := range queryRows(ctx, pgBouncerCon, generatePgBouncerDefinitions()) {
+				samples = append(samples, newSample("pgbouncer", instanceIDs(), row))
+			}
+		}
+	}
+
+	return samples
+}
+
+// CollectTableBloatSamples runs the bloat definitions of the tables of every database and returns their rows
+func CollectTableBloatSamples(ctx context.Context, ci connection.Info, version *semver.Version, databaseList collection.DatabaseList) []Sample {
+	return collectDatabaseSamples(ctx, ci, databaseList, func(schemaList collection.SchemaList) map[string][]*QueryDefinition {
+		return map[string][]*QueryDefinition{"pg-table": generateTableBloatDefinitions(schemaList, version)}
+	})
+}
+
+// collectDatabaseSamples runs the definitions returned by definitions, by entity namespace, on each database in
+// parallel
+func collectDatabaseSamples(ctx context.Context, ci connection.Info, databaseList collection.DatabaseList, definitions func(collection.SchemaList) map[string][]*QueryDefinition) []Sample {
+	host, port := ci.HostPort()
+
+	var mu sync.Mutex
+	var samples []Sample
+	databaseScheduler := scheduler.New(collectionConcurrency)
+	for database, schemaList := range databaseList {
+		if len(schemaList) == 0 {
+			continue
+		}
+
+		databaseScheduler.Go(func() {
+			databaseCon, err := ci.NewConnection(database)
+			if err != nil {
+				log.Error("Failed to connect to database %s: %s", database, err.Error())
+				return
+			}
+			defer databaseCon.Close()
+
+			var databaseSamples []Sample
+			for namespace, namespaceDefinitions := range definitions(schemaList) {
+				for _, row := range queryRows(ctx, databaseCon, namespaceDefinitions) {
+					databaseSamples = append(databaseSamples, newSample(namespace, map[string]string{"host": host, "port": port}, row))
+				}
+			}
+			mu.Lock()
+			samples = append(samples, databaseSamples...)
+			mu.Unlock()
+		})
+	}
+	databaseScheduler.Wait()
+	return samples
+}
+
+// SampleValue is a numeric metric of a sample
+type SampleValue struct {
+	MetricName string
+	// SourceType is the lowercase source type name, e.g. gauge or rate
+	SourceType string
+	Value      float64
+}
+
+// Values returns the metrics of the row of the sample, and its attributes: the ID attributes of its entity and
+// the attribute columns of the row. Missing, null and non-numeric columns are skipped.
+func (s Sample) Values() (map[string]string, []SampleValue) {
+	attributes := make(map[string]string, len(s.IDAttributes))
+	for key, value := range s.IDAttributes {
+		attributes[key] = value
+	}
+	var values []SampleValue
+	for _, m := range s.Row.Metrics {
+		sourceType, err := metric.SourceTypeForName(m.SourceType)
+		if err != nil {
+			continue
+		}
+		if sourceType == metric.ATTRIBUTE {
+			if value, ok := s.Row.Attribute(m.Column); ok {
+				attributes[m.MetricName] = value
+			}
+			continue
+		}
+		if number, ok := s.Row.Number(m.Column); ok {
+			values = append(values, SampleValue{MetricName: m.MetricName, SourceType: strings.ToLower(m.SourceType), Value: number})
+		}
+	}
+	return attributes, values
+}
+
+// newSample adds the ID attributes found in row to ids. The innermost one is the name of the entity, keyed by
+// its namespace: the database of a pg-database sample, the table of a pg-table sample.
+func newSample(namespace string, ids map[string]string, row Row) Sample {
+	for _, id := range []struct {
+		key  string
+		name func(interface{}) (string, error)
+	}{
+		{"pg-database", GetDatabaseName},
+		{"pg-schema", GetSchemaName},
+		{"pg-table", GetTableName},
+		{"pg-index", GetIndexName},
+	} {
+		if name, err := id.name(row); err == nil {
+			ids[id.key] = name
+		}
+	}
+	if namespace == "pgbouncer" {
+		ids[namespace] = ids["pg-database"]
+		delete(ids, "pg-database")
+	}
+	return Sample{Namespace: namespace, IDAttributes: ids, Row: row}
+}
+
+// queryRows returns the rows of every definition. Definitions whose query fails are skipped.
+func queryRows(ctx context.Context, con *connection.PGSQLConnection, definitions []*QueryDefinition) []Row {
+	var rows []Row
+	for _, definition := range definitions {
+		if definition == nil {
+			continue
+		}
+		definitionRows, err := queryDefinition(ctx, con, definition)
+		if err != nil {
+			log.Error("Could not execute %s query: %s", definition.GetName(), err.Error())
+			continue
+		}
+		rows = append(rows, definitionRows...)
+	}
+	return rows
+}
+
+// PopulateSamples adds samples to the entities of i with the event types and attributes of the Populate functions,
+// so rows collected once can be both exported and published. Instance samples are merged into one metric set of
+// instance.
+func PopulateSamples(samples []Sample, instance *integration.Entity, i *integration.Integration) {
+	var instanceMetricSet *metric.Set
+	for _, sample := range samples {
+		var metricSet *metric.Set
+		var err error
+		if sample.Namespace == "pg-instance" {
+			if instanceMetricSet == nil {
+				instanceMetricSet = instance.NewMetricSet("PostgresqlInstanceSample",
+					attribute.Attribute{Key: "displayName", Value: instance.Metadata.Name},
+					attribute.Attribute{Key: "entityName", Value: instance.Metadata.Namespace + ":" + instance.Metadata.Name},
+				)
+			}
+			metricSet = instanceMetricSet
+		} else if metricSet, err = sampleMetricSet(sample, i); err != nil {
+			log.Error("Failed to get %s entity: %s", sample.Namespace, err.Error())
+			continue
+		}
+		if err := sample.Row.populateMetricSet(metricSet); err != nil {
+			log.Error("Failed to populate %s entity with metrics: %s", sample.Namespace, err.Error())
+		}
+	}
+}
+
+// sampleMetricSet returns a new metric set of the entity of a database, table, index or PgBouncer sample
+func sampleMetricSet(sample Sample, i *integration.Integration) (*metric.Set, error) {
+	ids := sample.IDAttributes
+	idAttributes := func(keys ...string) []integration.IDAttribute {
+		attributes := make([]integration.IDAttribute, 0, len(keys))
+		for _, key := range keys {
+			attributes = append(attributes, integration.NewIDAttribute(key, ids[key]))
+		}
+		return attributes
+	}
+	switch sample.Namespace {
+	case "pg-database":
+		entity, err := i.Entity(ids["pg-database"], "pg-database", idAttributes("host", "port")...)
+		if err != nil {
+			return nil, err
+		}
+		return entity.NewMetricSet("PostgresqlDatabaseSample",
+			attribute.Attribute{Key: "displayName", Value: entity.Metadata.Name},
+			attribute.Attribute{Key: "entityName", Value: "database:" + entity.Metadata.Name},
+		), nil
+	case "pg-table":
+		entity, err := i.Entity(ids["pg-table"], "pg-table", idAttributes("host", "port", "pg-database", "pg-schema")...)
+		if err != nil {
+			return nil, err
+		}
+		return entity.NewMetricSet("PostgresqlTableSample",
+			attribute.Attribute{Key: "displayName", Value: entity.Metadata.Name},
+			attribute.Attribute{Key: "entityName", Value: "table:" + entity.Metadata.Name},
+			attribute.Attribute{Key: "database", Value: ids["pg-database"]},
+			attribute.Attribute{Key: "schema", Value: ids["pg-schema"]},
+		), nil
+	case "pg-index":
+		entity, err := i.Entity(ids["pg-index"], "pg-index", idAttributes("host", "port", "pg-database", "pg-schema", "pg-table")...)
+		if err != nil {
+			return nil, err
+		}
+		return entity.NewMetricSet("PostgresqlIndexSample",
+			attribute.Attribute{Key: "displayName", Value: entity.Metadata.Name},
+			attribute.Attribute{Key: "entityName", Value: "index:" + entity.Metadata.Name},
+			attribute.Attribute{Key: "database", Value: ids["pg-database"]},
+			attribute.Attribute{Key: "schema", Value: ids["pg-schema"]},
+			attribute.Attribute{Key: "table", Value: ids["pg-table"]},
+		), nil
+	case "pgbouncer":
+		entity, err := i.Entity(ids["pgbouncer"], "pgbouncer", idAttributes("host", "port")...)
+		if err != nil {
+			return nil, err
+		}
+		return entity.NewMetricSet("PgBouncerSample",
+			attribute.Attribute{Key: "displayName", Value: ids["pgbouncer"]},
+			attribute.Attribute{Key: "entityName", Value: "pgbouncer:" + ids["pgbouncer"]},
+			attribute.Attribute{Key: "host", Value: ids["host"]},
+		), nil
+	}
+	return nil, fmt.Errorf("unknown entity namespace %s", sample.Namespace)
+}
@@ -2,7 +2,9 @@ package metrics
 
 import (
 	"context"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/blang/semver/v4"
 	"github.com/newrelic/nri-postgresql/src/connection"
@@ -99,3 +101,17 @@ func Test_collectVersion_Err(t *testing.T) {
 
 	assert.NotNil(t, err)
 }
+
+func Test_collectStatsStartTime(t *testing.T) {
+	testConnection, mock := connection.CreateMockSQL(t)
+
+	statsReset := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(statsStartTimeQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"stats_start_time"}).AddRow(statsReset))
+
+	startTime, err := CollectStatsStartTime(context.Background(), testConnection)
+
+	assert.NoError(t, err)
+	assert.Equal(t, statsReset, startTime)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,61 @@
+package otlp
+
+// The types below follow the JSON encoding of the OTLP metrics protocol, see
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/metrics/v1/metrics.proto
+// 64-bit integers are encoded as strings and enums as numbers.
+
+type exportMetricsServiceRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type metric struct {
+	Name  string `json:"name"`
+	Gauge *gauge `json:"gauge,omitempty"`
+	Sum   *sum   `json:"sum,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type numberDataPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsDouble          float64    `json:"asDouble"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    string  `json:"intValue,omitempty"`
+}
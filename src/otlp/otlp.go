@@ -0,0 +1,198 @@
+// Package otlp exports the integration metrics as OpenTelemetry metrics over OTLP/HTTP, or to a file
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/metrics"
+)
+
+const (
+	scopeName = "nri-postgresql"
+	// aggregationTemporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE of the OTLP metrics protocol
+	aggregationTemporalityCumulative = 2
+	exportTimeout                    = 10 * time.Second
+)
+
+// Exporter converts samples to OTLP metrics and sends them to an OTLP/HTTP endpoint, appends them to a file as
+// JSON lines, or both
+type Exporter struct {
+	endpoint string
+	headers  map[string]string
+	file     string
+	client   *http.Client
+	resource resource
+	scope    scope
+	// startTime is the start of the cumulative sums, the time since which the server counters count. It is unknown,
+	// and omitted from the sums, until SetStartTime is called.
+	startTime time.Time
+}
+
+// New returns an Exporter for the OTLP endpoint and file of the arguments. Metrics are described by a resource
+// with the db.system=postgresql, server.address and server.port attributes.
+func New(al args.ArgumentList, integrationVersion string) (*Exporter, error) {
+	headers := map[string]string{}
+	if al.OtlpHeaders != "" {
+		if err := json.Unmarshal([]byte(al.OtlpHeaders), &headers); err != nil {
+			return nil, fmt.Errorf("invalid OTLP headers: %w", err)
+		}
+	}
+	resourceAttributes := []keyValue{
+		stringAttribute("db.system", "postgresql"),
+		stringAttribute("server.address", al.Hostname),
+	}
+	if port, err := strconv.ParseInt(al.Port, 10, 64); err == nil {
+		resourceAttributes = append(resourceAttributes, keyValue{Key: "server.port", Value: anyValue{IntValue: strconv.FormatInt(port, 10)}})
+	}
+	return &Exporter{
+		endpoint: al.OtlpEndpoint,
+		headers:  headers,
+		file:     al.OtlpFile,
+		client:   &http.Client{Timeout: exportTimeout},
+		resource: resource{Attributes: resourceAttributes},
+		scope:    scope{Name: scopeName, Version: integrationVersion},
+	}, nil
+}
+
+// SetStartTime sets the start of the cumulative sums exported next, the server start or the latest statistics reset
+// returned by metrics.CollectStatsStartTime
+func (e *Exporter) SetStartTime(startTime time.Time) {
+	e.startTime = startTime
+}
+
+// Export sends the metrics of samples, observed at now, to the endpoint and the file
+func (e *Exporter) Export(ctx context.Context, samples []metrics.Sample, now time.Time) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(e.newRequest(samples, now))
+	if err != nil {
+		return err
+	}
+	if e.file != "" {
+		if err := appendLine(e.file, payload); err != nil {
+			return fmt.Errorf("writing OTLP file %s: %w", e.file, err)
+		}
+	}
+	if e.endpoint != "" {
+		if err := e.post(ctx, payload); err != nil {
+			return fmt.Errorf("sending OTLP metrics to %s: %w", e.endpoint, err)
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) post(ctx context.Context, payload []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		request.Header.Set(key, value)
+	}
+	response, err := e.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func appendLine(path string, payload []byte) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(payload, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// newRequest groups the values of samples into one metric per name. Rate and delta source types are cumulative
+// monotonic sums of the counters read from the server, starting at the start time of the counters; other source types
+// are gauges. The ID attributes of the
+// entity, except host and port which describe the resource, and the attribute fields are data point attributes.
+func (e *Exporter) newRequest(samples []metrics.Sample, now time.Time) exportMetricsServiceRequest {
+	timeUnixNano := strconv.FormatInt(now.UnixNano(), 10)
+	var startTimeUnixNano string
+	if !e.startTime.IsZero() {
+		startTimeUnixNano = strconv.FormatInt(e.startTime.UnixNano(), 10)
+	}
+	metricsByName := make(map[string]*metric)
+	for _, sample := range samples {
+		attributes, values := sample.Values()
+		delete(attributes, "host")
+		delete(attributes, "port")
+		pointAttributes := sortedAttributes(attributes)
+		for _, value := range values {
+			point := numberDataPoint{Attributes: pointAttributes, TimeUnixNano: timeUnixNano, AsDouble: value.Value}
+			if value.SourceType == "rate" || value.SourceType == "delta" {
+				name := "postgresql." + strings.TrimSuffix(value.MetricName, "PerSecond")
+				m, ok := metricsByName[name]
+				if !ok {
+					m = &metric{Name: name, Sum: &sum{AggregationTemporality: aggregationTemporalityCumulative, IsMonotonic: true}}
+					metricsByName[name] = m
+				}
+				point.StartTimeUnixNano = startTimeUnixNano
+				m.Sum.DataPoints = append(m.Sum.DataPoints, point)
+				continue
+			}
+			name := "postgresql." + value.MetricName
+			m, ok := metricsByName[name]
+			if !ok {
+				m = &metric{Name: name, Gauge: &gauge{}}
+				metricsByName[name] = m
+			}
+			m.Gauge.DataPoints = append(m.Gauge.DataPoints, point)
+		}
+	}
+
+	names := make([]string, 0, len(metricsByName))
+	for name := range metricsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	otlpMetrics := make([]metric, 0, len(names))
+	for _, name := range names {
+		otlpMetrics = append(otlpMetrics, *metricsByName[name])
+	}
+	return exportMetricsServiceRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     e.resource,
+		ScopeMetrics: []scopeMetrics{{Scope: e.scope, Metrics: otlpMetrics}},
+	}}}
+}
+
+func sortedAttributes(attributes map[string]string) []keyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keyValues := make([]keyValue, 0, len(keys))
+	for _, key := range keys {
+		keyValues = append(keyValues, stringAttribute(key, attributes[key]))
+	}
+	return keyValues
+}
+
+func stringAttribute(key string, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: &value}}
+}
@@ -0,0 +1,127 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSamples() []metrics.Sample {
+	return []metrics.Sample{{
+		Namespace:    "pg-table",
+		IDAttributes: map[string]string{"host": "localhost", "port": "5432", "pg-database": "postgres"},
+		Row: metrics.Row{
+			Columns: map[string]interface{}{"name": "users", "rows": int64(10), "scans": int64(3), "missing": nil},
+			Metrics: []metrics.MetricColumn{
+				{Column: "name", MetricName: "table.name", SourceType: "attribute"},
+				{Column: "rows", MetricName: "table.liveRows", SourceType: "gauge"},
+				{Column: "scans", MetricName: "table.sequentialScansPerSecond", SourceType: "rate"},
+				{Column: "missing", MetricName: "table.missing", SourceType: "gauge"},
+			},
+		},
+	}}
+}
+
+func testExporter(t *testing.T, al args.ArgumentList) *Exporter {
+	al.Hostname, al.Port = "localhost", "5432"
+	e, err := New(al, "1.2.3")
+	require.NoError(t, err)
+	return e
+}
+
+func TestNewRequest(t *testing.T) {
+	e := testExporter(t, args.ArgumentList{})
+	e.SetStartTime(time.Unix(0, 500))
+	request := e.newRequest(testSamples(), time.Unix(1, 0))
+
+	payload, err := json.Marshal(request)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"resourceMetrics":[{
+		"resource":{"attributes":[
+			{"key":"db.system","value":{"stringValue":"postgresql"}},
+			{"key":"server.address","value":{"stringValue":"localhost"}},
+			{"key":"server.port","value":{"intValue":"5432"}}]},
+		"scopeMetrics":[{"scope":{"name":"nri-postgresql","version":"1.2.3"},"metrics":[
+			{"name":"postgresql.table.liveRows","gauge":{"dataPoints":[{
+				"attributes":[
+					{"key":"pg-database","value":{"stringValue":"postgres"}},
+					{"key":"table.name","value":{"stringValue":"users"}}],
+				"timeUnixNano":"1000000000","asDouble":10}]}},
+			{"name":"postgresql.table.sequentialScans","sum":{"aggregationTemporality":2,"isMonotonic":true,"dataPoints":[{
+				"attributes":[
+					{"key":"pg-database","value":{"stringValue":"postgres"}},
+					{"key":"table.name","value":{"stringValue":"users"}}],
+				"startTimeUnixNano":"500","timeUnixNano":"1000000000","asDouble":3}]}}]}]}]}`, string(payload))
+}
+
+func TestNewRequest_UnknownStartTime(t *testing.T) {
+	e := testExporter(t, args.ArgumentList{})
+	request := e.newRequest(testSamples(), time.Unix(1, 0))
+
+	payload, err := json.Marshal(request)
+	require.NoError(t, err)
+	assert.Contains(t, string(payload), `"aggregationTemporality":2`)
+	assert.NotContains(t, string(payload), "startTimeUnixNano", "the start of the sums is not known before it is read from the server")
+}
+
+func TestNew_InvalidHeaders(t *testing.T) {
+	_, err := New(args.ArgumentList{OtlpHeaders: "not json"}, "1.2.3")
+	assert.Error(t, err)
+}
+
+func TestExport_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "metrics.jsonl")
+	e := testExporter(t, args.ArgumentList{OtlpFile: file})
+
+	require.NoError(t, e.Export(context.Background(), testSamples(), time.Unix(1, 0)))
+	require.NoError(t, e.Export(context.Background(), testSamples(), time.Unix(2, 0)))
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+	var request exportMetricsServiceRequest
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &request))
+	assert.Equal(t, "2000000000", request.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Gauge.DataPoints[0].TimeUnixNano)
+}
+
+func TestExport_HTTP(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+	e := testExporter(t, args.ArgumentList{OtlpEndpoint: server.URL, OtlpHeaders: `{"Api-Key":"secret"}`})
+
+	require.NoError(t, e.Export(context.Background(), testSamples(), time.Unix(1, 0)))
+
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "secret", header.Get("Api-Key"))
+	var request exportMetricsServiceRequest
+	require.NoError(t, json.Unmarshal(body, &request))
+	assert.Len(t, request.ResourceMetrics[0].ScopeMetrics[0].Metrics, 2)
+}
+
+func TestExport_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+	e := testExporter(t, args.ArgumentList{OtlpEndpoint: server.URL})
+
+	assert.Error(t, e.Export(context.Background(), testSamples(), time.Unix(1, 0)))
+}
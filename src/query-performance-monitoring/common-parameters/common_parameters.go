@@ -1,6 +1,10 @@
 package commonparameters
 
 import (
+	"encoding/json"
+	"regexp"
+	"time"
+
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	"github.com/newrelic/nri-postgresql/src/args"
 )
@@ -9,8 +13,27 @@ const (
 	MaxQueryCountThreshold               = 30
 	DefaultQueryMonitoringCountThreshold = 20
 	DefaultQueryResponseTimeThreshold    = 500
+	DefaultExplainAnalyzeTimeout         = 5000
+	DefaultExplainRateLimit              = 5
+	DefaultExplainInterval               = 600
+	DefaultLongRunningThreshold          = 300
+	DefaultConcurrency                   = 4
 )
 
+// defaultExplainDenylist matches SELECT statements that have side effects or hold locks when executed
+var defaultExplainDenylist = []string{
+	`(?i)\bnextval\s*\(`,
+	`(?i)\bsetval\s*\(`,
+	`(?i)\bpg_sleep`,
+	`(?i)\bpg_advisory`,
+	`(?i)\bpg_(terminate|cancel)_backend\s*\(`,
+	`(?i)\bdblink`,
+	`(?i)\blo_\w+\s*\(`,
+	`(?i)\bfor\s+(no\s+key\s+)?(update|share)\b`,
+	`(?i)\bfor\s+key\s+share\b`,
+	`(?i)\binto\b`,
+}
+
 type CommonParameters struct {
 	Version                              uint64
 	Databases                            string
@@ -18,6 +41,15 @@ type CommonParameters struct {
 	QueryMonitoringResponseTimeThreshold int
 	Host                                 string
 	Port                                 string
+	ExplainAnalyze                       bool
+	ExplainAnalyzeTimeout                int
+	ExplainDenylist                      []*regexp.Regexp
+	ExplainRateLimit                     int
+	ExplainInterval                      time.Duration
+	LongRunningThreshold                 int
+	QueryFilter                          *QueryFilter
+	ErrorLogPath                         string
+	Concurrency                          int
 }
 
 func SetCommonParameters(a args.ArgumentList, version uint64, dbs string) *CommonParameters {
@@ -28,6 +60,15 @@ func SetCommonParameters(a args.ArgumentList, version uint64, dbs string) *Commo
 		QueryMonitoringResponseTimeThreshold: validateResponseTime(a),
 		Host:                                 a.Hostname,
 		Port:                                 a.Port,
+		ExplainAnalyze:                       a.QueryMonitoringExplainAnalyze,
+		ExplainAnalyzeTimeout:                validateExplainAnalyzeTimeout(a),
+		ExplainDenylist:                      parseExplainDenylist(a),
+		ExplainRateLimit:                     validateExplainRateLimit(a),
+		ExplainInterval:                      validateExplainInterval(a),
+		LongRunningThreshold:                 validateLongRunningThreshold(a),
+		QueryFilter:                          parseQueryFilter(a.QueryMonitoringFilterRules),
+		ErrorLogPath:                         a.QueryMonitoringErrorLogPath,
+		Concurrency:                          validateConcurrency(a),
 	}
 }
 
@@ -50,3 +91,69 @@ func validateResponseTime(a args.ArgumentList) int {
 	}
 	return a.QueryMonitoringResponseTimeThreshold
 }
+
+func validateExplainAnalyzeTimeout(a args.ArgumentList) int {
+	if a.QueryMonitoringExplainAnalyzeTimeout <= 0 {
+		log.Warn("invalid explain analyze timeout %d, using default %d", a.QueryMonitoringExplainAnalyzeTimeout, DefaultExplainAnalyzeTimeout)
+		return DefaultExplainAnalyzeTimeout
+	}
+	return a.QueryMonitoringExplainAnalyzeTimeout
+}
+
+func validateConcurrency(a args.ArgumentList) int {
+	if a.Concurrency <= 0 {
+		log.Warn("invalid concurrency %d, using default %d", a.Concurrency, DefaultConcurrency)
+		return DefaultConcurrency
+	}
+	return a.Concurrency
+}
+
+func validateExplainRateLimit(a args.ArgumentList) int {
+	if a.QueryMonitoringExplainRateLimit <= 0 {
+		log.Warn("invalid explain rate limit %d, using default %d", a.QueryMonitoringExplainRateLimit, DefaultExplainRateLimit)
+		return DefaultExplainRateLimit
+	}
+	return a.QueryMonitoringExplainRateLimit
+}
+
+func validateExplainInterval(a args.ArgumentList) time.Duration {
+	if a.QueryMonitoringExplainInterval < 0 {
+		log.Warn("invalid explain interval %d, using default %d", a.QueryMonitoringExplainInterval, DefaultExplainInterval)
+		return DefaultExplainInterval * time.Second
+	}
+	return time.Duration(a.QueryMonitoringExplainInterval) * time.Second
+}
+
+func validateLongRunningThreshold(a args.ArgumentList) int {
+	if a.QueryMonitoringLongRunningThreshold <= 0 {
+		log.Warn("invalid long running threshold %d, using default %d", a.QueryMonitoringLongRunningThreshold, DefaultLongRunningThreshold)
+		return DefaultLongRunningThreshold
+	}
+	return a.QueryMonitoringLongRunningThreshold
+}
+
+// parseExplainDenylist compiles the default denylist followed by the user provided patterns. Invalid user
+// patterns are skipped with a warning.
+func parseExplainDenylist(a args.ArgumentList) []*regexp.Regexp {
+	denylist := make([]*regexp.Regexp, 0, len(defaultExplainDenylist))
+	for _, pattern := range defaultExplainDenylist {
+		denylist = append(denylist, regexp.MustCompile(pattern))
+	}
+	if a.QueryMonitoringExplainDenylist == "" {
+		return denylist
+	}
+	var patterns []string
+	if err := json.Unmarshal([]byte(a.QueryMonitoringExplainDenylist), &patterns); err != nil {
+		log.Warn("invalid explain denylist %s: %v", a.QueryMonitoringExplainDenylist, err)
+		return denylist
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Warn("invalid explain denylist pattern %s: %v", pattern, err)
+			continue
+		}
+		denylist = append(denylist, re)
+	}
+	return denylist
+}
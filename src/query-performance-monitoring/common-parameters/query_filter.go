@@ -0,0 +1,203 @@
+package commonparameters
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+const (
+	// queryFilterFetchFactor is how many times the reported rows collectors fetch when the filter may drop queries
+	queryFilterFetchFactor = 5
+	// maxQueryFilterFetchRows caps the rows fetched by each collector query when the filter may drop queries
+	maxQueryFilterFetchRows = 500
+)
+
+// defaultQueryFilterExclude excludes the queries of the integration itself. The default_exclude key of the filter
+// rules replaces it, and an empty list reports them.
+var defaultQueryFilterExclude = []queryFilterRuleConfig{
+	{QueryText: `(?is)^EXPLAIN \(.*FORMAT JSON\) `},
+	{QueryText: `(?i)^SELECT \$1 as newrelic`},
+	{QueryText: `(?i)^WITH wait_history AS`},
+	{QueryText: `(?i)^SELECT -- (BLOAT|INDEX|TABLE|CAPABILITY_)`},
+	{QueryText: `(?i)^SELECT table_schema`},
+	{QueryText: `(?i)^SELECT D\.datname`},
+}
+
+// QueryFilterRule matches a query when every pattern it sets matches the corresponding attribute
+type QueryFilterRule struct {
+	QueryText       *regexp.Regexp
+	Database        *regexp.Regexp
+	User            *regexp.Regexp
+	ApplicationName *regexp.Regexp
+	StatementType   *regexp.Regexp
+}
+
+// QueryFilter decides which queries the query performance collectors report. A query is reported when it matches
+// one of the include rules, if any, matches none of the exclude rules, is in the query ID allowlist, if any, and
+// is not in the query ID denylist. Attributes a collector does not report never cause a query to be dropped.
+type QueryFilter struct {
+	Include          []QueryFilterRule
+	Exclude          []QueryFilterRule
+	QueryIDAllowlist map[string]bool
+	QueryIDDenylist  map[string]bool
+}
+
+// QueryAttributes are the attributes of a query the filter rules are evaluated against. Nil attributes are
+// not reported by the collector. QueryText is the normalized or anonymized query text.
+type QueryAttributes struct {
+	QueryID         *string
+	QueryText       *string
+	Database        *string
+	User            *string
+	ApplicationName *string
+	StatementType   *string
+}
+
+type queryFilterRuleConfig struct {
+	QueryText       string `json:"query_text"`
+	Database        string `json:"database"`
+	User            string `json:"user"`
+	ApplicationName string `json:"application_name"`
+	StatementType   string `json:"statement_type"`
+}
+
+type queryFilterConfig struct {
+	Include          []queryFilterRuleConfig  `json:"include"`
+	Exclude          []queryFilterRuleConfig  `json:"exclude"`
+	DefaultExclude   *[]queryFilterRuleConfig `json:"default_exclude"`
+	QueryIDAllowlist []string                 `json:"query_id_allowlist"`
+	QueryIDDenylist  []string                 `json:"query_id_denylist"`
+}
+
+// Allows reports whether a query with the given attributes should be collected
+func (f *QueryFilter) Allows(attributes QueryAttributes) bool {
+	if f == nil {
+		return true
+	}
+	if attributes.QueryID != nil {
+		if f.QueryIDDenylist[*attributes.QueryID] {
+			return false
+		}
+		if len(f.QueryIDAllowlist) > 0 && !f.QueryIDAllowlist[*attributes.QueryID] {
+			return false
+		}
+	}
+	for _, rule := range f.Exclude {
+		if rule.matches(attributes, false) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, rule := range f.Include {
+		if rule.matches(attributes, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchLimit returns the number of rows a collector fetches to report up to limit queries. The filter is applied to
+// the rows read, so when it may drop queries more rows are fetched and the collector stops at limit allowed ones.
+func (f *QueryFilter) FetchLimit(limit int) int {
+	if f == nil || (len(f.Include) == 0 && len(f.Exclude) == 0 && len(f.QueryIDAllowlist) == 0 && len(f.QueryIDDenylist) == 0) {
+		return limit
+	}
+	return max(limit, min(limit*queryFilterFetchFactor, maxQueryFilterFetchRows))
+}
+
+// matches evaluates every pattern of the rule. Patterns on unknown attributes evaluate to unknownMatches.
+func (r QueryFilterRule) matches(attributes QueryAttributes, unknownMatches bool) bool {
+	conditions := []struct {
+		pattern *regexp.Regexp
+		value   *string
+	}{
+		{r.QueryText, attributes.QueryText},
+		{r.Database, attributes.Database},
+		{r.User, attributes.User},
+		{r.ApplicationName, attributes.ApplicationName},
+		{r.StatementType, attributes.StatementType},
+	}
+	for _, condition := range conditions {
+		if condition.pattern == nil {
+			continue
+		}
+		if condition.value == nil {
+			if !unknownMatches {
+				return false
+			}
+			continue
+		}
+		if !condition.pattern.MatchString(*condition.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseQueryFilter reads the filter rules from a JSON object. Invalid rules are skipped with a warning. The default
+// exclude rules apply unless the object replaces them.
+func parseQueryFilter(filterRules string) *QueryFilter {
+	filter := &QueryFilter{Exclude: compileQueryFilterRules(defaultQueryFilterExclude)}
+	if filterRules == "" {
+		return filter
+	}
+	var config queryFilterConfig
+	if err := json.Unmarshal([]byte(filterRules), &config); err != nil {
+		log.Warn("invalid query filter rules %s: %v", filterRules, err)
+		return filter
+	}
+	defaultExclude := defaultQueryFilterExclude
+	if config.DefaultExclude != nil {
+		defaultExclude = *config.DefaultExclude
+	}
+	filter.Include = compileQueryFilterRules(config.Include)
+	filter.Exclude = append(compileQueryFilterRules(config.Exclude), compileQueryFilterRules(defaultExclude)...)
+	filter.QueryIDAllowlist = queryIDSet(config.QueryIDAllowlist)
+	filter.QueryIDDenylist = queryIDSet(config.QueryIDDenylist)
+	return filter
+}
+
+func compileQueryFilterRules(ruleConfigs []queryFilterRuleConfig) []QueryFilterRule {
+	var rules []QueryFilterRule
+	for _, ruleConfig := range ruleConfigs {
+		var rule QueryFilterRule
+		valid := true
+		for _, field := range []struct {
+			pattern string
+			target  **regexp.Regexp
+		}{
+			{ruleConfig.QueryText, &rule.QueryText},
+			{ruleConfig.Database, &rule.Database},
+			{ruleConfig.User, &rule.User},
+			{ruleConfig.ApplicationName, &rule.ApplicationName},
+			{ruleConfig.StatementType, &rule.StatementType},
+		} {
+			if field.pattern == "" {
+				continue
+			}
+			re, err := regexp.Compile(field.pattern)
+			if err != nil {
+				log.Warn("invalid query filter pattern %s: %v", field.pattern, err)
+				valid = false
+				break
+			}
+			*field.target = re
+		}
+		if valid {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func queryIDSet(queryIDs []string) map[string]bool {
+	set := make(map[string]bool, len(queryIDs))
+	for _, queryID := range queryIDs {
+		set[queryID] = true
+	}
+	return set
+}
@@ -0,0 +1,80 @@
+package commonparameters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stringPtr(v string) *string {
+	return &v
+}
+
+func TestQueryFilterAllows(t *testing.T) {
+	filter := parseQueryFilter(`{
+		"include": [{"database": "^shop$"}, {"application_name": "^checkout"}],
+		"exclude": [{"query_text": "(?i)^vacuum"}, {"user": "^replicator$", "statement_type": "^SELECT$"}],
+		"query_id_denylist": ["-42"]
+	}`)
+
+	testCases := []struct {
+		name       string
+		attributes QueryAttributes
+		allowed    bool
+	}{
+		{"included database", QueryAttributes{Database: stringPtr("shop"), QueryText: stringPtr("SELECT 1")}, true},
+		{"not included", QueryAttributes{Database: stringPtr("analytics"), ApplicationName: stringPtr("psql")}, false},
+		{"included application", QueryAttributes{Database: stringPtr("analytics"), ApplicationName: stringPtr("checkout-api")}, true},
+		{"unknown application is not dropped", QueryAttributes{Database: stringPtr("analytics")}, true},
+		{"excluded query text", QueryAttributes{Database: stringPtr("shop"), QueryText: stringPtr("VACUUM orders")}, false},
+		{"excluded user and statement type", QueryAttributes{Database: stringPtr("shop"), User: stringPtr("replicator"), StatementType: stringPtr("SELECT")}, false},
+		{"partially matching exclude rule", QueryAttributes{Database: stringPtr("shop"), User: stringPtr("replicator"), StatementType: stringPtr("UPDATE")}, true},
+		{"unknown user is not excluded", QueryAttributes{Database: stringPtr("shop"), StatementType: stringPtr("SELECT")}, true},
+		{"denied query ID", QueryAttributes{Database: stringPtr("shop"), QueryID: stringPtr("-42")}, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.allowed, filter.Allows(tc.attributes), tc.name)
+	}
+}
+
+func TestQueryFilterAllowlist(t *testing.T) {
+	filter := parseQueryFilter(`{"query_id_allowlist": ["1", "2"]}`)
+	assert.True(t, filter.Allows(QueryAttributes{QueryID: stringPtr("1")}))
+	assert.False(t, filter.Allows(QueryAttributes{QueryID: stringPtr("3")}))
+	assert.True(t, filter.Allows(QueryAttributes{Database: stringPtr("shop")}))
+}
+
+func TestParseQueryFilterInvalid(t *testing.T) {
+	filter := parseQueryFilter(`{"exclude": [{"query_text": "("}, {"database": "^tmp"}]}`)
+	assert.Len(t, filter.Exclude, 1+len(defaultQueryFilterExclude))
+
+	filter = parseQueryFilter(`not json`)
+	assert.True(t, filter.Allows(QueryAttributes{Database: stringPtr("shop")}))
+}
+
+func TestQueryFilterDefaultExclude(t *testing.T) {
+	explain := QueryAttributes{QueryText: stringPtr("EXPLAIN (VERBOSE, FORMAT JSON) SELECT * FROM orders")}
+	bloat := QueryAttributes{QueryText: stringPtr("select -- BLOATQUERY\n  current_database()")}
+
+	filter := parseQueryFilter("")
+	assert.False(t, filter.Allows(explain))
+	assert.False(t, filter.Allows(bloat))
+	assert.True(t, filter.Allows(QueryAttributes{QueryText: stringPtr("SELECT * FROM orders")}))
+
+	filter = parseQueryFilter(`{"exclude": [{"user": "^batch$"}]}`)
+	assert.False(t, filter.Allows(explain))
+
+	filter = parseQueryFilter(`{"default_exclude": [{"query_text": "(?i)^select -- BLOATQUERY"}]}`)
+	assert.True(t, filter.Allows(explain))
+	assert.False(t, filter.Allows(bloat))
+
+	filter = parseQueryFilter(`{"default_exclude": []}`)
+	assert.True(t, filter.Allows(bloat))
+}
+
+func TestQueryFilterFetchLimit(t *testing.T) {
+	assert.Equal(t, 100, parseQueryFilter("").FetchLimit(20))
+	assert.Equal(t, 500, parseQueryFilter("").FetchLimit(200))
+	assert.Equal(t, 20, parseQueryFilter(`{"default_exclude": []}`).FetchLimit(20))
+	assert.Equal(t, 20, (*QueryFilter)(nil).FetchLimit(20))
+}
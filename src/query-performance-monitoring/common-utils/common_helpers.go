@@ -1,21 +1,26 @@
 package commonutils
 
 import (
-	"crypto/rand"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
-	"math/big"
+	"net/url"
 	"regexp"
 	"strings"
-	"sync/atomic"
-	"time"
 
 	"github.com/newrelic/nri-postgresql/src/collection"
 )
 
 var re = regexp.MustCompile(`'[^']*'|\d+|".*?"`)
 
+// planLiteralRegex matches the string and numeric literals of a plan node field, leaving identifiers such as t1.id
+// or "Order" intact
+var planLiteralRegex = regexp.MustCompile(`'(?:[^']|'')*'|\b\d+(?:\.\d+)?\b`)
+
+// errorMessageLiteralRegex matches the quoted values and numbers of an error message, such as the input of
+// invalid input syntax for type integer: "4111"
+var errorMessageLiteralRegex = regexp.MustCompile(`"(?:[^"]|"")*"|'(?:[^']|'')*'|\b\d+(?:\.\d+)?\b`)
+
 func GetDatabaseListInString(dbMap collection.DatabaseList) string {
 	if len(dbMap) == 0 {
 		return ""
@@ -31,15 +36,76 @@ func AnonymizeQueryText(q string) string {
 	return re.ReplaceAllString(q, "?")
 }
 
-var planCounter uint64
+// AnonymizePlanText replaces the literals of a plan node field, such as a filter or output list, keeping the
+// column names and aliases it refers to
+func AnonymizePlanText(text string) string {
+	return planLiteralRegex.ReplaceAllString(text, "?")
+}
+
+// AnonymizeErrorMessage replaces the quoted values and numbers of an error message, which may hold user data
+func AnonymizeErrorMessage(message string) string {
+	return errorMessageLiteralRegex.ReplaceAllString(message, "?")
+}
 
-func GeneratePlanID() (string, error) {
-	ctr := atomic.AddUint64(&planCounter, 1)
-	rnd, err := rand.Int(rand.Reader, big.NewInt(RandomIntRange))
+var (
+	sqlCommentRegex = regexp.MustCompile(`(?s)/\*(.*?)\*/`)
+	queryTagRegex   = regexp.MustCompile(`([^=,'\s]+)='((?:[^'\\]|\\.)*)'`)
+	// queryTagCommentRegex matches comments made only of sqlcommenter key='value' pairs
+	queryTagCommentRegex = regexp.MustCompile(`^\s*[^=,'\s]+='(?:[^'\\]|\\.)*'(?:\s*,\s*[^=,'\s]+='(?:[^'\\]|\\.)*')*\s*$`)
+)
+
+// ExtractQueryTags returns the key/value pairs of the sqlcommenter comments in queryText, for example
+// /*controller='orders',route='%2Fapi%2Forders'*/. It must run before the query text is anonymized.
+func ExtractQueryTags(queryText string) map[string]string {
+	var tags map[string]string
+	for _, comment := range sqlCommentRegex.FindAllStringSubmatch(queryText, -1) {
+		if !queryTagCommentRegex.MatchString(comment[1]) {
+			continue
+		}
+		for _, pair := range queryTagRegex.FindAllStringSubmatch(comment[1], -1) {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[unescapeQueryTag(pair[1])] = unescapeQueryTag(strings.ReplaceAll(pair[2], `\'`, `'`))
+		}
+	}
+	return tags
+}
+
+func unescapeQueryTag(value string) string {
+	unescaped, err := url.PathUnescape(value)
 	if err != nil {
-		return "", ErrUnExpectedError
+		return value
+	}
+	return unescaped
+}
+
+// planShapeKeys are the plan node fields that define the shape of a plan. Costs, row
+// estimates and filter literals are left out so that a plan only changes identity when
+// the planner picks a different access path or join order.
+var planShapeKeys = []string{"Node Type", "Parent Relationship", "Join Type", "Strategy", "Schema", "Relation Name", "Index Name", "CTE Name", "Subplan Name"}
+
+// GeneratePlanHash returns a deterministic identifier for the normalized plan tree rooted at plan.
+func GeneratePlanHash(plan map[string]interface{}) string {
+	var sb strings.Builder
+	writePlanShape(&sb, plan)
+	hash := sha1.Sum([]byte(sb.String()))
+	return hex.EncodeToString(hash[:PlanHashBytes])
+}
+
+func writePlanShape(sb *strings.Builder, plan map[string]interface{}) {
+	sb.WriteByte('(')
+	for _, key := range planShapeKeys {
+		if value, ok := plan[key]; ok {
+			fmt.Fprintf(sb, "%s=%v;", key, value)
+		}
+	}
+	if nestedPlans, ok := plan["Plans"].([]interface{}); ok {
+		for _, nestedPlan := range nestedPlans {
+			if nestedPlanMap, nestedOk := nestedPlan.(map[string]interface{}); nestedOk {
+				writePlanShape(sb, nestedPlanMap)
+			}
+		}
 	}
-	ts := time.Now().UTC().Format(TimeFormat)
-	hash := sha1.Sum([]byte(ts))
-	return fmt.Sprintf("%06d-%06d-%s", rnd.Int64(), ctr%1_000_000, hex.EncodeToString(hash[:6])), nil
+	sb.WriteByte(')')
 }
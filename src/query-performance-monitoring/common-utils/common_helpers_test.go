@@ -36,3 +36,56 @@ func TestAnonymizeQueryText(t *testing.T) {
 	result = AnonymizeQueryText(query)
 	assert.Equal(t, expected, result)
 }
+
+func TestAnonymizePlanText(t *testing.T) {
+	assert.Equal(t, "((t1.status = ?::text) AND (t1.total > ?))", AnonymizePlanText("((t1.status = 'it''s'::text) AND (t1.total > 12.5))"))
+	assert.Equal(t, `"Order"."line2", t2.id, ?`, AnonymizePlanText(`"Order"."line2", t2.id, 42`))
+}
+
+func TestAnonymizeErrorMessage(t *testing.T) {
+	assert.Equal(t, "invalid input syntax for type integer: ?", AnonymizeErrorMessage(`invalid input syntax for type integer: "4111111111111111"`))
+	assert.Equal(t, "value too long for type character varying(?)", AnonymizeErrorMessage("value too long for type character varying(20)"))
+	assert.Equal(t, "duplicate key value violates unique constraint ?", AnonymizeErrorMessage(`duplicate key value violates unique constraint "orders_pkey"`))
+	assert.Equal(t, "division by zero", AnonymizeErrorMessage("division by zero"))
+}
+
+func TestGeneratePlanHash(t *testing.T) {
+	seqScanPlan := func(totalCost float64) map[string]interface{} {
+		return map[string]interface{}{
+			"Node Type":  "Hash Join",
+			"Join Type":  "Inner",
+			"Total Cost": totalCost,
+			"Plans": []interface{}{
+				map[string]interface{}{"Node Type": "Seq Scan", "Relation Name": "orders", "Parent Relationship": "Outer"},
+				map[string]interface{}{"Node Type": "Hash", "Parent Relationship": "Inner"},
+			},
+		}
+	}
+	indexScanPlan := map[string]interface{}{
+		"Node Type": "Hash Join",
+		"Join Type": "Inner",
+		"Plans": []interface{}{
+			map[string]interface{}{"Node Type": "Index Scan", "Relation Name": "orders", "Index Name": "orders_pkey", "Parent Relationship": "Outer"},
+			map[string]interface{}{"Node Type": "Hash", "Parent Relationship": "Inner"},
+		},
+	}
+
+	hash := GeneratePlanHash(seqScanPlan(100))
+	assert.Len(t, hash, 2*PlanHashBytes)
+	assert.Equal(t, hash, GeneratePlanHash(seqScanPlan(100)))
+	assert.Equal(t, hash, GeneratePlanHash(seqScanPlan(250)), "cost estimates must not change the plan hash")
+	assert.NotEqual(t, hash, GeneratePlanHash(indexScanPlan))
+}
+
+func TestExtractQueryTags(t *testing.T) {
+	query := "SELECT * FROM orders WHERE id = $1 /*controller='orders',route='%2Fapi%2Forders%2F%3Aid',traceparent='00-5bd66ef5095369c7b0d1f8f4bd33716a-c532cb4098ac3dd2-01'*/"
+	assert.Equal(t, map[string]string{
+		"controller":  "orders",
+		"route":       "/api/orders/:id",
+		"traceparent": "00-5bd66ef5095369c7b0d1f8f4bd33716a-c532cb4098ac3dd2-01",
+	}, ExtractQueryTags(query))
+
+	assert.Equal(t, map[string]string{"action": "it's"}, ExtractQueryTags(`/* action='it\'s' */ SELECT 1`))
+	assert.Nil(t, ExtractQueryTags("SELECT 1 /* plain comment */"))
+	assert.Nil(t, ExtractQueryTags("SELECT 1"))
+}
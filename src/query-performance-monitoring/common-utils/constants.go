@@ -1,14 +1,43 @@
 package commonutils
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 const (
 	PublishThreshold                    = 600
-	RandomIntRange                      = 1_000_000
-	TimeFormat                          = "20060102150405"
+	PlanHashBytes                       = 8
+	MaxPlanJSONLength                   = 4095
+	MaxPlanJSONParts                    = 16
 	MaxIndividualQueryCountThreshold    = 10
 	ExplainTPS                          = 5
+	ExplainTimeout                      = 10 * time.Second
+	ExplainAnalyzeTimeoutMargin         = 5 * time.Second
 	DefaultStatementTimeoutMilliseconds = 5000
+	PlanHistoryStoreName                = "nri-postgresql-plan-history"
+	PlanHistoryTTL                      = 7 * 24 * time.Hour
+	PlanCacheStatsChangeRatio           = 0.5
+	MaxErrorLogBytesPerRun              = 16 << 20
+	MaxTempSpillTrackedQueries          = 1000
+)
+
+// Thresholds used by the execution plan advisor
+const (
+	AdvisorLargeRelationRows        = 10_000
+	AdvisorSelectiveFilterRatio     = 0.1
+	AdvisorLargeSeqScanCost         = 10_000
+	AdvisorSelectiveScanMaxRows     = 1_000
+	AdvisorMinRowsRemovedByFilter   = 1_000
+	AdvisorRowsRemovedByFilterRatio = 0.9
+	AdvisorNestedLoopOuterRows      = 10_000
+)
+
+// Limits of the HypoPG what-if analysis
+const (
+	HypotheticalIndexMaxQueries    = 3
+	HypotheticalIndexMaxCandidates = 5
+	HypotheticalIndexResetTimeout  = 5 * time.Second
 )
 
 var (
@@ -19,6 +48,7 @@ var (
 )
 
 const (
+	PostgresVersion10 = 10
 	PostgresVersion11 = 11
 	PostgresVersion12 = 12
 	PostgresVersion13 = 13
@@ -9,11 +9,14 @@ import (
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	commonparams "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 )
 
 var (
-	typeCache   sync.Map // reflect.Type → []fieldDesc
-	entityCache sync.Map // "host:port" → *integration.Entity
+	typeCache sync.Map // reflect.Type → []fieldDesc
+	// ingestMu serializes ingestion from parallel collectors, as Publish serializes and clears the metric sets of
+	// every entity while they may still be filled
+	ingestMu sync.Mutex
 )
 
 type fieldDesc struct {
@@ -60,6 +63,17 @@ func ProcessModel(model interface{}, ms *metric.Set) error {
 		if fv.Kind() == reflect.Ptr {
 			fv = fv.Elem()
 		}
+		// Maps are reported as one metric per key, named after the field and the key
+		if fv.Kind() == reflect.Map {
+			iter := fv.MapRange()
+			for iter.Next() {
+				name := fmt.Sprintf("%s.%v", fd.name, iter.Key().Interface())
+				if err := ms.SetMetric(name, iter.Value().Interface(), fd.kind); err != nil {
+					log.Debug("setMetric %s: %v", name, err)
+				}
+			}
+			continue
+		}
 		if err := ms.SetMetric(fd.name, fv.Interface(), fd.kind); err != nil {
 			log.Debug("setMetric %s: %v", fd.name, err)
 		}
@@ -67,23 +81,30 @@ func ProcessModel(model interface{}, ms *metric.Set) error {
 	return nil
 }
 
+// CreateEntity returns the instance entity. It is not cached, as Publish removes the entities from pgInt.
 func CreateEntity(pgInt *integration.Integration, cp *commonparams.CommonParameters) (*integration.Entity, error) {
-	key := fmt.Sprintf("%s:%s", cp.Host, cp.Port)
-	if v, ok := entityCache.Load(key); ok {
-		return v.(*integration.Entity), nil
-	}
-	ent, err := pgInt.Entity(key, "pg-instance")
+	return pgInt.Entity(fmt.Sprintf("%s:%s", cp.Host, cp.Port), "pg-instance")
+}
+
+// IngestMetric adds one evt metric set per model of list to the instance entity and publishes them in batches. The
+// rows ingested and ingestion failures are recorded in the self-metrics of evt.
+func IngestMetric(list []interface{}, evt string, pgInt *integration.Integration, cp *commonparams.CommonParameters) error {
+	ingestMu.Lock()
+	defer ingestMu.Unlock()
+
+	rows, err := ingestMetric(list, evt, pgInt, cp)
+	selfmetrics.AddRows(evt, rows)
 	if err != nil {
-		return nil, err
+		selfmetrics.RecordError(evt, err)
 	}
-	entityCache.Store(key, ent)
-	return ent, nil
+	return err
 }
 
-func IngestMetric(list []interface{}, evt string, pgInt *integration.Integration, cp *commonparams.CommonParameters) error {
+func ingestMetric(list []interface{}, evt string, pgInt *integration.Integration, cp *commonparams.CommonParameters) (int, error) {
+	rows := 0
 	ent, err := CreateEntity(pgInt, cp)
 	if err != nil {
-		return err
+		return rows, err
 	}
 	batch := 0
 	for _, m := range list {
@@ -94,16 +115,17 @@ func IngestMetric(list []interface{}, evt string, pgInt *integration.Integration
 		if err := ProcessModel(m, ms); err != nil {
 			log.Error("ProcessModel: %v", err)
 		}
+		rows++
 		batch++
 		if batch >= PublishThreshold {
 			if err := pgInt.Publish(); err != nil {
-				return err
+				return rows, err
 			}
 			batch = 0
 		}
 	}
 	if batch > 0 {
-		return pgInt.Publish()
+		return rows, pgInt.Publish()
 	}
-	return nil
+	return rows, nil
 }
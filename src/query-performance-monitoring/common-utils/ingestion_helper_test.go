@@ -50,6 +50,19 @@ func TestCreateEntity(t *testing.T) {
 	assert.Equal(t, "localhost:5432", entity.Metadata.Name)
 }
 
+func TestCreateEntity_AfterClear(t *testing.T) {
+	pgIntegration, _ := integration.New("test", "1.0.0")
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{Hostname: "localhost", Port: "5432"}, uint64(14), "testdb")
+
+	_, err := commonutils.CreateEntity(pgIntegration, cp)
+	assert.NoError(t, err)
+	pgIntegration.Clear()
+
+	entity, err := commonutils.CreateEntity(pgIntegration, cp)
+	assert.NoError(t, err)
+	assert.Equal(t, []*integration.Entity{entity}, pgIntegration.Entities)
+}
+
 // TestProcessModel tests the ProcessModel function
 func TestProcessModel(t *testing.T) {
 	pgIntegration, _ := integration.New("test", "1.0.0")
@@ -88,3 +101,19 @@ func TestIngestMetricSimplified(t *testing.T) {
 	err := commonutils.IngestMetric(metricList, "testEvent", pgIntegration, cp)
 	assert.NoError(t, err)
 }
+
+func TestProcessModelWithMap(t *testing.T) {
+	pgIntegration, _ := integration.New("test", "1.0.0")
+	entity, _ := pgIntegration.Entity("test-entity", "test-type")
+	metricSet := entity.NewMetricSet("test-event")
+
+	model := struct {
+		Tags    map[string]string `metric_name:"query_tag" source_type:"attribute"`
+		NilTags map[string]string `metric_name:"other_tag" source_type:"attribute"`
+	}{Tags: map[string]string{"controller": "orders"}}
+
+	err := commonutils.ProcessModel(model, metricSet)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders", metricSet.Metrics["query_tag.controller"])
+	assert.Len(t, metricSet.Metrics, 2) // event_type and the single tag
+}
@@ -1,36 +1,80 @@
 package commonutils
 
-import "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+import (
+	"context"
+
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/newrelic/nri-postgresql/src/versions"
+)
+
+// The server versions of the query monitoring features
+var (
+	// QueryMonitoringVersions are the versions query monitoring supports
+	QueryMonitoringVersions = versions.Range{Min: "10"}
+	// ActivityQueryIDVersions have the query_id column in pg_stat_activity
+	ActivityQueryIDVersions = versions.Range{Min: "14"}
+	// ActivityBlockingVersions read blocking sessions from pg_stat_activity alone, so they do not need
+	// pg_stat_statements and their queries are not anonymized by the server
+	ActivityBlockingVersions = versions.Range{Min: "10", Max: "13"}
+)
+
+var slowQueries = []versions.Variant[string]{
+	{Range: versions.Range{Min: "10", Max: "12"}, Value: queries.SlowQueriesForV10ToV12},
+	{Range: versions.Range{Min: "13"}, Value: queries.SlowQueriesForV13AndAbove},
+}
+
+var blockingQueries = []versions.Variant[string]{
+	{Range: ActivityBlockingVersions, Value: queries.BlockingQueriesForV10ToV13},
+	{Range: versions.Range{Min: "14"}, Value: queries.BlockingQueriesForV14AndAbove},
+}
+
+var individualQueries = []versions.Variant[string]{
+	{Range: versions.Range{Min: "11", Max: "12"}, Value: queries.IndividualQuerySearchV11AndV12},
+	{Range: versions.Range{Min: "13"}, Value: queries.IndividualQuerySearchV13AndAbove},
+}
+
+var queryLoadByUserQueries = []versions.Variant[string]{
+	{Range: versions.Range{Min: "10", Max: "12"}, Value: queries.QueryLoadByUserForV10ToV12},
+	{Range: versions.Range{Min: "13"}, Value: queries.QueryLoadByUserForV13AndAbove},
+}
+
+// queryErrorLevels are the elevel of ERROR in pg_stat_monitor, which PostgreSQL 14 raised by adding
+// WARNING_CLIENT_ONLY before it
+var queryErrorLevels = []versions.Variant[int]{
+	{Range: versions.Range{Min: "11", Max: "13"}, Value: 20},
+	{Range: versions.Range{Min: "14"}, Value: 21},
+}
 
 func FetchVersionSpecificSlowQueries(v uint64) (string, error) {
-	switch {
-	case v == PostgresVersion12:
-		return queries.SlowQueriesForV12, nil
-	case v >= PostgresVersion13:
-		return queries.SlowQueriesForV13AndAbove, nil
-	default:
-		return "", ErrUnsupportedVersion
-	}
+	return fetchVersionSpecific(slowQueries, v)
 }
 
 func FetchVersionSpecificBlockingQueries(v uint64) (string, error) {
-	switch {
-	case v == PostgresVersion12 || v == PostgresVersion13:
-		return queries.BlockingQueriesForV12AndV13, nil
-	case v >= PostgresVersion14:
-		return queries.BlockingQueriesForV14AndAbove, nil
-	default:
-		return "", ErrUnsupportedVersion
-	}
+	return fetchVersionSpecific(blockingQueries, v)
 }
 
 func FetchVersionSpecificIndividualQueries(v uint64) (string, error) {
-	switch {
-	case v == PostgresVersion12:
-		return queries.IndividualQuerySearchV12, nil
-	case v > PostgresVersion12:
-		return queries.IndividualQuerySearchV13AndAbove, nil
-	default:
+	return fetchVersionSpecific(individualQueries, v)
+}
+
+func FetchVersionSpecificQueryLoadByUser(v uint64) (string, error) {
+	return fetchVersionSpecific(queryLoadByUserQueries, v)
+}
+
+// FetchVersionSpecificErrorLevel returns the lowest elevel of the statements counted as query errors
+func FetchVersionSpecificErrorLevel(v uint64) (int, error) {
+	errorLevel, ok := versions.First(context.Background(), queryErrorLevels, versions.Major(v), nil)
+	if !ok {
+		return 0, ErrUnsupportedVersion
+	}
+	return errorLevel, nil
+}
+
+// fetchVersionSpecific returns the query of the first variant whose range contains the major version v
+func fetchVersionSpecific(variants []versions.Variant[string], v uint64) (string, error) {
+	query, ok := versions.First(context.Background(), variants, versions.Major(v), nil)
+	if !ok {
 		return "", ErrUnsupportedVersion
 	}
+	return query, nil
 }
@@ -31,9 +31,11 @@ func TestFetchVersionSpecificSlowQueries(t *testing.T) {
 		expected  string
 		expectErr bool
 	}{
-		{commonutils.PostgresVersion12, queries.SlowQueriesForV12, false},
+		{commonutils.PostgresVersion10, queries.SlowQueriesForV10ToV12, false},
+		{commonutils.PostgresVersion11, queries.SlowQueriesForV10ToV12, false},
+		{commonutils.PostgresVersion12, queries.SlowQueriesForV10ToV12, false},
 		{commonutils.PostgresVersion13, queries.SlowQueriesForV13AndAbove, false},
-		{commonutils.PostgresVersion11, "", true},
+		{9, "", true},
 	}
 
 	runTestCases(t, tests, commonutils.FetchVersionSpecificSlowQueries)
@@ -45,10 +47,12 @@ func TestFetchVersionSpecificBlockingQueries(t *testing.T) {
 		expected  string
 		expectErr bool
 	}{
-		{commonutils.PostgresVersion12, queries.BlockingQueriesForV12AndV13, false},
-		{commonutils.PostgresVersion13, queries.BlockingQueriesForV12AndV13, false},
+		{commonutils.PostgresVersion10, queries.BlockingQueriesForV10ToV13, false},
+		{commonutils.PostgresVersion11, queries.BlockingQueriesForV10ToV13, false},
+		{commonutils.PostgresVersion12, queries.BlockingQueriesForV10ToV13, false},
+		{commonutils.PostgresVersion13, queries.BlockingQueriesForV10ToV13, false},
 		{commonutils.PostgresVersion14, queries.BlockingQueriesForV14AndAbove, false},
-		{commonutils.PostgresVersion11, "", true},
+		{9, "", true},
 	}
 
 	runTestCases(t, tests, commonutils.FetchVersionSpecificBlockingQueries)
@@ -60,11 +64,38 @@ func TestFetchVersionSpecificIndividualQueries(t *testing.T) {
 		expected  string
 		expectErr bool
 	}{
-		{commonutils.PostgresVersion12, queries.IndividualQuerySearchV12, false},
+		{commonutils.PostgresVersion11, queries.IndividualQuerySearchV11AndV12, false},
+		{commonutils.PostgresVersion12, queries.IndividualQuerySearchV11AndV12, false},
 		{commonutils.PostgresVersion13, queries.IndividualQuerySearchV13AndAbove, false},
 		{commonutils.PostgresVersion14, queries.IndividualQuerySearchV13AndAbove, false},
-		{commonutils.PostgresVersion11, "", true},
+		{commonutils.PostgresVersion10, "", true},
 	}
 
 	runTestCases(t, tests, commonutils.FetchVersionSpecificIndividualQueries)
 }
+
+func TestFetchVersionSpecificQueryLoadByUser(t *testing.T) {
+	tests := []struct {
+		version   uint64
+		expected  string
+		expectErr bool
+	}{
+		{commonutils.PostgresVersion10, queries.QueryLoadByUserForV10ToV12, false},
+		{commonutils.PostgresVersion12, queries.QueryLoadByUserForV10ToV12, false},
+		{commonutils.PostgresVersion13, queries.QueryLoadByUserForV13AndAbove, false},
+		{commonutils.PostgresVersion14, queries.QueryLoadByUserForV13AndAbove, false},
+		{9, "", true},
+	}
+
+	runTestCases(t, tests, commonutils.FetchVersionSpecificQueryLoadByUser)
+}
+
+func TestFetchVersionSpecificErrorLevel(t *testing.T) {
+	for version, expected := range map[uint64]int{11: 20, 13: 20, 14: 21, 17: 21} {
+		errorLevel, err := commonutils.FetchVersionSpecificErrorLevel(version)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, errorLevel, "PostgreSQL %d", version)
+	}
+	_, err := commonutils.FetchVersionSpecificErrorLevel(10)
+	assert.Error(t, err)
+}
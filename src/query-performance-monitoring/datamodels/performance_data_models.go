@@ -1,17 +1,19 @@
 package datamodels
 
 type SlowRunningQueryMetrics struct {
-	Newrelic            *string  `db:"newrelic"              metric_name:"newrelic"     source_type:"attribute" ingest_data:"false"`
-	QueryID             *string  `db:"query_id"              metric_name:"query_id"     source_type:"attribute"`
-	QueryText           *string  `db:"query_text"            metric_name:"query_text"   source_type:"attribute"`
-	DatabaseName        *string  `db:"database_name"         metric_name:"database_name"source_type:"attribute"`
-	SchemaName          *string  `db:"schema_name"           metric_name:"schema_name"  source_type:"attribute"`
-	ExecutionCount      *int64   `db:"execution_count"       metric_name:"execution_count" source_type:"gauge"`
-	AvgElapsedTimeMs    *float64 `db:"avg_elapsed_time_ms"   metric_name:"avg_elapsed_time_ms" source_type:"gauge"`
-	AvgDiskReads        *float64 `db:"avg_disk_reads"        metric_name:"avg_disk_reads" source_type:"gauge"`
-	AvgDiskWrites       *float64 `db:"avg_disk_writes"       metric_name:"avg_disk_writes" source_type:"gauge"`
-	StatementType       *string  `db:"statement_type"        metric_name:"statement_type" source_type:"attribute"`
-	CollectionTimestamp *string  `db:"collection_timestamp"  metric_name:"collection_timestamp" source_type:"attribute"`
+	Newrelic            *string           `db:"newrelic"             metric_name:"newrelic"             source_type:"attribute" ingest_data:"false"`
+	QueryID             *string           `db:"query_id"             metric_name:"query_id"             source_type:"attribute"`
+	QueryText           *string           `db:"query_text"           metric_name:"query_text"           source_type:"attribute"`
+	DatabaseName        *string           `db:"database_name"        metric_name:"database_name"        source_type:"attribute"`
+	SchemaName          *string           `db:"schema_name"          metric_name:"schema_name"          source_type:"attribute"`
+	UserName            *string           `db:"user_name"            metric_name:"user_name"            source_type:"attribute"`
+	ExecutionCount      *int64            `db:"execution_count"      metric_name:"execution_count"      source_type:"gauge"`
+	AvgElapsedTimeMs    *float64          `db:"avg_elapsed_time_ms"  metric_name:"avg_elapsed_time_ms"  source_type:"gauge"`
+	AvgDiskReads        *float64          `db:"avg_disk_reads"       metric_name:"avg_disk_reads"       source_type:"gauge"`
+	AvgDiskWrites       *float64          `db:"avg_disk_writes"      metric_name:"avg_disk_writes"      source_type:"gauge"`
+	StatementType       *string           `db:"statement_type"       metric_name:"statement_type"       source_type:"attribute"`
+	CollectionTimestamp *string           `db:"collection_timestamp" metric_name:"collection_timestamp" source_type:"attribute"`
+	QueryTags           map[string]string `db:"-"                    metric_name:"query_tag"            source_type:"attribute"`
 }
 
 type WaitEventMetrics struct {
@@ -25,44 +27,255 @@ type WaitEventMetrics struct {
 }
 
 type BlockingSessionMetrics struct {
-	Newrelic           *string `db:"newrelic"              metric_name:"newrelic"        source_type:"attribute" ingest_data:"false"`
-	BlockedPid         *int64  `db:"blocked_pid"           metric_name:"blocked_pid"     source_type:"gauge"`
-	BlockedQuery       *string `db:"blocked_query"         metric_name:"blocked_query"   source_type:"attribute"`
-	BlockedQueryID     *string `db:"blocked_query_id"      metric_name:"blocked_query_id" source_type:"attribute"`
-	BlockedQueryStart  *string `db:"blocked_query_start"   metric_name:"blocked_query_start" source_type:"attribute"`
-	BlockedDatabase    *string `db:"database_name"         metric_name:"database_name"   source_type:"attribute"`
-	BlockingPid        *int64  `db:"blocking_pid"          metric_name:"blocking_pid"    source_type:"gauge"`
-	BlockingQuery      *string `db:"blocking_query"        metric_name:"blocking_query"  source_type:"attribute"`
-	BlockingQueryID    *string `db:"blocking_query_id"     metric_name:"blocking_query_id" source_type:"attribute"`
-	BlockingQueryStart *string `db:"blocking_query_start"  metric_name:"blocking_query_start" source_type:"attribute"`
+	Newrelic           *string           `db:"newrelic"             metric_name:"newrelic"             source_type:"attribute" ingest_data:"false"`
+	BlockedPid         *int64            `db:"blocked_pid"          metric_name:"blocked_pid"          source_type:"gauge"`
+	BlockedQuery       *string           `db:"blocked_query"        metric_name:"blocked_query"        source_type:"attribute"`
+	BlockedQueryID     *string           `db:"blocked_query_id"     metric_name:"blocked_query_id"     source_type:"attribute"`
+	BlockedQueryStart  *string           `db:"blocked_query_start"  metric_name:"blocked_query_start"  source_type:"attribute"`
+	BlockedDatabase    *string           `db:"database_name"        metric_name:"database_name"        source_type:"attribute"`
+	BlockingPid        *int64            `db:"blocking_pid"         metric_name:"blocking_pid"         source_type:"gauge"`
+	BlockingQuery      *string           `db:"blocking_query"       metric_name:"blocking_query"       source_type:"attribute"`
+	BlockingQueryID    *string           `db:"blocking_query_id"    metric_name:"blocking_query_id"    source_type:"attribute"`
+	BlockingQueryStart *string           `db:"blocking_query_start" metric_name:"blocking_query_start" source_type:"attribute"`
+	BlockedQueryTags   map[string]string `db:"-"                    metric_name:"blocked_query_tag"    source_type:"attribute"`
+	BlockingQueryTags  map[string]string `db:"-"                    metric_name:"blocking_query_tag"   source_type:"attribute"`
+}
+
+type LongRunningSessionMetrics struct {
+	Newrelic              *string  `db:"newrelic"                metric_name:"newrelic"                source_type:"attribute" ingest_data:"false"`
+	Pid                   *int64   `db:"pid"                     metric_name:"pid"                     source_type:"gauge"`
+	UserName              *string  `db:"user_name"               metric_name:"user_name"               source_type:"attribute"`
+	ApplicationName       *string  `db:"application_name"        metric_name:"application_name"        source_type:"attribute"`
+	ClientAddress         *string  `db:"client_address"          metric_name:"client_address"          source_type:"attribute"`
+	DatabaseName          *string  `db:"database_name"           metric_name:"database_name"           source_type:"attribute"`
+	State                 *string  `db:"state"                   metric_name:"state"                   source_type:"attribute"`
+	WaitEventType         *string  `db:"wait_event_type"         metric_name:"wait_event_type"         source_type:"attribute"`
+	WaitEvent             *string  `db:"wait_event"              metric_name:"wait_event"              source_type:"attribute"`
+	BackendXminAge        *int64   `db:"backend_xmin_age"        metric_name:"backend_xmin_age"        source_type:"gauge"`
+	QueryDurationInMs     *float64 `db:"query_duration_ms"       metric_name:"query_duration_ms"       source_type:"gauge"`
+	TransactionDurationMs *float64 `db:"transaction_duration_ms" metric_name:"transaction_duration_ms" source_type:"gauge"`
+	QueryText             *string  `db:"query_text"              metric_name:"query_text"              source_type:"attribute"`
 }
 
 type IndividualQueryMetrics struct {
-	QueryText       *string  `db:"query"         metric_name:"query_text"     source_type:"attribute"`
-	QueryID         *string  `db:"queryid"       metric_name:"query_id"       source_type:"attribute"`
-	DatabaseName    *string  `db:"datname"       metric_name:"database_name"  source_type:"attribute"`
-	AvgCPUTimeInMS  *float64 `db:"cpu_time_ms"   metric_name:"cpu_time_ms"    source_type:"gauge"`
-	PlanID          *string  `db:"planid"        metric_name:"plan_id"        source_type:"attribute"`
-	RealQueryText   *string  `ingest_data:"false"`
-	AvgExecTimeInMs *float64 `db:"exec_time_ms"  metric_name:"exec_time_ms"    source_type:"gauge"`
-	Newrelic        *string  `db:"newrelic"      metric_name:"newrelic"        source_type:"attribute" ingest_data:"false"`
+	QueryText       *string           `db:"query"           metric_name:"query_text"      source_type:"attribute"`
+	QueryID         *string           `db:"queryid"         metric_name:"query_id"        source_type:"attribute"`
+	DatabaseName    *string           `db:"datname"         metric_name:"database_name"   source_type:"attribute"`
+	AvgCPUTimeInMS  *float64          `db:"cpu_time_ms"     metric_name:"cpu_time_ms"     source_type:"gauge"`
+	PlanID          *string           `db:"planid"          metric_name:"plan_id"         source_type:"attribute"`
+	RealQueryText   *string           `ingest_data:"false"`
+	AvgExecTimeInMs *float64          `db:"exec_time_ms"    metric_name:"exec_time_ms"    source_type:"gauge"`
+	Newrelic        *string           `db:"newrelic"        metric_name:"newrelic"        source_type:"attribute" ingest_data:"false"`
+	DurationInMs    *float64          `db:"duration_ms"     metric_name:"duration_ms"     source_type:"gauge"`
+	WaitEventType   *string           `db:"wait_event_type" metric_name:"wait_event_type" source_type:"attribute"`
+	WaitEvent       *string           `db:"wait_event"      metric_name:"wait_event"      source_type:"attribute"`
+	State           *string           `db:"state"           metric_name:"state"           source_type:"attribute"`
+	QueryTags       map[string]string `db:"-"               metric_name:"query_tag"       source_type:"attribute"`
 }
 
 type QueryExecutionPlanMetrics struct {
-	NodeType            string  `metric_name:"node_type"             source_type:"attribute"`
-	ParallelAware       bool    `metric_name:"parallel_aware"        source_type:"gauge"`
-	AsyncCapable        bool    `metric_name:"async_capable"         source_type:"gauge"`
-	ScanDirection       string  `metric_name:"scan_direction"        source_type:"attribute"`
-	IndexName           string  `metric_name:"index_name"            source_type:"attribute"`
-	RelationName        string  `metric_name:"relation_name"         source_type:"attribute"`
-	Alias               string  `metric_name:"alias"                 source_type:"attribute"`
-	StartupCost         float64 `metric_name:"startup_cost"          source_type:"gauge"`
-	TotalCost           float64 `metric_name:"total_cost"            source_type:"gauge"`
-	PlanRows            int64   `metric_name:"plan_rows"             source_type:"gauge"`
-	PlanWidth           int64   `metric_name:"plan_width"            source_type:"gauge"`
-	RowsRemovedByFilter int64   `metric_name:"rows_removed_by_filter" source_type:"gauge"`
-	DatabaseName        string  `metric_name:"database_name"         source_type:"attribute"`
-	QueryID             string  `metric_name:"query_id"              source_type:"attribute"`
-	PlanID              string  `metric_name:"plan_id"               source_type:"attribute"`
-	Level               int     `metric_name:"level_id"              source_type:"gauge"`
+	NodeType            string   `mapstructure:"Node Type"              metric_name:"node_type"              source_type:"attribute"`
+	ParallelAware       bool     `mapstructure:"Parallel Aware"         metric_name:"parallel_aware"         source_type:"gauge"`
+	AsyncCapable        bool     `mapstructure:"Async Capable"          metric_name:"async_capable"          source_type:"gauge"`
+	ScanDirection       string   `mapstructure:"Scan Direction"         metric_name:"scan_direction"         source_type:"attribute"`
+	IndexName           string   `mapstructure:"Index Name"             metric_name:"index_name"             source_type:"attribute"`
+	RelationName        string   `mapstructure:"Relation Name"          metric_name:"relation_name"          source_type:"attribute"`
+	Alias               string   `mapstructure:"Alias"                  metric_name:"alias"                  source_type:"attribute"`
+	StartupCost         float64  `mapstructure:"Startup Cost"           metric_name:"startup_cost"           source_type:"gauge"`
+	TotalCost           float64  `mapstructure:"Total Cost"             metric_name:"total_cost"             source_type:"gauge"`
+	PlanRows            int64    `mapstructure:"Plan Rows"              metric_name:"plan_rows"              source_type:"gauge"`
+	PlanWidth           int64    `mapstructure:"Plan Width"             metric_name:"plan_width"             source_type:"gauge"`
+	RowsRemovedByFilter int64    `mapstructure:"Rows Removed by Filter" metric_name:"rows_removed_by_filter" source_type:"gauge"`
+	JoinType            *string  `mapstructure:"Join Type"              metric_name:"join_type"              source_type:"attribute"`
+	Strategy            *string  `mapstructure:"Strategy"               metric_name:"strategy"               source_type:"attribute"`
+	HashCondition       *string  `mapstructure:"Hash Cond"              metric_name:"hash_condition"         source_type:"attribute"`
+	MergeCondition      *string  `mapstructure:"Merge Cond"             metric_name:"merge_condition"        source_type:"attribute"`
+	JoinFilter          *string  `mapstructure:"Join Filter"            metric_name:"join_filter"            source_type:"attribute"`
+	Filter              *string  `mapstructure:"Filter"                 metric_name:"filter"                 source_type:"attribute"`
+	IndexCondition      *string  `mapstructure:"Index Cond"             metric_name:"index_condition"        source_type:"attribute"`
+	RecheckCondition    *string  `mapstructure:"Recheck Cond"           metric_name:"recheck_condition"      source_type:"attribute"`
+	SortKey             *string  `mapstructure:"-"                      metric_name:"sort_key"               source_type:"attribute"`
+	ParentRelationship  *string  `mapstructure:"Parent Relationship"    metric_name:"parent_relationship"    source_type:"attribute"`
+	SubplanName         *string  `mapstructure:"Subplan Name"           metric_name:"subplan_name"           source_type:"attribute"`
+	SortMethod          *string  `mapstructure:"Sort Method"            metric_name:"sort_method"            source_type:"attribute"`
+	SortSpaceType       *string  `mapstructure:"Sort Space Type"        metric_name:"sort_space_type"        source_type:"attribute"`
+	SortSpaceUsed       *int64   `mapstructure:"Sort Space Used"        metric_name:"sort_space_used_kb"     source_type:"gauge"`
+	ActualStartupTime   *float64 `mapstructure:"Actual Startup Time"    metric_name:"actual_startup_time"    source_type:"gauge"`
+	ActualTotalTime     *float64 `mapstructure:"Actual Total Time"      metric_name:"actual_total_time"      source_type:"gauge"`
+	ActualRows          *int64   `mapstructure:"Actual Rows"            metric_name:"actual_rows"            source_type:"gauge"`
+	ActualLoops         *int64   `mapstructure:"Actual Loops"           metric_name:"actual_loops"           source_type:"gauge"`
+	SharedHitBlocks     *int64   `mapstructure:"Shared Hit Blocks"      metric_name:"shared_hit_blocks"      source_type:"gauge"`
+	SharedReadBlocks    *int64   `mapstructure:"Shared Read Blocks"     metric_name:"shared_read_blocks"     source_type:"gauge"`
+	SharedDirtiedBlocks *int64   `mapstructure:"Shared Dirtied Blocks"  metric_name:"shared_dirtied_blocks"  source_type:"gauge"`
+	SharedWrittenBlocks *int64   `mapstructure:"Shared Written Blocks"  metric_name:"shared_written_blocks"  source_type:"gauge"`
+	LocalHitBlocks      *int64   `mapstructure:"Local Hit Blocks"       metric_name:"local_hit_blocks"       source_type:"gauge"`
+	LocalReadBlocks     *int64   `mapstructure:"Local Read Blocks"      metric_name:"local_read_blocks"      source_type:"gauge"`
+	LocalDirtiedBlocks  *int64   `mapstructure:"Local Dirtied Blocks"   metric_name:"local_dirtied_blocks"   source_type:"gauge"`
+	LocalWrittenBlocks  *int64   `mapstructure:"Local Written Blocks"   metric_name:"local_written_blocks"   source_type:"gauge"`
+	TempReadBlocks      *int64   `mapstructure:"Temp Read Blocks"       metric_name:"temp_read_blocks"       source_type:"gauge"`
+	TempWrittenBlocks   *int64   `mapstructure:"Temp Written Blocks"    metric_name:"temp_written_blocks"    source_type:"gauge"`
+	DatabaseName        string   `mapstructure:"-"                      metric_name:"database_name"          source_type:"attribute"`
+	QueryID             string   `mapstructure:"-"                      metric_name:"query_id"               source_type:"attribute"`
+	PlanID              string   `mapstructure:"-"                      metric_name:"plan_id"                source_type:"attribute"`
+	Level               int      `mapstructure:"-"                      metric_name:"level_id"               source_type:"gauge"`
+	NodeID              int      `mapstructure:"-"                      metric_name:"node_id"                source_type:"gauge"`
+	ParentNodeID        *int     `mapstructure:"-"                      metric_name:"parent_node_id"         source_type:"gauge"`
+	Depth               int      `mapstructure:"-"                      metric_name:"depth"                  source_type:"gauge"`
+	PlanJSON            *string  `mapstructure:"-"                      metric_name:"plan_json"              source_type:"attribute"`
+	// PlanJSONParts holds the parts of a plan JSON longer than an attribute after the first, keyed by position
+	PlanJSONParts     map[string]string `mapstructure:"-"                      metric_name:"plan_json_part"         source_type:"attribute"`
+	PlanJSONPartCount *int              `mapstructure:"-"                      metric_name:"plan_json_parts"        source_type:"gauge"`
+	PlanJSONTruncated *bool             `mapstructure:"-"                      metric_name:"plan_json_truncated"    source_type:"gauge"`
+}
+
+type PlanChangeMetrics struct {
+	QueryID        *string  `metric_name:"query_id"         source_type:"attribute"`
+	QueryText      *string  `metric_name:"query_text"       source_type:"attribute"`
+	DatabaseName   *string  `metric_name:"database_name"    source_type:"attribute"`
+	OldPlanHash    *string  `metric_name:"old_plan_hash"    source_type:"attribute"`
+	NewPlanHash    *string  `metric_name:"new_plan_hash"    source_type:"attribute"`
+	OldStartupCost *float64 `metric_name:"old_startup_cost" source_type:"gauge"`
+	NewStartupCost *float64 `metric_name:"new_startup_cost" source_type:"gauge"`
+	OldTotalCost   *float64 `metric_name:"old_total_cost"   source_type:"gauge"`
+	NewTotalCost   *float64 `metric_name:"new_total_cost"   source_type:"gauge"`
+}
+
+type QueryRecommendationMetrics struct {
+	QueryID            *string `metric_name:"query_id"            source_type:"attribute"`
+	QueryText          *string `metric_name:"query_text"          source_type:"attribute"`
+	DatabaseName       *string `metric_name:"database_name"       source_type:"attribute"`
+	PlanID             *string `metric_name:"plan_id"             source_type:"attribute"`
+	NodeID             *int    `metric_name:"node_id"             source_type:"gauge"`
+	NodeType           *string `metric_name:"node_type"           source_type:"attribute"`
+	RecommendationType *string `metric_name:"recommendation_type" source_type:"attribute"`
+	Severity           *string `metric_name:"severity"            source_type:"attribute"`
+	RelationName       *string `metric_name:"relation_name"       source_type:"attribute"`
+	ColumnName         *string `metric_name:"column_name"         source_type:"attribute"`
+	Message            *string `metric_name:"message"             source_type:"attribute"`
+}
+
+type HypotheticalIndexMetrics struct {
+	QueryID               *string  `metric_name:"query_id"                source_type:"attribute"`
+	QueryText             *string  `metric_name:"query_text"              source_type:"attribute"`
+	DatabaseName          *string  `metric_name:"database_name"           source_type:"attribute"`
+	PlanID                *string  `metric_name:"plan_id"                 source_type:"attribute"`
+	SchemaName            *string  `metric_name:"schema_name"             source_type:"attribute"`
+	RelationName          *string  `metric_name:"relation_name"           source_type:"attribute"`
+	ColumnName            *string  `metric_name:"column_name"             source_type:"attribute"`
+	IndexDefinition       *string  `metric_name:"index_definition"        source_type:"attribute"`
+	IndexUsed             *bool    `metric_name:"index_used"              source_type:"gauge"`
+	OriginalTotalCost     *float64 `metric_name:"original_total_cost"     source_type:"gauge"`
+	HypotheticalTotalCost *float64 `metric_name:"hypothetical_total_cost" source_type:"gauge"`
+	CostReductionPercent  *float64 `metric_name:"cost_reduction_percent"  source_type:"gauge"`
+}
+
+type QueryLoadTotals struct {
+	Newrelic          *string `db:"newrelic"`
+	UserName          string  `db:"user_name"`
+	DatabaseName      string  `db:"database_name"`
+	Calls             int64   `db:"calls"`
+	TotalExecTimeInMs float64 `db:"total_exec_time_ms"`
+	SharedBlksHit     int64   `db:"shared_blks_hit"`
+	SharedBlksRead    int64   `db:"shared_blks_read"`
+	SharedBlksWritten int64   `db:"shared_blks_written"`
+	TempBlksRead      int64   `db:"temp_blks_read"`
+	TempBlksWritten   int64   `db:"temp_blks_written"`
+}
+
+type QueryLoadApplicationSample struct {
+	Newrelic        *string `db:"newrelic"`
+	UserName        string  `db:"user_name"`
+	DatabaseName    string  `db:"database_name"`
+	ApplicationName string  `db:"application_name"`
+	ActiveSessions  int64   `db:"active_sessions"`
+}
+
+type QueryLoadByUserMetrics struct {
+	UserName               *string  `metric_name:"user_name"                source_type:"attribute"`
+	DatabaseName           *string  `metric_name:"database_name"            source_type:"attribute"`
+	ApplicationName        *string  `metric_name:"application_name"         source_type:"attribute"`
+	ApplicationShare       *float64 `metric_name:"application_share"        source_type:"gauge"`
+	ApplicationShareSource *string  `metric_name:"application_share_source" source_type:"attribute"`
+	IntervalSeconds        *float64 `metric_name:"interval_seconds"         source_type:"gauge"`
+	Calls                  *float64 `metric_name:"calls"                    source_type:"gauge"`
+	ExecTimeInMs           *float64 `metric_name:"exec_time_ms"             source_type:"gauge"`
+	SharedBlksHit          *float64 `metric_name:"shared_blks_hit"          source_type:"gauge"`
+	SharedBlksRead         *float64 `metric_name:"shared_blks_read"         source_type:"gauge"`
+	SharedBlksWritten      *float64 `metric_name:"shared_blks_written"      source_type:"gauge"`
+	TempBlksRead           *float64 `metric_name:"temp_blks_read"           source_type:"gauge"`
+	TempBlksWritten        *float64 `metric_name:"temp_blks_written"        source_type:"gauge"`
+}
+
+type QueryLatencyHistogramBin struct {
+	Newrelic        *string `db:"newrelic"`
+	BucketID        int64   `db:"bucket_id"`
+	BucketStartTime int64   `db:"bucket_start_time"`
+	QueryID         string  `db:"query_id"`
+	DatabaseName    string  `db:"database_name"`
+	Bin             int     `db:"bin"`
+	Calls           int64   `db:"calls"`
+}
+
+type QueryLatencyHistogramMetrics struct {
+	QueryID         *string          `metric_name:"query_id"          source_type:"attribute"`
+	QueryText       *string          `metric_name:"query_text"        source_type:"attribute"`
+	DatabaseName    *string          `metric_name:"database_name"     source_type:"attribute"`
+	BucketID        *int64           `metric_name:"bucket_id"         source_type:"attribute"`
+	BucketStartTime *int64           `metric_name:"bucket_start_time" source_type:"attribute"`
+	Calls           *int64           `metric_name:"calls"             source_type:"gauge"`
+	P50InMs         *float64         `metric_name:"p50_ms"            source_type:"gauge"`
+	P95InMs         *float64         `metric_name:"p95_ms"            source_type:"gauge"`
+	P99InMs         *float64         `metric_name:"p99_ms"            source_type:"gauge"`
+	RespCalls       map[string]int64 `metric_name:"resp_calls"        source_type:"gauge"`
+}
+
+type QueryErrorMetrics struct {
+	Newrelic        *string `db:"newrelic"          metric_name:"newrelic"          source_type:"attribute" ingest_data:"false"`
+	BucketStartTime *int64  `db:"bucket_start_time" metric_name:"bucket_start_time" source_type:"attribute"`
+	QueryID         *string `db:"query_id"          metric_name:"query_id"          source_type:"attribute"`
+	QueryText       *string `db:"query_text"        metric_name:"query_text"        source_type:"attribute"`
+	DatabaseName    *string `db:"database_name"     metric_name:"database_name"     source_type:"attribute"`
+	SQLState        *string `db:"sqlstate"          metric_name:"sqlstate"          source_type:"attribute"`
+	SQLStateClass   *string `db:"-"                 metric_name:"sqlstate_class"    source_type:"attribute"`
+	Message         *string `db:"message"           metric_name:"message"           source_type:"attribute"`
+	ErrorCount      *int64  `db:"error_count"       metric_name:"error_count"       source_type:"gauge"`
+	Source          *string `db:"-"                 metric_name:"source"            source_type:"attribute"`
+}
+
+type TempSpillQueryTotals struct {
+	Newrelic        *string `db:"newrelic"`
+	QueryID         string  `db:"query_id"`
+	QueryText       string  `db:"query_text"`
+	DatabaseName    string  `db:"database_name"`
+	Calls           int64   `db:"calls"`
+	TempBlksRead    int64   `db:"temp_blks_read"`
+	TempBlksWritten int64   `db:"temp_blks_written"`
+}
+
+type TempSpillDatabaseTotals struct {
+	DatabaseName string `db:"database_name"`
+	TempFiles    int64  `db:"temp_files"`
+	TempBytes    int64  `db:"temp_bytes"`
+}
+
+type TempSpillSettings struct {
+	WorkMemInKB int64 `db:"work_mem_kb"`
+	BlockSize   int64 `db:"block_size"`
+}
+
+type TempSpillMetrics struct {
+	QueryID                 *string  `metric_name:"query_id"                    source_type:"attribute"`
+	QueryText               *string  `metric_name:"query_text"                  source_type:"attribute"`
+	DatabaseName            *string  `metric_name:"database_name"               source_type:"attribute"`
+	IntervalSeconds         *float64 `metric_name:"interval_seconds"            source_type:"gauge"`
+	Calls                   *int64   `metric_name:"calls"                       source_type:"gauge"`
+	TempBlksRead            *int64   `metric_name:"temp_blks_read"              source_type:"gauge"`
+	TempBlksWritten         *int64   `metric_name:"temp_blks_written"           source_type:"gauge"`
+	TempWrittenBytes        *int64   `metric_name:"temp_written_bytes"          source_type:"gauge"`
+	TempWrittenBytesPerCall *float64 `metric_name:"temp_written_bytes_per_call" source_type:"gauge"`
+	WorkMemInKB             *int64   `metric_name:"work_mem_kb"                 source_type:"gauge"`
+	SpillToWorkMemRatio     *float64 `metric_name:"spill_to_work_mem_ratio"     source_type:"gauge"`
+	DatabaseTempFiles       *int64   `metric_name:"database_temp_files"         source_type:"gauge"`
+	DatabaseTempBytes       *int64   `metric_name:"database_temp_bytes"         source_type:"gauge"`
+	DatabaseTempShare       *float64 `metric_name:"database_temp_share"         source_type:"gauge"`
 }
@@ -9,8 +9,8 @@ import (
 
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
-	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/selfmetrics"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
@@ -30,6 +30,7 @@ func PopulateBlockingMetrics(ctx context.Context, conn *performancedbconnection.
 	blockingQueriesMetricsList, blockQueryFetchErr := getBlockingMetrics(ctx, conn, cp)
 	if blockQueryFetchErr != nil {
 		log.Error("Error fetching Blocking queries: %v", blockQueryFetchErr)
+		selfmetrics.RecordError("PostgresBlockingSessions", blockQueryFetchErr)
 		return
 	}
 	if len(blockingQueriesMetricsList) == 0 {
@@ -56,20 +57,29 @@ func getBlockingMetrics(ctx context.Context, conn *performancedbconnection.PGSQL
 		log.Error("Unsupported postgres version: %v", err)
 		return nil, err
 	}
-	var query = fmt.Sprintf(versionSpecificBlockingQuery, cp.Databases, cp.QueryMonitoringCountThreshold)
+	var query = fmt.Sprintf(versionSpecificBlockingQuery, cp.Databases, cp.QueryFilter.FetchLimit(cp.QueryMonitoringCountThreshold))
 	rows, err := conn.QueryxContext(ctx, query)
 	if err != nil {
 		log.Error("Failed to execute query: %v", err)
 		return nil, commonutils.ErrUnExpectedError
 	}
 	defer rows.Close()
-	for rows.Next() {
+	for len(blockingQueriesMetricsList) < cp.QueryMonitoringCountThreshold && rows.Next() {
 		var blockingQueryMetric datamodels.BlockingSessionMetrics
 		if scanError := rows.StructScan(&blockingQueryMetric); scanError != nil {
 			return nil, scanError
 		}
-		// For PostgreSQL versions 13 and 12, anonymization of queries does not occur for blocking sessions, so it's necessary to explicitly anonymize them.
-		if cp.Version == commonutils.PostgresVersion13 || cp.Version == commonutils.PostgresVersion12 {
+		if !cp.QueryFilter.Allows(commonparameters.QueryAttributes{QueryID: blockingQueryMetric.BlockedQueryID, QueryText: anonymizedQueryText(blockingQueryMetric.BlockedQuery), Database: blockingQueryMetric.BlockedDatabase}) {
+			continue
+		}
+		if blockingQueryMetric.BlockedQuery != nil {
+			blockingQueryMetric.BlockedQueryTags = commonutils.ExtractQueryTags(*blockingQueryMetric.BlockedQuery)
+		}
+		if blockingQueryMetric.BlockingQuery != nil {
+			blockingQueryMetric.BlockingQueryTags = commonutils.ExtractQueryTags(*blockingQueryMetric.BlockingQuery)
+		}
+		// For PostgreSQL versions 10 to 13, anonymization of queries does not occur for blocking sessions, so it's necessary to explicitly anonymize them.
+		if commonutils.ActivityBlockingVersions.ContainsMajor(cp.Version) {
 			*blockingQueryMetric.BlockedQuery = commonutils.AnonymizeQueryText(*blockingQueryMetric.BlockedQuery)
 			*blockingQueryMetric.BlockingQuery = commonutils.AnonymizeQueryText(*blockingQueryMetric.BlockingQuery)
 		}
@@ -26,8 +26,8 @@ func TestGetBlockingMetrics(t *testing.T) {
 	cp := common_parameters.SetCommonParameters(args, version, databaseName)
 	ctx := context.Background()
 	
-	expectedQuery := queries.BlockingQueriesForV12AndV13
-	query := fmt.Sprintf(expectedQuery, databaseName, args.QueryMonitoringCountThreshold)
+	expectedQuery := queries.BlockingQueriesForV10ToV13
+	query := fmt.Sprintf(expectedQuery, databaseName, cp.QueryFilter.FetchLimit(args.QueryMonitoringCountThreshold))
 	rowData := []driver.Value{
 		"newrelic_value", int64(123), "SELECT 1", "1233444", "2023-01-01 00:00:00", "testdb",
 		int64(456), "SELECT 2", "4566", "2023-01-01 00:00:00",
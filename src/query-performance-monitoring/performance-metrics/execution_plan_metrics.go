@@ -3,37 +3,71 @@ package performancemetrics
 import (
 	"context"
 	"encoding/json"
-	"time"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
 	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
-	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/selfmetrics"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 )
 
-func PopulateExecutionPlanMetrics(ctx context.Context, results []datamodels.IndividualQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, connectionInfo performancedbconnection.Info) {
+// planConditionKeys are the plan node fields that can hold literals from the query text
+var planConditionKeys = []string{"Hash Cond", "Merge Cond", "Join Filter", "Filter", "Index Cond", "Recheck Cond", "Sort Key", "Output"}
+
+func PopulateExecutionPlanMetrics(ctx context.Context, results []datamodels.IndividualQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, connectionInfo performancedbconnection.Info, planStore persist.Storer, enabledExtensions map[string]bool) {
 	if len(results) == 0 {
 		log.Debug("No individual queries found.")
 		return
 	}
-	
+
 	// Increment self-metrics counter
 	selfmetrics.IncQueries()
-	
-	executionDetailsList := getExecutionPlanMetrics(ctx, results, connectionInfo)
+
+	evaluateIndexes, _ := validations.CheckHypotheticalIndexFetchEligibility(enabledExtensions)
+	executionDetailsList, planChangeList, hypotheticalIndexList := getExecutionPlanMetrics(ctx, results, cp, connectionInfo, planStore, evaluateIndexes)
+	setPlanIDs(results, executionDetailsList)
 	err := commonutils.IngestMetric(executionDetailsList, "PostgresExecutionPlanMetrics", pgIntegration, cp)
 	if err != nil {
 		log.Error("Error ingesting Execution Plan metrics: %v", err)
 		return
 	}
+	if err := planStore.Save(); err != nil {
+		log.Error("Error saving plan history: %v", err)
+	}
+	recommendationList := analyzeExecutionPlans(executionDetailsList, groupQueryTextsByDatabase(results))
+	if len(recommendationList) > 0 {
+		err = commonutils.IngestMetric(recommendationList, "PostgresQueryRecommendation", pgIntegration, cp)
+		if err != nil {
+			log.Error("Error ingesting query recommendations: %v", err)
+		}
+	}
+	if len(planChangeList) > 0 {
+		err = commonutils.IngestMetric(planChangeList, "PostgresPlanChange", pgIntegration, cp)
+		if err != nil {
+			log.Error("Error ingesting plan changes: %v", err)
+		}
+	}
+	if len(hypotheticalIndexList) > 0 {
+		err = commonutils.IngestMetric(hypotheticalIndexList, "PostgresHypotheticalIndex", pgIntegration, cp)
+		if err != nil {
+			log.Error("Error ingesting hypothetical indexes: %v", err)
+		}
+	}
 }
 
-func getExecutionPlanMetrics(ctx context.Context, results []datamodels.IndividualQueryMetrics, connectionInfo performancedbconnection.Info) []interface{} {
+func getExecutionPlanMetrics(ctx context.Context, results []datamodels.IndividualQueryMetrics, cp *commonparameters.CommonParameters, connectionInfo performancedbconnection.Info, planStore persist.Storer, evaluateIndexes bool) ([]interface{}, []interface{}, []interface{}) {
 	var executionPlanMetricsList []interface{}
+	var planChangeList []interface{}
+	var hypotheticalIndexList []interface{}
 	var groupIndividualQueriesByDatabase = groupQueriesByDatabase(results)
 	for dbName, individualQueriesList := range groupIndividualQueriesByDatabase {
 		dbConn, err := connectionInfo.NewConnection(dbName)
@@ -41,62 +75,193 @@ func getExecutionPlanMetrics(ctx context.Context, results []datamodels.Individua
 			log.Error("Error opening database connection: %v", err)
 			continue
 		}
-		processExecutionPlanOfQueries(ctx, individualQueriesList, dbConn, &executionPlanMetricsList)
+		candidatesByQuery := processExecutionPlanOfQueries(ctx, individualQueriesList, dbConn, cp, planStore, &executionPlanMetricsList, &planChangeList)
+		if evaluateIndexes {
+			hypotheticalIndexList = append(hypotheticalIndexList, evaluateHypotheticalIndexes(ctx, dbConn, individualQueriesList, executionPlanMetricsList, candidatesByQuery)...)
+		}
 		dbConn.Close()
 	}
 
-	return executionPlanMetricsList
+	return executionPlanMetricsList, planChangeList, hypotheticalIndexList
 }
 
-func processExecutionPlanOfQueries(ctx context.Context, individualQueriesList []datamodels.IndividualQueryMetrics, dbConn *performancedbconnection.PGSQLConnection, executionPlanMetricsList *[]interface{}) {
+// processExecutionPlanOfQueries captures or reads from the cache the plan of each query. It returns the hypothetical
+// index candidates of the plans captured in this run by query ID, read before the plans are anonymized.
+func processExecutionPlanOfQueries(ctx context.Context, individualQueriesList []datamodels.IndividualQueryMetrics, dbConn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters, planStore persist.Storer, executionPlanMetricsList *[]interface{}, planChangeList *[]interface{}) map[string][]indexCandidate {
+	candidatesByQuery := make(map[string][]indexCandidate)
 	for _, individualQuery := range individualQueriesList {
 		if individualQuery.RealQueryText == nil || individualQuery.QueryID == nil || individualQuery.DatabaseName == nil {
 			log.Error("QueryText, QueryID or Database Name is nil")
 			continue
 		}
-		
-		// Create a timeout context for each query
-		queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		defer cancel()
-		
-		query := "EXPLAIN (FORMAT JSON) " + *individualQuery.RealQueryText
-		rows, err := dbConn.QueryxContext(queryCtx, query)
-		if err != nil {
-			log.Debug("Error executing query: %v", err)
-			continue
-		}
-		defer rows.Close()
-		if !rows.Next() {
-			log.Debug("Execution plan not found for queryId", *individualQuery.QueryID)
-			continue
-		}
-		var execPlanJSON string
-		if scanErr := rows.Scan(&execPlanJSON); scanErr != nil {
-			log.Error("Error scanning row: ", scanErr.Error())
-			continue
+
+		execPlanJSON, cached := cachedExecutionPlan(planStore, individualQuery, cp.ExplainInterval)
+		if !cached {
+			// Create a timeout context for each query
+			queryCtx, cancel := context.WithTimeout(ctx, ExplainTimeout(cp))
+			var err error
+			execPlanJSON, err = fetchExecutionPlan(queryCtx, dbConn, *individualQuery.RealQueryText, cp)
+			cancel()
+			if err != nil {
+				log.Debug("Error fetching execution plan for queryId %s: %v", *individualQuery.QueryID, err)
+				selfmetrics.RecordError("PostgresExecutionPlanMetrics", err)
+				continue
+			}
+			selfmetrics.IncPlans()
 		}
 
 		var execPlan []map[string]interface{}
-		err = json.Unmarshal([]byte(execPlanJSON), &execPlan)
+		err := json.Unmarshal([]byte(execPlanJSON), &execPlan)
 		if err != nil {
 			log.Error("Failed to unmarshal execution plan: %v", err)
 			continue
 		}
-		validateAndFetchNestedExecPlan(execPlan, individualQuery, executionPlanMetricsList)
+		if rawPlan, ok := rootPlan(execPlan); ok && !cached {
+			candidatesByQuery[*individualQuery.QueryID] = hypotheticalIndexCandidates(rawPlan)
+		}
+		if !cached {
+			cacheExecutionPlan(planStore, individualQuery, execPlan)
+		}
+		plan := validateAndFetchNestedExecPlan(execPlan, &individualQuery, executionPlanMetricsList)
+		if plan == nil {
+			continue
+		}
+		if planChange := recordPlan(planStore, individualQuery, plan); planChange != nil {
+			*planChangeList = append(*planChangeList, *planChange)
+		}
+	}
+	return candidatesByQuery
+}
+
+// rootPlan returns the root node of an execution plan
+func rootPlan(execPlan []map[string]interface{}) (map[string]interface{}, bool) {
+	if len(execPlan) == 0 {
+		return nil, false
 	}
+	plan, ok := execPlan[0]["Plan"].(map[string]interface{})
+	return plan, ok
 }
 
-func validateAndFetchNestedExecPlan(execPlan []map[string]interface{}, individualQuery datamodels.IndividualQueryMetrics, executionPlanMetricsList *[]interface{}) {
-	level := 0
+// validateAndFetchNestedExecPlan sets the plan hash of individualQuery, flattens the plan tree into
+// executionPlanMetricsList and returns its root node, or nil if the plan could not be read
+func validateAndFetchNestedExecPlan(execPlan []map[string]interface{}, individualQuery *datamodels.IndividualQueryMetrics, executionPlanMetricsList *[]interface{}) map[string]interface{} {
 	if len(execPlan) > 0 {
 		if plan, ok := execPlan[0]["Plan"].(map[string]interface{}); ok {
-			fetchNestedExecutionPlanDetails(individualQuery, &level, plan, executionPlanMetricsList)
+			anonymizeExecutionPlan(plan)
+			planHash := commonutils.GeneratePlanHash(plan)
+			individualQuery.PlanID = &planHash
+			rootIndex := len(*executionPlanMetricsList)
+			nodeID := 0
+			fetchNestedExecutionPlanDetails(*individualQuery, &nodeID, 0, nil, plan, executionPlanMetricsList)
+			if rootNode, rootOk := (*executionPlanMetricsList)[rootIndex].(datamodels.QueryExecutionPlanMetrics); rootOk {
+				setPlanJSON(&rootNode, execPlan)
+				(*executionPlanMetricsList)[rootIndex] = rootNode
+			}
+			return plan
 		} else {
 			log.Debug("execPlan is not in correct datatype")
 		}
 	} else {
 		log.Debug("execPlan is empty")
 	}
+	return nil
+}
+
+// anonymizeExecutionPlan replaces the literals in the conditions of every node of the plan tree
+func anonymizeExecutionPlan(plan map[string]interface{}) {
+	for _, key := range planConditionKeys {
+		switch value := plan[key].(type) {
+		case string:
+			plan[key] = commonutils.AnonymizePlanText(value)
+		case []interface{}:
+			for i, item := range value {
+				if itemString, ok := item.(string); ok {
+					value[i] = commonutils.AnonymizePlanText(itemString)
+				}
+			}
+		}
+	}
+	if nestedPlans, ok := plan["Plans"].([]interface{}); ok {
+		for _, nestedPlan := range nestedPlans {
+			if nestedPlanMap, nestedOk := nestedPlan.(map[string]interface{}); nestedOk {
+				anonymizeExecutionPlan(nestedPlanMap)
+			}
+		}
+	}
+}
+
+// setPlanJSON sets the compact JSON of the plan on the root node. A plan longer than an attribute is split in
+// parts: plan_json holds the first one and plan_json_part.N the following ones, in order. A plan that needs more
+// than MaxPlanJSONParts parts is cut and flagged with plan_json_truncated.
+func setPlanJSON(rootNode *datamodels.QueryExecutionPlanMetrics, execPlan []map[string]interface{}) {
+	planJSON, err := json.Marshal(execPlan)
+	if err != nil {
+		log.Debug("Failed to marshal execution plan: %v", err)
+		return
+	}
+	parts := splitPlanJSON(planJSON)
+	truncated := len(parts) > commonutils.MaxPlanJSONParts
+	if truncated {
+		log.Debug("Execution plan JSON of %d bytes exceeds %d attributes, truncating it", len(planJSON), commonutils.MaxPlanJSONParts)
+		parts = parts[:commonutils.MaxPlanJSONParts]
+	}
+	partCount := len(parts)
+	rootNode.PlanJSON = &parts[0]
+	rootNode.PlanJSONPartCount = &partCount
+	rootNode.PlanJSONTruncated = &truncated
+	for i, part := range parts[1:] {
+		if rootNode.PlanJSONParts == nil {
+			rootNode.PlanJSONParts = make(map[string]string)
+		}
+		rootNode.PlanJSONParts[strconv.Itoa(i+1)] = part
+	}
+}
+
+// splitPlanJSON splits planJSON in parts of at most MaxPlanJSONLength bytes, without splitting a UTF-8 character
+func splitPlanJSON(planJSON []byte) []string {
+	var parts []string
+	for len(planJSON) > commonutils.MaxPlanJSONLength {
+		end := commonutils.MaxPlanJSONLength
+		for end > 0 && !utf8.RuneStart(planJSON[end]) {
+			end--
+		}
+		parts = append(parts, string(planJSON[:end]))
+		planJSON = planJSON[end:]
+	}
+	return append(parts, string(planJSON))
+}
+
+// setPlanIDs sets the plan hash of the root node of each captured plan on its query in results
+func setPlanIDs(results []datamodels.IndividualQueryMetrics, executionPlanMetricsList []interface{}) {
+	planIDs := make(map[string]string)
+	for _, node := range executionPlanMetricsList {
+		if planNode, ok := node.(datamodels.QueryExecutionPlanMetrics); ok && planNode.ParentNodeID == nil {
+			planIDs[planHistoryKey(planNode.DatabaseName, planNode.QueryID)] = planNode.PlanID
+		}
+	}
+	for i := range results {
+		if results[i].DatabaseName == nil || results[i].QueryID == nil {
+			continue
+		}
+		if planID, ok := planIDs[planHistoryKey(*results[i].DatabaseName, *results[i].QueryID)]; ok {
+			results[i].PlanID = &planID
+		}
+	}
+}
+
+func groupQueryTextsByDatabase(results []datamodels.IndividualQueryMetrics) databaseQueryInfoMap {
+	queryTextsByDB := make(databaseQueryInfoMap)
+	for _, individualQueryMetric := range results {
+		if individualQueryMetric.DatabaseName == nil || individualQueryMetric.QueryID == nil || individualQueryMetric.QueryText == nil {
+			continue
+		}
+		dbName := *individualQueryMetric.DatabaseName
+		if _, exists := queryTextsByDB[dbName]; !exists {
+			queryTextsByDB[dbName] = make(queryInfoMap)
+		}
+		queryTextsByDB[dbName][*individualQueryMetric.QueryID] = *individualQueryMetric.QueryText
+	}
+	return queryTextsByDB
 }
 
 func groupQueriesByDatabase(results []datamodels.IndividualQueryMetrics) map[string][]datamodels.IndividualQueryMetrics {
@@ -111,24 +276,42 @@ func groupQueriesByDatabase(results []datamodels.IndividualQueryMetrics) map[str
 	return databaseMap
 }
 
-func fetchNestedExecutionPlanDetails(individualQuery datamodels.IndividualQueryMetrics, level *int, execPlan map[string]interface{}, executionPlanMetricsList *[]interface{}) {
+// fetchNestedExecutionPlanDetails appends the plan nodes in pre-order. nodeID is the pre-order index of the
+// next node, depth is the distance from the root and parentNodeID is nil for the root node.
+func fetchNestedExecutionPlanDetails(individualQuery datamodels.IndividualQueryMetrics, nodeID *int, depth int, parentNodeID *int, execPlan map[string]interface{}, executionPlanMetricsList *[]interface{}) {
 	var execPlanMetrics datamodels.QueryExecutionPlanMetrics
 	err := mapstructure.Decode(execPlan, &execPlanMetrics)
 	if err != nil {
 		log.Error("Failed to decode execPlan to execPlanMetrics: %v", err)
 		return
 	}
+	if sortKeys, ok := execPlan["Sort Key"].([]interface{}); ok {
+		sortKey := joinPlanValues(sortKeys)
+		execPlanMetrics.SortKey = &sortKey
+	}
+	currentNodeID := *nodeID
+	*nodeID++
 	execPlanMetrics.QueryID = *individualQuery.QueryID
 	execPlanMetrics.DatabaseName = *individualQuery.DatabaseName
-	execPlanMetrics.Level = *level
-	*level++
+	execPlanMetrics.NodeID = currentNodeID
+	execPlanMetrics.Level = currentNodeID
+	execPlanMetrics.ParentNodeID = parentNodeID
+	execPlanMetrics.Depth = depth
 	execPlanMetrics.PlanID = *individualQuery.PlanID
 	*executionPlanMetricsList = append(*executionPlanMetricsList, execPlanMetrics)
 	if nestedPlans, ok := execPlan["Plans"].([]interface{}); ok {
 		for _, nestedPlan := range nestedPlans {
 			if nestedPlanMap, nestedOk := nestedPlan.(map[string]interface{}); nestedOk {
-				fetchNestedExecutionPlanDetails(individualQuery, level, nestedPlanMap, executionPlanMetricsList)
+				fetchNestedExecutionPlanDetails(individualQuery, nodeID, depth+1, &currentNodeID, nestedPlanMap, executionPlanMetricsList)
 			}
 		}
 	}
 }
+
+func joinPlanValues(values []interface{}) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, fmt.Sprint(value))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -1,17 +1,25 @@
 package performancemetrics
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
 	"testing"
 
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
 
 	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
 	"github.com/newrelic/nri-postgresql/src/args"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
 func TestPopulateExecutionPlanMetrics(t *testing.T) {
@@ -21,11 +29,79 @@ func TestPopulateExecutionPlanMetrics(t *testing.T) {
 	cp := common_parameters.SetCommonParameters(args, uint64(13), "testdb")
 	connectionInfo := performancedbconnection.DefaultConnectionInfo(&args)
 	ctx := context.Background()
-	
-	PopulateExecutionPlanMetrics(ctx, results, pgIntegration, cp, connectionInfo)
+
+	PopulateExecutionPlanMetrics(ctx, results, pgIntegration, cp, connectionInfo, persist.NewInMemoryStore(), map[string]bool{})
 	assert.Empty(t, pgIntegration.Entities)
 }
 
+func TestPopulateExecutionPlanMetrics_IndividualQueryPlanID(t *testing.T) {
+	var output bytes.Buffer
+	pgIntegration, _ := integration.New("test", "1.0.0", integration.Writer(&output))
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{}, uint64(16), "testdb")
+	conn, mock := performancedbconnection.CreateMockSQL(t)
+	connectionInfo := &performancedbconnection.MockInfo{}
+	connectionInfo.On("NewConnection", "testdb").Return(conn, nil)
+
+	mock.ExpectQuery(regexp.QuoteMeta(explainQueryPrefix + "SELECT * FROM orders WHERE id = 42")).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "orders", "Filter": "(id = 42)"}}]`))
+
+	queryID, queryText, realQueryText, databaseName := "-123", "SELECT * FROM orders WHERE id = ?", "SELECT * FROM orders WHERE id = 42", "testdb"
+	results := []datamodels.IndividualQueryMetrics{{QueryID: &queryID, QueryText: &queryText, RealQueryText: &realQueryText, DatabaseName: &databaseName}}
+	PopulateExecutionPlanMetrics(context.Background(), results, pgIntegration, cp, connectionInfo, persist.NewInMemoryStore(), map[string]bool{})
+	IngestIndividualQueryMetrics(results, pgIntegration, cp)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	planIDs := map[string]interface{}{}
+	decoder := json.NewDecoder(&output)
+	for decoder.More() {
+		var payload struct {
+			Data []struct {
+				Metrics []map[string]interface{} `json:"metrics"`
+			} `json:"data"`
+		}
+		assert.NoError(t, decoder.Decode(&payload))
+		for _, data := range payload.Data {
+			for _, event := range data.Metrics {
+				planIDs[event["event_type"].(string)] = event["plan_id"]
+			}
+		}
+	}
+	assert.NotNil(t, planIDs["PostgresIndividualQueries"], "the individual query event has a plan_id")
+	assert.Equal(t, planIDs["PostgresExecutionPlanMetrics"], planIDs["PostgresIndividualQueries"])
+}
+
+func TestSetPlanJSON(t *testing.T) {
+	nodes := make([]interface{}, 0, 200)
+	for i := 0; i < 200; i++ {
+		nodes = append(nodes, map[string]interface{}{"Node Type": "Seq Scan", "Relation Name": fmt.Sprintf("relation_%d_é", i)})
+	}
+	execPlan := []map[string]interface{}{{"Plan": map[string]interface{}{"Node Type": "Append", "Plans": nodes}}}
+	planJSON, _ := json.Marshal(execPlan)
+
+	var root datamodels.QueryExecutionPlanMetrics
+	setPlanJSON(&root, execPlan)
+	assert.Greater(t, *root.PlanJSONPartCount, 1)
+	assert.False(t, *root.PlanJSONTruncated)
+	rebuilt := *root.PlanJSON
+	for i := 1; i < *root.PlanJSONPartCount; i++ {
+		part := root.PlanJSONParts[strconv.Itoa(i)]
+		assert.LessOrEqual(t, len(part), commonutils.MaxPlanJSONLength)
+		rebuilt += part
+	}
+	assert.Equal(t, string(planJSON), rebuilt)
+
+	nodes = append(nodes, nodes...)
+	for len(planJSON) <= commonutils.MaxPlanJSONLength*commonutils.MaxPlanJSONParts {
+		nodes = append(nodes, nodes...)
+		execPlan[0]["Plan"].(map[string]interface{})["Plans"] = nodes
+		planJSON, _ = json.Marshal(execPlan)
+	}
+	root = datamodels.QueryExecutionPlanMetrics{}
+	setPlanJSON(&root, execPlan)
+	assert.Equal(t, commonutils.MaxPlanJSONParts, *root.PlanJSONPartCount)
+	assert.True(t, *root.PlanJSONTruncated)
+}
+
 func TestGroupQueriesByDatabase(t *testing.T) {
 	databaseName := "testdb"
 	queryID := "queryid1"
@@ -85,8 +161,116 @@ func TestFetchNestedExecutionPlanDetails(t *testing.T) {
 		"Plans":         []interface{}{execPlanLevel2},
 	}
 	var executionPlanMetricsList []interface{}
-	level := 0
+	nodeID := 0
 
-	fetchNestedExecutionPlanDetails(individualQuery, &level, execPlanLevel3, &executionPlanMetricsList)
+	fetchNestedExecutionPlanDetails(individualQuery, &nodeID, 0, nil, execPlanLevel3, &executionPlanMetricsList)
 	assert.Len(t, executionPlanMetricsList, 3)
+	for i, node := range executionPlanMetricsList {
+		planNode := node.(datamodels.QueryExecutionPlanMetrics)
+		assert.Equal(t, i, planNode.NodeID)
+		assert.Equal(t, i, planNode.Depth)
+		assert.Equal(t, "Seq Scan", planNode.NodeType)
+		assert.Equal(t, "test_table", planNode.RelationName)
+		assert.Equal(t, 1000.00, planNode.TotalCost)
+		assert.Equal(t, int64(100000), planNode.PlanRows)
+		if i == 0 {
+			assert.Nil(t, planNode.ParentNodeID)
+		} else {
+			assert.Equal(t, i-1, *planNode.ParentNodeID)
+		}
+	}
+}
+
+func TestValidateAndFetchNestedExecPlan(t *testing.T) {
+	queryID := "queryid1"
+	databaseName := "testdb"
+	individualQuery := datamodels.IndividualQueryMetrics{
+		QueryID:      &queryID,
+		DatabaseName: &databaseName,
+	}
+	execPlan := []map[string]interface{}{
+		{
+			"Plan": map[string]interface{}{
+				"Node Type": "Hash Join",
+				"Join Type": "Inner",
+				"Hash Cond": "(o.customer_id = c.id)",
+				"Output":    []interface{}{"o1.id", "'shipped'::text"},
+				"Plans": []interface{}{
+					map[string]interface{}{
+						"Node Type":           "Seq Scan",
+						"Parent Relationship": "Outer",
+						"Relation Name":       "orders",
+						"Filter":              "(status = 'shipped'::text)",
+					},
+					map[string]interface{}{
+						"Node Type":           "Hash",
+						"Parent Relationship": "Inner",
+						"Plans": []interface{}{
+							map[string]interface{}{
+								"Node Type":           "Sort",
+								"Parent Relationship": "Outer",
+								"Sort Key":            []interface{}{"c.id", "c.name"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	var executionPlanMetricsList []interface{}
+
+	plan := validateAndFetchNestedExecPlan(execPlan, &individualQuery, &executionPlanMetricsList)
+	assert.NotNil(t, plan)
+	assert.NotNil(t, individualQuery.PlanID)
+	assert.Len(t, executionPlanMetricsList, 4)
+
+	root := executionPlanMetricsList[0].(datamodels.QueryExecutionPlanMetrics)
+	assert.Equal(t, "Inner", *root.JoinType)
+	assert.Equal(t, "(o.customer_id = c.id)", *root.HashCondition)
+	assert.NotNil(t, root.PlanJSON)
+	assert.NotContains(t, *root.PlanJSON, "shipped")
+	assert.Equal(t, *individualQuery.PlanID, root.PlanID)
+	assert.Contains(t, *root.PlanJSON, `"Output":["o1.id","?::text"]`, "identifiers with digits are kept")
+	assert.Equal(t, 1, *root.PlanJSONPartCount)
+	assert.False(t, *root.PlanJSONTruncated)
+	assert.Nil(t, root.PlanJSONParts)
+
+	seqScan := executionPlanMetricsList[1].(datamodels.QueryExecutionPlanMetrics)
+	assert.Equal(t, "(status = ?::text)", *seqScan.Filter)
+	assert.Equal(t, "Outer", *seqScan.ParentRelationship)
+	assert.Equal(t, 0, *seqScan.ParentNodeID)
+	assert.Nil(t, seqScan.PlanJSON)
+
+	sort := executionPlanMetricsList[3].(datamodels.QueryExecutionPlanMetrics)
+	assert.Equal(t, 3, sort.NodeID)
+	assert.Equal(t, 2, *sort.ParentNodeID)
+	assert.Equal(t, 2, sort.Depth)
+	assert.Equal(t, "c.id, c.name", *sort.SortKey)
+}
+
+func TestFetchNestedExecutionPlanDetailsWithAnalyze(t *testing.T) {
+	queryID := "queryid1"
+	databaseName := "testdb"
+	planID := "planid1"
+	individualQuery := datamodels.IndividualQueryMetrics{
+		QueryID:      &queryID,
+		DatabaseName: &databaseName,
+		PlanID:       &planID,
+	}
+	var execPlan map[string]interface{}
+	err := json.Unmarshal([]byte(`{"Node Type": "Seq Scan", "Relation Name": "orders", "Plan Rows": 120, "Actual Startup Time": 0.012, "Actual Total Time": 35.5, "Actual Rows": 100, "Actual Loops": 1, "Rows Removed by Filter": 99900, "Shared Hit Blocks": 12, "Shared Read Blocks": 430, "Temp Written Blocks": 0}`), &execPlan)
+	assert.NoError(t, err)
+	var executionPlanMetricsList []interface{}
+	nodeID := 0
+
+	fetchNestedExecutionPlanDetails(individualQuery, &nodeID, 0, nil, execPlan, &executionPlanMetricsList)
+	assert.Len(t, executionPlanMetricsList, 1)
+	planNode := executionPlanMetricsList[0].(datamodels.QueryExecutionPlanMetrics)
+	assert.Equal(t, 35.5, *planNode.ActualTotalTime)
+	assert.Equal(t, int64(100), *planNode.ActualRows)
+	assert.Equal(t, int64(1), *planNode.ActualLoops)
+	assert.Equal(t, int64(99900), planNode.RowsRemovedByFilter)
+	assert.Equal(t, int64(430), *planNode.SharedReadBlocks)
+	assert.Equal(t, int64(0), *planNode.TempWrittenBlocks)
+	assert.Nil(t, planNode.LocalHitBlocks)
 }
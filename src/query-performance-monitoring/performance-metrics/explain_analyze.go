@@ -0,0 +1,99 @@
+package performancemetrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+)
+
+// Plans are captured VERBOSE so their scan nodes name the schema of their relation
+const (
+	explainQueryPrefix        = "EXPLAIN (VERBOSE, FORMAT JSON) "
+	explainAnalyzeQueryPrefix = "EXPLAIN (ANALYZE, VERBOSE, BUFFERS, FORMAT JSON) "
+)
+
+var leadingCommentsRegex = regexp.MustCompile(`^(\s|\(|/\*.*?\*/|--[^\n]*\n)+`)
+
+// fetchExecutionPlan returns the JSON execution plan of queryText once the explain rate limiter allows it. The plan
+// is captured with EXPLAIN ANALYZE when enabled and the statement is eligible, otherwise only the estimated plan is fetched.
+func fetchExecutionPlan(ctx context.Context, dbConn *performancedbconnection.PGSQLConnection, queryText string, cp *commonparameters.CommonParameters) (string, error) {
+	if err := WaitExplain(ctx); err != nil {
+		return "", err
+	}
+	if cp.ExplainAnalyze {
+		if isExplainAnalyzeAllowed(queryText, cp.ExplainDenylist) {
+			return explainAnalyze(ctx, dbConn, queryText, cp.ExplainAnalyzeTimeout)
+		}
+		log.Debug("Query is not eligible for EXPLAIN ANALYZE, fetching the estimated plan")
+	}
+	rows, err := dbConn.QueryxContext(ctx, explainQueryPrefix+queryText)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", sql.ErrNoRows
+	}
+	var execPlanJSON string
+	if err := rows.Scan(&execPlanJSON); err != nil {
+		return "", err
+	}
+	return execPlanJSON, nil
+}
+
+// ExplainTimeout returns how long capturing one plan may take: the EXPLAIN ANALYZE statement timeout plus a margin
+// for the rate limiter and the round trips when EXPLAIN ANALYZE is enabled, and never less than the EXPLAIN timeout
+func ExplainTimeout(cp *commonparameters.CommonParameters) time.Duration {
+	if !cp.ExplainAnalyze {
+		return commonutils.ExplainTimeout
+	}
+	return max(commonutils.ExplainTimeout, time.Duration(cp.ExplainAnalyzeTimeout)*time.Millisecond+commonutils.ExplainAnalyzeTimeoutMargin)
+}
+
+// explainAnalyze executes queryText with EXPLAIN ANALYZE in a read-only transaction that is always rolled back,
+// bounded by a statement timeout of timeoutMs milliseconds
+func explainAnalyze(ctx context.Context, dbConn *performancedbconnection.PGSQLConnection, queryText string, timeoutMs int) (string, error) {
+	tx, err := dbConn.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Debug("Error rolling back EXPLAIN ANALYZE transaction: %v", rollbackErr)
+		}
+	}()
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeoutMs)); err != nil {
+		return "", err
+	}
+	var execPlanJSON string
+	if err := tx.QueryRowxContext(ctx, explainAnalyzeQueryPrefix+queryText).Scan(&execPlanJSON); err != nil {
+		return "", err
+	}
+	return execPlanJSON, nil
+}
+
+// isExplainAnalyzeAllowed reports whether queryText is a single SELECT statement that matches none of the
+// denylist patterns
+func isExplainAnalyzeAllowed(queryText string, denylist []*regexp.Regexp) bool {
+	statement := leadingCommentsRegex.ReplaceAllString(queryText, "")
+	if len(statement) < len("select") || !strings.EqualFold(statement[:len("select")], "select") {
+		return false
+	}
+	if strings.Contains(strings.TrimRight(statement, "; \t\r\n"), ";") {
+		return false
+	}
+	for _, pattern := range denylist {
+		if pattern.MatchString(statement) {
+			return false
+		}
+	}
+	return true
+}
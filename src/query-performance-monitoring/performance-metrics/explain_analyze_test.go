@@ -0,0 +1,78 @@
+package performancemetrics
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestIsExplainAnalyzeAllowed(t *testing.T) {
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringExplainDenylist: `["(?i)\\bpayments\\b"]`}, uint64(14), "testdb")
+	testCases := []struct {
+		query   string
+		allowed bool
+	}{
+		{"SELECT * FROM orders WHERE id = 1", true},
+		{"  select count(*) from orders;", true},
+		{"/* controller='orders' */ SELECT * FROM orders", true},
+		{"(SELECT 1) UNION (SELECT 2)", true},
+		{"UPDATE orders SET status = 'shipped'", false},
+		{"WITH deleted AS (DELETE FROM orders RETURNING *) SELECT * FROM deleted", false},
+		{"SELECT * FROM orders; DELETE FROM orders", false},
+		{"SELECT nextval('orders_id_seq')", false},
+		{"SELECT * FROM orders FOR UPDATE", false},
+		{"SELECT * INTO orders_copy FROM orders", false},
+		{"SELECT pg_sleep(10)", false},
+		{"SELECT * FROM payments", false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.allowed, isExplainAnalyzeAllowed(tc.query, cp.ExplainDenylist), tc.query)
+	}
+}
+
+func TestExplainTimeout(t *testing.T) {
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{}, uint64(14), "testdb")
+	assert.Equal(t, 10*time.Second, ExplainTimeout(cp))
+
+	cp = common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringExplainAnalyze: true, QueryMonitoringExplainAnalyzeTimeout: 2000}, uint64(14), "testdb")
+	assert.Equal(t, 10*time.Second, ExplainTimeout(cp))
+
+	cp = common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringExplainAnalyze: true, QueryMonitoringExplainAnalyzeTimeout: 60000}, uint64(14), "testdb")
+	assert.Equal(t, 65*time.Second, ExplainTimeout(cp), "the configured statement timeout is not capped")
+}
+
+func TestFetchExecutionPlanWithExplainAnalyze(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringExplainAnalyze: true, QueryMonitoringExplainAnalyzeTimeout: 2000}, uint64(14), "testdb")
+	query := "SELECT * FROM orders WHERE id = 1"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL statement_timeout = 2000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(explainAnalyzeQueryPrefix + query)).WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Seq Scan"}}]`))
+	mock.ExpectRollback()
+
+	execPlanJSON, err := fetchExecutionPlan(context.Background(), conn, query, cp)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"Plan": {"Node Type": "Seq Scan"}}]`, execPlanJSON)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchExecutionPlanNotEligibleForExplainAnalyze(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringExplainAnalyze: true}, uint64(14), "testdb")
+	query := "DELETE FROM orders WHERE id = 1"
+
+	mock.ExpectQuery(regexp.QuoteMeta(explainQueryPrefix + query)).WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "ModifyTable"}}]`))
+
+	execPlanJSON, err := fetchExecutionPlan(context.Background(), conn, query, cp)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"Plan": {"Node Type": "ModifyTable"}}]`, execPlanJSON)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -9,6 +9,14 @@ import (
 
 var explainLimiter = rate.NewLimiter(rate.Limit(commonutils.ExplainTPS), commonutils.ExplainTPS)
 
+// SetExplainRate sets the maximum number of EXPLAIN statements per second, allowing bursts of the same size
+func SetExplainRate(tps int) {
+	explainLimiter.SetLimit(rate.Limit(tps))
+	explainLimiter.SetBurst(tps)
+}
+
+// WaitExplain blocks until an EXPLAIN statement is allowed to run or ctx is done. It must be called before
+// sending any EXPLAIN to the server.
 func WaitExplain(ctx context.Context) error {
 	return explainLimiter.Wait(ctx)
 }
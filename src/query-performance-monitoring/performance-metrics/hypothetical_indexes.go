@@ -0,0 +1,292 @@
+package performancemetrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+)
+
+const (
+	hypopgCreateIndexQuery = "SELECT indexname FROM hypopg_create_index($1)"
+	hypopgResetQuery       = "SELECT hypopg_reset()"
+)
+
+// planIdentifierPattern matches a plain or double quoted identifier of a plan condition
+const planIdentifierPattern = `(?:"(?:[^"]|"")+"|[A-Za-z_][\w$]*)`
+
+var (
+	errInvalidExecutionPlan = errors.New("execution plan has no root node cost")
+	// qualifiedColumnRegex matches alias qualified columns in join conditions, e.g. (t1.customer_id = t2.id)
+	qualifiedColumnRegex = regexp.MustCompile(`(` + planIdentifierPattern + `)\.(` + planIdentifierPattern + `)`)
+	// filterOperandRegex matches the columns compared in a scan filter, optionally qualified, e.g. (t1.status = 'a'::text)
+	filterOperandRegex     = regexp.MustCompile(`(?:(` + planIdentifierPattern + `)\.)?(` + planIdentifierPattern + `)\)?(?:::[\w ]+)?\s*(?:=|<>|!=|<=|>=|<|>|~~\*?|!~~\*?| IS | IN )`)
+	planStringLiteralRegex = regexp.MustCompile(`'(?:[^']|'')*'`)
+)
+
+type indexCandidate struct {
+	schemaName   string
+	relationName string
+	columnName   string
+}
+
+// definition returns the CREATE INDEX statement of the candidate, qualified with its schema when the plan has it
+func (c indexCandidate) definition() string {
+	relation := pq.QuoteIdentifier(c.relationName)
+	if c.schemaName != "" {
+		relation = pq.QuoteIdentifier(c.schemaName) + "." + relation
+	}
+	return fmt.Sprintf("CREATE INDEX ON %s (%s)", relation, pq.QuoteIdentifier(c.columnName))
+}
+
+// evaluateHypotheticalIndexes creates the candidate indexes of the slowest queries of a database as HypoPG
+// hypothetical indexes and compares the estimated cost of each query with and without them. candidatesByQuery
+// holds the candidates of the plans captured in this run, so queries with a cached plan are not evaluated again
+// until their plan is captured again.
+func evaluateHypotheticalIndexes(ctx context.Context, dbConn *performancedbconnection.PGSQLConnection, individualQueriesList []datamodels.IndividualQueryMetrics, executionPlanMetricsList []interface{}, candidatesByQuery map[string][]indexCandidate) []interface{} {
+	var queriesWithCandidates []datamodels.IndividualQueryMetrics
+	for _, individualQuery := range individualQueriesList {
+		if individualQuery.QueryID != nil && len(candidatesByQuery[*individualQuery.QueryID]) > 0 {
+			queriesWithCandidates = append(queriesWithCandidates, individualQuery)
+		}
+	}
+	var hypotheticalIndexList []interface{}
+	for _, individualQuery := range slowestQueries(queriesWithCandidates, commonutils.HypotheticalIndexMaxQueries) {
+		planNodes := queryPlanNodes(executionPlanMetricsList, *individualQuery.DatabaseName, *individualQuery.QueryID)
+		if len(planNodes) == 0 {
+			continue
+		}
+		queryCtx, cancel := context.WithTimeout(ctx, commonutils.ExplainTimeout)
+		results, err := evaluateIndexCandidates(queryCtx, dbConn, individualQuery, planNodes[0].PlanID, candidatesByQuery[*individualQuery.QueryID])
+		cancel()
+		if err != nil {
+			log.Debug("Error evaluating hypothetical indexes for queryId %s: %v", *individualQuery.QueryID, err)
+		}
+		hypotheticalIndexList = append(hypotheticalIndexList, results...)
+	}
+	return hypotheticalIndexList
+}
+
+// evaluateIndexCandidates runs on a single pooled connection because hypothetical indexes only exist in the
+// session that creates them. They are removed before the connection is returned to the pool.
+func evaluateIndexCandidates(ctx context.Context, dbConn *performancedbconnection.PGSQLConnection, individualQuery datamodels.IndividualQueryMetrics, planID string, candidates []indexCandidate) ([]interface{}, error) {
+	conn, err := dbConn.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseHypotheticalIndexConn(conn)
+
+	originalTotalCost, _, err := explainTotalCost(ctx, conn, *individualQuery.RealQueryText)
+	if err != nil {
+		return nil, err
+	}
+	var results []interface{}
+	for _, candidate := range candidates {
+		if _, err := conn.ExecContext(ctx, hypopgResetQuery); err != nil {
+			return results, err
+		}
+		definition := candidate.definition()
+		var indexName string
+		if err := conn.QueryRowxContext(ctx, hypopgCreateIndexQuery, definition).Scan(&indexName); err != nil {
+			log.Debug("Error creating hypothetical index %s: %v", definition, err)
+			continue
+		}
+		hypotheticalTotalCost, execPlanJSON, err := explainTotalCost(ctx, conn, *individualQuery.RealQueryText)
+		if err != nil {
+			return results, err
+		}
+		indexUsed := strings.Contains(execPlanJSON, indexName)
+		costReductionPercent := 0.0
+		if originalTotalCost > 0 {
+			costReductionPercent = (originalTotalCost - hypotheticalTotalCost) / originalTotalCost * 100
+		}
+		results = append(results, datamodels.HypotheticalIndexMetrics{
+			QueryID:               individualQuery.QueryID,
+			QueryText:             individualQuery.QueryText,
+			DatabaseName:          individualQuery.DatabaseName,
+			PlanID:                &planID,
+			SchemaName:            &candidate.schemaName,
+			RelationName:          &candidate.relationName,
+			ColumnName:            &candidate.columnName,
+			IndexDefinition:       &definition,
+			IndexUsed:             &indexUsed,
+			OriginalTotalCost:     &originalTotalCost,
+			HypotheticalTotalCost: &hypotheticalTotalCost,
+			CostReductionPercent:  &costReductionPercent,
+		})
+	}
+	return results, nil
+}
+
+// releaseHypotheticalIndexConn removes the hypothetical indexes of conn and returns it to the pool. A connection
+// whose indexes could not be removed is discarded, so that later plans of the database are not captured with them.
+func releaseHypotheticalIndexConn(conn *sqlx.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), commonutils.HypotheticalIndexResetTimeout)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, hypopgResetQuery); err != nil {
+		log.Debug("Error removing hypothetical indexes, discarding the connection: %v", err)
+		_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	conn.Close()
+}
+
+// explainTotalCost returns the estimated total cost of queryText and its JSON plan
+func explainTotalCost(ctx context.Context, conn *sqlx.Conn, queryText string) (float64, string, error) {
+	if err := WaitExplain(ctx); err != nil {
+		return 0, "", err
+	}
+	var execPlanJSON string
+	if err := conn.QueryRowxContext(ctx, explainQueryPrefix+queryText).Scan(&execPlanJSON); err != nil {
+		return 0, "", err
+	}
+	var execPlan []map[string]interface{}
+	if err := json.Unmarshal([]byte(execPlanJSON), &execPlan); err != nil {
+		return 0, "", err
+	}
+	if len(execPlan) == 0 {
+		return 0, "", errInvalidExecutionPlan
+	}
+	plan, ok := execPlan[0]["Plan"].(map[string]interface{})
+	if !ok {
+		return 0, "", errInvalidExecutionPlan
+	}
+	totalCost, ok := plan["Total Cost"].(float64)
+	if !ok {
+		return 0, "", errInvalidExecutionPlan
+	}
+	return totalCost, execPlanJSON, nil
+}
+
+// slowestQueries returns up to limit distinct queries with a plan, ordered by average execution time
+func slowestQueries(individualQueriesList []datamodels.IndividualQueryMetrics, limit int) []datamodels.IndividualQueryMetrics {
+	sorted := make([]datamodels.IndividualQueryMetrics, 0, len(individualQueriesList))
+	for _, individualQuery := range individualQueriesList {
+		if individualQuery.RealQueryText == nil || individualQuery.QueryID == nil || individualQuery.DatabaseName == nil {
+			continue
+		}
+		sorted = append(sorted, individualQuery)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return avgExecTime(sorted[i]) > avgExecTime(sorted[j])
+	})
+	var slowest []datamodels.IndividualQueryMetrics
+	seen := make(map[string]bool)
+	for _, individualQuery := range sorted {
+		if len(slowest) == limit {
+			break
+		}
+		if seen[*individualQuery.QueryID] {
+			continue
+		}
+		seen[*individualQuery.QueryID] = true
+		slowest = append(slowest, individualQuery)
+	}
+	return slowest
+}
+
+func avgExecTime(individualQuery datamodels.IndividualQueryMetrics) float64 {
+	if individualQuery.AvgExecTimeInMs == nil {
+		return 0
+	}
+	return *individualQuery.AvgExecTimeInMs
+}
+
+func queryPlanNodes(executionPlanMetricsList []interface{}, databaseName string, queryID string) []datamodels.QueryExecutionPlanMetrics {
+	var planNodes []datamodels.QueryExecutionPlanMetrics
+	for _, item := range executionPlanMetricsList {
+		planNode, ok := item.(datamodels.QueryExecutionPlanMetrics)
+		if ok && planNode.DatabaseName == databaseName && planNode.QueryID == queryID {
+			planNodes = append(planNodes, planNode)
+		}
+	}
+	return planNodes
+}
+
+// hypotheticalIndexCandidates derives single column indexes from the filter columns of scans without an index
+// and the join columns of the plan, resolving join aliases through the scan nodes. It reads the raw plan, as
+// anonymizing its conditions rewrites aliases and quoted identifiers.
+func hypotheticalIndexCandidates(plan map[string]interface{}) []indexCandidate {
+	planNodes := flattenPlan(plan, nil)
+	relationsByAlias := make(map[string]indexCandidate)
+	for _, planNode := range planNodes {
+		if relationName := planString(planNode, "Relation Name"); relationName != "" {
+			relation := indexCandidate{schemaName: planString(planNode, "Schema"), relationName: relationName}
+			relationsByAlias[relationName] = relation
+			if alias := planString(planNode, "Alias"); alias != "" {
+				relationsByAlias[alias] = relation
+			}
+		}
+	}
+
+	var candidates []indexCandidate
+	seen := make(map[indexCandidate]bool)
+	addCandidate := func(relation indexCandidate, columnName string) {
+		candidate := indexCandidate{schemaName: relation.schemaName, relationName: relation.relationName, columnName: columnName}
+		if relation.relationName == "" || seen[candidate] || len(candidates) == commonutils.HypotheticalIndexMaxCandidates {
+			return
+		}
+		seen[candidate] = true
+		candidates = append(candidates, candidate)
+	}
+
+	for _, planNode := range planNodes {
+		nodeType := planString(planNode, "Node Type")
+		if filter := planString(planNode, "Filter"); (nodeType == "Seq Scan" || nodeType == "Bitmap Heap Scan") && filter != "" {
+			relation := indexCandidate{schemaName: planString(planNode, "Schema"), relationName: planString(planNode, "Relation Name")}
+			for _, match := range filterOperandRegex.FindAllStringSubmatch(stripPlanLiterals(filter), -1) {
+				qualifier := unquoteIdentifier(match[1])
+				if qualifier == "" || qualifier == planString(planNode, "Alias") || qualifier == relation.relationName {
+					addCandidate(relation, unquoteIdentifier(match[2]))
+				}
+			}
+		}
+		for _, key := range []string{"Hash Cond", "Merge Cond", "Join Filter"} {
+			for _, match := range qualifiedColumnRegex.FindAllStringSubmatch(stripPlanLiterals(planString(planNode, key)), -1) {
+				addCandidate(relationsByAlias[unquoteIdentifier(match[1])], unquoteIdentifier(match[2]))
+			}
+		}
+	}
+	return candidates
+}
+
+// flattenPlan appends the nodes of the plan tree to planNodes in pre-order
+func flattenPlan(plan map[string]interface{}, planNodes []map[string]interface{}) []map[string]interface{} {
+	planNodes = append(planNodes, plan)
+	if nestedPlans, ok := plan["Plans"].([]interface{}); ok {
+		for _, nestedPlan := range nestedPlans {
+			if nestedPlanMap, nestedOk := nestedPlan.(map[string]interface{}); nestedOk {
+				planNodes = flattenPlan(nestedPlanMap, planNodes)
+			}
+		}
+	}
+	return planNodes
+}
+
+func planString(planNode map[string]interface{}, key string) string {
+	value, _ := planNode[key].(string)
+	return value
+}
+
+// stripPlanLiterals empties the string literals of a plan condition, so their content is not read as identifiers
+func stripPlanLiterals(condition string) string {
+	return planStringLiteralRegex.ReplaceAllString(condition, "''")
+}
+
+// unquoteIdentifier returns the name of a plain or double quoted identifier
+func unquoteIdentifier(identifier string) string {
+	if len(identifier) >= 2 && strings.HasPrefix(identifier, `"`) && strings.HasSuffix(identifier, `"`) {
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], `""`, `"`)
+	}
+	return identifier
+}
@@ -0,0 +1,128 @@
+package performancemetrics
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestHypotheticalIndexCandidates(t *testing.T) {
+	plan := map[string]interface{}{
+		"Node Type": "Hash Join",
+		"Hash Cond": `(t1.customer_id = "Customer"."ID2")`,
+		"Plans": []interface{}{
+			map[string]interface{}{
+				"Node Type":     "Seq Scan",
+				"Schema":        "sales",
+				"Relation Name": "orders",
+				"Alias":         "t1",
+				"Filter":        "((t1.status = 'a.b'::text) AND (t1.created_at2 > '2024-01-01'::date))",
+			},
+			map[string]interface{}{
+				"Node Type":     "Index Scan",
+				"Schema":        "public",
+				"Relation Name": "Customer",
+				"Alias":         "Customer",
+			},
+		},
+	}
+
+	candidates := hypotheticalIndexCandidates(plan)
+	assert.Equal(t, []indexCandidate{
+		{schemaName: "sales", relationName: "orders", columnName: "customer_id"},
+		{schemaName: "public", relationName: "Customer", columnName: "ID2"},
+		{schemaName: "sales", relationName: "orders", columnName: "status"},
+		{schemaName: "sales", relationName: "orders", columnName: "created_at2"},
+	}, candidates)
+	assert.Equal(t, `CREATE INDEX ON "sales"."orders" ("customer_id")`, candidates[0].definition())
+	assert.Equal(t, `CREATE INDEX ON "orders" ("id")`, indexCandidate{relationName: "orders", columnName: "id"}.definition())
+}
+
+func TestProcessExecutionPlanOfQueries_IndexCandidatesOfCapturedPlans(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringExplainInterval: 600}, uint64(16), "testdb")
+	planStore := persist.NewInMemoryStore()
+	query := "SELECT * FROM orders WHERE status = 'shipped'"
+	individualQuery := datamodels.IndividualQueryMetrics{QueryID: stringPtr("queryid1"), QueryText: stringPtr("SELECT * FROM orders WHERE status = ?"), DatabaseName: stringPtr("testdb"), RealQueryText: &query}
+	mock.ExpectQuery(regexp.QuoteMeta(explainQueryPrefix + query)).WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).
+		AddRow(`[{"Plan": {"Node Type": "Seq Scan", "Schema": "public", "Relation Name": "orders", "Alias": "orders", "Filter": "(orders.status = 'shipped'::text)"}}]`))
+
+	var executionPlanMetricsList, planChangeList []interface{}
+	candidatesByQuery := processExecutionPlanOfQueries(context.Background(), []datamodels.IndividualQueryMetrics{individualQuery}, conn, cp, planStore, &executionPlanMetricsList, &planChangeList)
+	assert.Equal(t, map[string][]indexCandidate{"queryid1": {{schemaName: "public", relationName: "orders", columnName: "status"}}}, candidatesByQuery)
+
+	candidatesByQuery = processExecutionPlanOfQueries(context.Background(), []datamodels.IndividualQueryMetrics{individualQuery}, conn, cp, planStore, &executionPlanMetricsList, &planChangeList)
+	assert.Empty(t, candidatesByQuery, "cached plans are not evaluated again")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSlowestQueries(t *testing.T) {
+	query := func(queryID string, execTime float64) datamodels.IndividualQueryMetrics {
+		return datamodels.IndividualQueryMetrics{QueryID: &queryID, DatabaseName: stringPtr("testdb"), RealQueryText: stringPtr("SELECT 1"), AvgExecTimeInMs: &execTime}
+	}
+	slowest := slowestQueries([]datamodels.IndividualQueryMetrics{query("q1", 10), query("q2", 50), query("q2", 40), query("q3", 30), query("q4", 5)}, 2)
+	assert.Len(t, slowest, 2)
+	assert.Equal(t, "q2", *slowest[0].QueryID)
+	assert.Equal(t, "q3", *slowest[1].QueryID)
+}
+
+func TestEvaluateHypotheticalIndexes(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	query := "SELECT * FROM orders WHERE status = 'shipped'"
+	individualQuery := datamodels.IndividualQueryMetrics{
+		QueryID:         stringPtr("queryid1"),
+		QueryText:       stringPtr("SELECT * FROM orders WHERE status = ?"),
+		DatabaseName:    stringPtr("testdb"),
+		RealQueryText:   &query,
+		AvgExecTimeInMs: new(float64),
+	}
+	seqScan := planNode(0, "Seq Scan")
+	seqScan.RelationName = "orders"
+	seqScan.Filter = stringPtr("(status = ?::text)")
+
+	mock.ExpectQuery(regexp.QuoteMeta(explainQueryPrefix + query)).WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Seq Scan", "Total Cost": 2000.0}}]`))
+	mock.ExpectExec(regexp.QuoteMeta(hypopgResetQuery)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(hypopgCreateIndexQuery)).WithArgs(`CREATE INDEX ON "orders" ("status")`).WillReturnRows(sqlmock.NewRows([]string{"indexname"}).AddRow("<13543>btree_orders_status"))
+	mock.ExpectQuery(regexp.QuoteMeta(explainQueryPrefix + query)).WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Index Scan", "Index Name": "<13543>btree_orders_status", "Total Cost": 500.0}}]`))
+	mock.ExpectExec(regexp.QuoteMeta(hypopgResetQuery)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	candidatesByQuery := map[string][]indexCandidate{"queryid1": {{relationName: "orders", columnName: "status"}}}
+	results := evaluateHypotheticalIndexes(context.Background(), conn, []datamodels.IndividualQueryMetrics{individualQuery}, []interface{}{seqScan}, candidatesByQuery)
+	assert.Len(t, results, 1)
+	hypotheticalIndex := results[0].(datamodels.HypotheticalIndexMetrics)
+	assert.Equal(t, "orders", *hypotheticalIndex.RelationName)
+	assert.Equal(t, "status", *hypotheticalIndex.ColumnName)
+	assert.Equal(t, "planid1", *hypotheticalIndex.PlanID)
+	assert.True(t, *hypotheticalIndex.IndexUsed)
+	assert.Equal(t, 2000.0, *hypotheticalIndex.OriginalTotalCost)
+	assert.Equal(t, 500.0, *hypotheticalIndex.HypotheticalTotalCost)
+	assert.Equal(t, 75.0, *hypotheticalIndex.CostReductionPercent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvaluateIndexCandidatesResetFailure(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	query := "SELECT * FROM orders WHERE status = 'shipped'"
+	individualQuery := datamodels.IndividualQueryMetrics{QueryID: stringPtr("queryid1"), DatabaseName: stringPtr("testdb"), RealQueryText: &query}
+
+	mock.ExpectQuery(regexp.QuoteMeta(explainQueryPrefix + query)).WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Seq Scan", "Total Cost": 2000.0}}]`))
+	mock.ExpectExec(regexp.QuoteMeta(hypopgResetQuery)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(hypopgCreateIndexQuery)).WillReturnRows(sqlmock.NewRows([]string{"indexname"}).AddRow("<13543>btree_orders_status"))
+	mock.ExpectQuery(regexp.QuoteMeta(explainQueryPrefix + query)).WillReturnError(errors.New("canceling statement due to statement timeout"))
+	mock.ExpectExec(regexp.QuoteMeta(hypopgResetQuery)).WillReturnError(errors.New("connection reset by peer"))
+	// the session may still hold the hypothetical index, so it is closed instead of returned to the pool
+	mock.ExpectClose()
+
+	_, err := evaluateIndexCandidates(context.Background(), conn, individualQuery, "planid1", []indexCandidate{{relationName: "orders", columnName: "status"}})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
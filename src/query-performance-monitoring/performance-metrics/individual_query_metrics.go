@@ -2,6 +2,8 @@ package performancemetrics
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 
@@ -11,34 +13,53 @@ import (
 	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 )
 
 type queryInfoMap map[string]string
 type databaseQueryInfoMap map[string]queryInfoMap
 
-func PopulateIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, enabledExtensions map[string]bool) []datamodels.IndividualQueryMetrics {
+// PopulateIndividualQueryMetrics returns the individual queries of the slow queries. They are ingested by
+// IngestIndividualQueryMetrics once PopulateExecutionPlanMetrics has set the hash of their plans.
+func PopulateIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, cp *commonparameters.CommonParameters, enabledExtensions map[string]bool) []datamodels.IndividualQueryMetrics {
 	isEligible, err := validations.CheckIndividualQueryMetricsFetchEligibility(enabledExtensions)
 	if err != nil {
 		log.Error("Error executing query: %v", err)
 		return nil
 	}
-	if !isEligible {
+	var individualQueriesList []datamodels.IndividualQueryMetrics
+	if isEligible {
+		log.Debug("Extension 'pg_stat_monitor' enabled.")
+		_, individualQueriesList = getIndividualQueryMetrics(conn, slowRunningQueries, cp)
+	} else if isActivityEligible, _ := validations.CheckActivityIndividualQueryMetricsFetchEligibility(cp.Version); isActivityEligible {
+		log.Debug("Extension 'pg_stat_monitor' is not enabled, sampling running queries from pg_stat_activity.")
+		_, individualQueriesList = getActivityIndividualQueryMetrics(conn, slowRunningQueries, cp)
+	} else {
 		log.Debug("Extension 'pg_stat_monitor' is not enabled or unsupported version.")
 		return nil
 	}
-	log.Debug("Extension 'pg_stat_monitor' enabled.")
-	individualQueryMetricsInterface, individualQueriesList := getIndividualQueryMetrics(conn, slowRunningQueries, cp)
-	if len(individualQueryMetricsInterface) == 0 {
+	if len(individualQueriesList) == 0 {
 		log.Debug("No individual queries found.")
 		return nil
 	}
-	err = commonutils.IngestMetric(individualQueryMetricsInterface, "PostgresIndividualQueries", pgIntegration, cp)
-	if err != nil {
+	return individualQueriesList
+}
+
+// IngestIndividualQueryMetrics ingests the individual queries, with the plan_id PopulateExecutionPlanMetrics set
+// so they join with the execution plan and plan change events
+func IngestIndividualQueryMetrics(individualQueriesList []datamodels.IndividualQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters) {
+	if len(individualQueriesList) == 0 {
+		return
+	}
+	individualQueryMetricsInterface := make([]interface{}, 0, len(individualQueriesList))
+	for _, individualQuery := range individualQueriesList {
+		individualQueryMetricsInterface = append(individualQueryMetricsInterface, individualQuery)
+	}
+	if err := commonutils.IngestMetric(individualQueryMetricsInterface, "PostgresIndividualQueries", pgIntegration, cp); err != nil {
 		log.Error("Error ingesting individual queries: %v", err)
-		return nil
 	}
-	return individualQueriesList
 }
 
 func getIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, cp *commonparameters.CommonParameters) ([]interface{}, []datamodels.IndividualQueryMetrics) {
@@ -58,14 +79,15 @@ func getIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, sl
 		if slowRunningMetric.QueryID == nil {
 			continue
 		}
-		query := fmt.Sprintf(versionSpecificIndividualQuery, *slowRunningMetric.QueryID, cp.Databases, cp.QueryMonitoringResponseTimeThreshold, min(cp.QueryMonitoringCountThreshold, commonutils.MaxIndividualQueryCountThreshold))
+		query := fmt.Sprintf(versionSpecificIndividualQuery, *slowRunningMetric.QueryID, cp.Databases, cp.QueryMonitoringResponseTimeThreshold, cp.QueryFilter.FetchLimit(individualQueryLimit(cp)))
 		rows, err := conn.Queryx(query)
 		if err != nil {
 			log.Debug("Error executing query in individual query: %v", err)
+			selfmetrics.RecordError("PostgresIndividualQueries", err)
 			return nil, nil
 		}
 		defer rows.Close()
-		individualQuerySamplesList := processRows(rows, anonymizedQueriesByDB)
+		individualQuerySamplesList := processRows(rows, anonymizedQueriesByDB, cp.QueryFilter, individualQueryLimit(cp))
 		for _, individualQuery := range individualQuerySamplesList {
 			individualQueryMetricsList = append(individualQueryMetricsList, individualQuery)
 			individualQueryMetricsListInterface = append(individualQueryMetricsListInterface, individualQuery)
@@ -74,9 +96,56 @@ func getIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, sl
 	return individualQueryMetricsListInterface, individualQueryMetricsList
 }
 
-func processRows(rows *sqlx.Rows, anonymizedQueriesByDB databaseQueryInfoMap) []datamodels.IndividualQueryMetrics {
+// getActivityIndividualQueryMetrics samples the statements of the slow queries that are currently running, for
+// servers without pg_stat_monitor
+func getActivityIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, cp *commonparameters.CommonParameters) ([]interface{}, []datamodels.IndividualQueryMetrics) {
+	queryIDs := slowQueryIDs(slowRunningQueries)
+	if len(queryIDs) == 0 {
+		log.Debug("No slow running queries found.")
+		return nil, nil
+	}
+	query := fmt.Sprintf(queries.IndividualQuerySearchFromActivity, strings.Join(queryIDs, ", "), cp.Databases, cp.QueryFilter.FetchLimit(individualQueryLimit(cp)))
+	rows, err := conn.Queryx(query)
+	if err != nil {
+		log.Debug("Error executing query in individual query: %v", err)
+		selfmetrics.RecordError("PostgresIndividualQueries", err)
+		return nil, nil
+	}
+	defer rows.Close()
+	var individualQueryMetricsListInterface []interface{}
+	individualQueryMetricsList := processRows(rows, processForAnonymizeQueryMap(slowRunningQueries), cp.QueryFilter, individualQueryLimit(cp))
+	for _, individualQuery := range individualQueryMetricsList {
+		individualQueryMetricsListInterface = append(individualQueryMetricsListInterface, individualQuery)
+	}
+	return individualQueryMetricsListInterface, individualQueryMetricsList
+}
+
+// slowQueryIDs returns the IDs of the slow queries that are valid to be inlined in an IN list
+func slowQueryIDs(slowRunningQueries []datamodels.SlowRunningQueryMetrics) []string {
+	var queryIDs []string
+	for _, slowRunningMetric := range slowRunningQueries {
+		if slowRunningMetric.QueryID == nil {
+			continue
+		}
+		if _, err := strconv.ParseInt(*slowRunningMetric.QueryID, 10, 64); err != nil {
+			log.Debug("Skipping invalid query ID %s", *slowRunningMetric.QueryID)
+			continue
+		}
+		queryIDs = append(queryIDs, *slowRunningMetric.QueryID)
+	}
+	return queryIDs
+}
+
+// individualQueryLimit is the number of individual queries reported per slow query, or in total when sampling the
+// running statements
+func individualQueryLimit(cp *commonparameters.CommonParameters) int {
+	return min(cp.QueryMonitoringCountThreshold, commonutils.MaxIndividualQueryCountThreshold)
+}
+
+// processRows returns up to limit individual queries of rows allowed by queryFilter
+func processRows(rows *sqlx.Rows, anonymizedQueriesByDB databaseQueryInfoMap, queryFilter *commonparameters.QueryFilter, limit int) []datamodels.IndividualQueryMetrics {
 	var individualQueryMetricsList []datamodels.IndividualQueryMetrics
-	for rows.Next() {
+	for len(individualQueryMetricsList) < limit && rows.Next() {
 		var model datamodels.IndividualQueryMetrics
 		if scanErr := rows.StructScan(&model); scanErr != nil {
 			log.Error("Could not scan row: ", scanErr)
@@ -90,13 +159,11 @@ func processRows(rows *sqlx.Rows, anonymizedQueriesByDB databaseQueryInfoMap) []
 		anonymizedQueryText := anonymizedQueriesByDB[*model.DatabaseName][*model.QueryID]
 		queryText := *model.QueryText
 		individualQueryMetric.RealQueryText = &queryText
+		individualQueryMetric.QueryTags = commonutils.ExtractQueryTags(queryText)
 		individualQueryMetric.QueryText = &anonymizedQueryText
-		generatedPlanID, err := commonutils.GeneratePlanID()
-		if err != nil {
-			log.Error("Error generating plan ID: %v", err)
+		if !queryFilter.Allows(commonparameters.QueryAttributes{QueryID: individualQueryMetric.QueryID, QueryText: individualQueryMetric.QueryText, Database: individualQueryMetric.DatabaseName}) {
 			continue
 		}
-		individualQueryMetric.PlanID = &generatedPlanID
 		individualQueryMetricsList = append(individualQueryMetricsList, individualQueryMetric)
 	}
 	return individualQueryMetricsList
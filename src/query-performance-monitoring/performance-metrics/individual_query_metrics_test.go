@@ -24,7 +24,7 @@ func TestGetIndividualQueryMetrics(t *testing.T) {
 	cp := common_parameters.SetCommonParameters(args, version, databaseName)
 
 	// Mock the individual query
-	query := fmt.Sprintf(queries.IndividualQuerySearchV13AndAbove, mockQueryID, databaseName, args.QueryMonitoringResponseTimeThreshold, args.QueryMonitoringCountThreshold)
+	query := fmt.Sprintf(queries.IndividualQuerySearchV13AndAbove, mockQueryID, databaseName, args.QueryMonitoringResponseTimeThreshold, cp.QueryFilter.FetchLimit(args.QueryMonitoringCountThreshold))
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
 		"newrelic", "query", "queryid", "datname", "planid", "cpu_time_ms", "exec_time_ms",
 	}).AddRow(
@@ -45,3 +45,38 @@ func TestGetIndividualQueryMetrics(t *testing.T) {
 	assert.Len(t, individualQueryMetrics, 1)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestGetActivityIndividualQueryMetrics(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
+	databaseName := "testdb"
+	version := uint64(16)
+	mockQueryID := "-123"
+	invalidQueryID := "1; DROP TABLE orders"
+	mockQueryText := "SELECT * FROM orders WHERE id = ?"
+	cp := common_parameters.SetCommonParameters(args, version, databaseName)
+
+	query := fmt.Sprintf(queries.IndividualQuerySearchFromActivity, mockQueryID, databaseName, cp.QueryFilter.FetchLimit(args.QueryMonitoringCountThreshold))
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
+		"newrelic", "query", "queryid", "datname", "duration_ms", "wait_event_type", "wait_event", "state",
+	}).AddRow(
+		"newrelic_value", "SELECT * FROM orders WHERE id = 42", "-123", "testdb", 1520.5, "Lock", "relation", "active",
+	))
+
+	slowRunningQueries := []datamodels.SlowRunningQueryMetrics{
+		{QueryID: &mockQueryID, QueryText: &mockQueryText, DatabaseName: &databaseName},
+		{QueryID: &invalidQueryID, QueryText: &mockQueryText, DatabaseName: &databaseName},
+	}
+
+	individualQueryMetricsInterface, individualQueryMetrics := getActivityIndividualQueryMetrics(conn, slowRunningQueries, cp)
+
+	assert.Len(t, individualQueryMetricsInterface, 1)
+	assert.Len(t, individualQueryMetrics, 1)
+	assert.Equal(t, "SELECT * FROM orders WHERE id = 42", *individualQueryMetrics[0].RealQueryText)
+	assert.Equal(t, mockQueryText, *individualQueryMetrics[0].QueryText)
+	assert.Equal(t, 1520.5, *individualQueryMetrics[0].DurationInMs)
+	assert.Equal(t, "Lock", *individualQueryMetrics[0].WaitEventType)
+	assert.Equal(t, "active", *individualQueryMetrics[0].State)
+	assert.Nil(t, individualQueryMetrics[0].PlanID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,72 @@
+package performancemetrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
+)
+
+// PopulateLongRunningSessionMetrics reports the sessions whose statement or transaction has been running longer
+// than the long running threshold, including idle in transaction sessions
+func PopulateLongRunningSessionMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters) {
+	longRunningSessionsList, err := getLongRunningSessionMetrics(ctx, conn, cp)
+	if err != nil {
+		log.Error("Error fetching long running sessions: %v", err)
+		selfmetrics.RecordError("PostgresLongRunningSession", err)
+		return
+	}
+	if len(longRunningSessionsList) == 0 {
+		log.Debug("No long running sessions found.")
+		return
+	}
+	err = commonutils.IngestMetric(longRunningSessionsList, "PostgresLongRunningSession", pgIntegration, cp)
+	if err != nil {
+		log.Error("Error ingesting long running sessions: %v", err)
+		return
+	}
+
+	// Increment self-metrics counter
+	selfmetrics.IncQueries()
+}
+
+func getLongRunningSessionMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var longRunningSessionsList []interface{}
+	query := fmt.Sprintf(queries.LongRunningSessions, cp.Databases, cp.LongRunningThreshold, cp.QueryFilter.FetchLimit(cp.QueryMonitoringCountThreshold))
+	rows, err := conn.QueryxContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for len(longRunningSessionsList) < cp.QueryMonitoringCountThreshold && rows.Next() {
+		var longRunningSession datamodels.LongRunningSessionMetrics
+		if scanErr := rows.StructScan(&longRunningSession); scanErr != nil {
+			return nil, scanErr
+		}
+		longRunningSession.QueryText = anonymizedQueryText(longRunningSession.QueryText)
+		if !cp.QueryFilter.Allows(commonparameters.QueryAttributes{QueryText: longRunningSession.QueryText, Database: longRunningSession.DatabaseName, User: longRunningSession.UserName, ApplicationName: longRunningSession.ApplicationName}) {
+			continue
+		}
+		longRunningSessionsList = append(longRunningSessionsList, longRunningSession)
+	}
+	return longRunningSessionsList, rows.Err()
+}
+
+func anonymizedQueryText(queryText *string) *string {
+	if queryText == nil {
+		return nil
+	}
+	anonymized := commonutils.AnonymizeQueryText(*queryText)
+	return &anonymized
+}
@@ -0,0 +1,43 @@
+package performancemetrics
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestGetLongRunningSessionMetrics(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 10, QueryMonitoringLongRunningThreshold: 60}
+	databaseName := "testdb"
+	cp := common_parameters.SetCommonParameters(args, uint64(16), databaseName)
+
+	query := fmt.Sprintf(queries.LongRunningSessions, databaseName, 60, cp.QueryFilter.FetchLimit(10))
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
+		"newrelic", "pid", "user_name", "application_name", "client_address", "database_name", "state",
+		"wait_event_type", "wait_event", "backend_xmin_age", "query_duration_ms", "transaction_duration_ms", "query_text",
+	}).AddRow(
+		"newrelic", int64(4242), "app", "billing", "10.0.0.7", "testdb", "idle in transaction",
+		"Client", "ClientRead", int64(1500), 95000.0, 125000.0, "UPDATE invoices SET status = 'paid' WHERE id = 7",
+	))
+
+	longRunningSessionsList, err := getLongRunningSessionMetrics(context.Background(), conn, cp)
+	assert.NoError(t, err)
+	assert.Len(t, longRunningSessionsList, 1)
+	longRunningSession := longRunningSessionsList[0].(datamodels.LongRunningSessionMetrics)
+	assert.Equal(t, int64(4242), *longRunningSession.Pid)
+	assert.Equal(t, "idle in transaction", *longRunningSession.State)
+	assert.Equal(t, int64(1500), *longRunningSession.BackendXminAge)
+	assert.Equal(t, 125000.0, *longRunningSession.TransactionDurationMs)
+	assert.Equal(t, "UPDATE invoices SET status = ? WHERE id = ?", *longRunningSession.QueryText)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
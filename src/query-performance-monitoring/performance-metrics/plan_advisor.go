@@ -0,0 +1,199 @@
+package performancemetrics
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+)
+
+const (
+	recommendationSeqScanSelectiveFilter = "seq_scan_selective_filter"
+	recommendationRowsRemovedByFilter    = "high_rows_removed_by_filter"
+	recommendationNestedLoopLargeOuter   = "nested_loop_large_outer"
+	recommendationSortSpill              = "sort_spill_to_disk"
+	recommendationImplicitCast           = "implicit_cast_prevents_index"
+
+	severityHigh   = "high"
+	severityMedium = "medium"
+)
+
+var (
+	// castColumnRegex matches a column cast to another type in a plan condition, e.g. ((customer_id)::numeric = ?::numeric)
+	castColumnRegex = regexp.MustCompile(`\(([A-Za-z_][\w.]*)\)::([\w ]+)`)
+	// binaryCoercibleCastTypes are the types varchar, char and name columns are cast to without a conversion. The plan
+	// prints such casts, e.g. ((email)::text = ?::text), for every comparison of these columns even when an index is
+	// used, and does not tell them apart from other casts to these types.
+	binaryCoercibleCastTypes = map[string]bool{"text": true, "character varying": true, "bpchar": true, "name": true}
+	// filterColumnRegex matches the first column compared in a plan condition, e.g. (status = ?::text)
+	filterColumnRegex = regexp.MustCompile(`\(*([A-Za-z_][\w.]*)\)?(?:::[\w ]+)?\s*(?:=|<>|!=|<=|>=|<|>|~~\*?|!~~\*?| IS | IN | ANY)`)
+)
+
+type planKey struct {
+	databaseName string
+	queryID      string
+	planID       string
+}
+
+// analyzeExecutionPlans flags anti-patterns in the plan nodes of every query and returns them as recommendations.
+// queryTexts maps database name and query ID to the anonymized query text.
+func analyzeExecutionPlans(executionPlanMetricsList []interface{}, queryTexts databaseQueryInfoMap) []interface{} {
+	var recommendationList []interface{}
+	for key, planNodes := range groupPlanNodes(executionPlanMetricsList) {
+		queryText, hasQueryText := queryTexts[key.databaseName][key.queryID]
+		for _, planNode := range planNodes {
+			for _, recommendation := range analyzePlanNode(planNode, planNodes) {
+				if hasQueryText {
+					recommendation.QueryText = &queryText
+				}
+				recommendationList = append(recommendationList, recommendation)
+			}
+		}
+	}
+	return recommendationList
+}
+
+func groupPlanNodes(executionPlanMetricsList []interface{}) map[planKey][]datamodels.QueryExecutionPlanMetrics {
+	planNodesByKey := make(map[planKey][]datamodels.QueryExecutionPlanMetrics)
+	for _, item := range executionPlanMetricsList {
+		planNode, ok := item.(datamodels.QueryExecutionPlanMetrics)
+		if !ok {
+			continue
+		}
+		key := planKey{databaseName: planNode.DatabaseName, queryID: planNode.QueryID, planID: planNode.PlanID}
+		planNodesByKey[key] = append(planNodesByKey[key], planNode)
+	}
+	return planNodesByKey
+}
+
+func analyzePlanNode(planNode datamodels.QueryExecutionPlanMetrics, planNodes []datamodels.QueryExecutionPlanMetrics) []datamodels.QueryRecommendationMetrics {
+	var recommendations []datamodels.QueryRecommendationMetrics
+	seqScanFlagged := false
+	if isSeqScanWithSelectiveFilter(planNode) {
+		seqScanFlagged = true
+		recommendations = append(recommendations, newRecommendation(planNode, recommendationSeqScanSelectiveFilter, severityHigh, filterColumn(planNode.Filter),
+			fmt.Sprintf("Sequential scan on %s discards most rows with a selective filter, consider an index on the filtered columns", planNode.RelationName)))
+	}
+	if !seqScanFlagged && hasHighRowsRemovedByFilter(planNode) {
+		recommendations = append(recommendations, newRecommendation(planNode, recommendationRowsRemovedByFilter, severityMedium, filterColumn(planNode.Filter),
+			fmt.Sprintf("%s removes %d rows by filter for each row returned", planNode.NodeType, planNode.RowsRemovedByFilter/max(rowsReturned(planNode), 1))))
+	}
+	if outerRows, ok := nestedLoopOuterRows(planNode, planNodes); ok {
+		recommendations = append(recommendations, newRecommendation(planNode, recommendationNestedLoopLargeOuter, severityMedium, "",
+			fmt.Sprintf("Nested loop iterates over %d outer rows, consider a hash or merge join or an index on the inner join key", outerRows)))
+	}
+	if isSortSpill(planNode) {
+		recommendations = append(recommendations, newRecommendation(planNode, recommendationSortSpill, severityMedium, "",
+			"Sort spilled to disk, consider increasing work_mem or an index matching the sort keys"))
+	}
+	if column, castType, ok := implicitCast(planNode); ok {
+		recommendations = append(recommendations, newRecommendation(planNode, recommendationImplicitCast, severityHigh, column,
+			fmt.Sprintf("Column %s is cast to %s in the filter, which prevents the use of an index on it", column, castType)))
+	}
+	return recommendations
+}
+
+func newRecommendation(planNode datamodels.QueryExecutionPlanMetrics, recommendationType string, severity string, column string, message string) datamodels.QueryRecommendationMetrics {
+	recommendation := datamodels.QueryRecommendationMetrics{
+		QueryID:            &planNode.QueryID,
+		DatabaseName:       &planNode.DatabaseName,
+		PlanID:             &planNode.PlanID,
+		NodeID:             &planNode.NodeID,
+		NodeType:           &planNode.NodeType,
+		RecommendationType: &recommendationType,
+		Severity:           &severity,
+		Message:            &message,
+	}
+	if planNode.RelationName != "" {
+		recommendation.RelationName = &planNode.RelationName
+	}
+	if column != "" {
+		recommendation.ColumnName = &column
+	}
+	return recommendation
+}
+
+// isSeqScanWithSelectiveFilter uses the actual row counts of analyzed plans. Estimated plans have no count of the
+// rows read, so a costly scan returning few rows is used instead.
+func isSeqScanWithSelectiveFilter(planNode datamodels.QueryExecutionPlanMetrics) bool {
+	if planNode.NodeType != "Seq Scan" || planNode.Filter == nil {
+		return false
+	}
+	if planNode.ActualRows == nil {
+		return planNode.TotalCost >= commonutils.AdvisorLargeSeqScanCost && planNode.PlanRows <= commonutils.AdvisorSelectiveScanMaxRows
+	}
+	rowsRead := *planNode.ActualRows + planNode.RowsRemovedByFilter
+	return rowsRead >= commonutils.AdvisorLargeRelationRows && float64(*planNode.ActualRows) <= float64(rowsRead)*commonutils.AdvisorSelectiveFilterRatio
+}
+
+func hasHighRowsRemovedByFilter(planNode datamodels.QueryExecutionPlanMetrics) bool {
+	if planNode.RowsRemovedByFilter < commonutils.AdvisorMinRowsRemovedByFilter {
+		return false
+	}
+	rowsRead := rowsReturned(planNode) + planNode.RowsRemovedByFilter
+	return float64(planNode.RowsRemovedByFilter) >= float64(rowsRead)*commonutils.AdvisorRowsRemovedByFilterRatio
+}
+
+// nestedLoopOuterRows returns the number of rows of the outer input of a nested loop when it exceeds the threshold
+func nestedLoopOuterRows(planNode datamodels.QueryExecutionPlanMetrics, planNodes []datamodels.QueryExecutionPlanMetrics) (int64, bool) {
+	if planNode.NodeType != "Nested Loop" {
+		return 0, false
+	}
+	for _, child := range planNodes {
+		if child.ParentNodeID == nil || *child.ParentNodeID != planNode.NodeID || child.ParentRelationship == nil || *child.ParentRelationship != "Outer" {
+			continue
+		}
+		outerRows := rowsReturned(child)
+		if child.ActualLoops != nil {
+			outerRows *= *child.ActualLoops
+		}
+		return outerRows, outerRows >= commonutils.AdvisorNestedLoopOuterRows
+	}
+	return 0, false
+}
+
+func isSortSpill(planNode datamodels.QueryExecutionPlanMetrics) bool {
+	if planNode.NodeType != "Sort" && planNode.NodeType != "Incremental Sort" {
+		return false
+	}
+	if planNode.SortSpaceType != nil && *planNode.SortSpaceType == "Disk" {
+		return true
+	}
+	return planNode.TempWrittenBlocks != nil && *planNode.TempWrittenBlocks > 0
+}
+
+// implicitCast returns the column and type of a column cast found in the filter of a sequential scan. Index and
+// recheck conditions are left out, as an index was used for them, and so are binary coercible casts.
+func implicitCast(planNode datamodels.QueryExecutionPlanMetrics) (string, string, bool) {
+	if planNode.NodeType != "Seq Scan" || planNode.Filter == nil {
+		return "", "", false
+	}
+	for _, match := range castColumnRegex.FindAllStringSubmatch(*planNode.Filter, -1) {
+		castType := strings.TrimSpace(match[2])
+		if binaryCoercibleCastTypes[castType] {
+			continue
+		}
+		return match[1], castType, true
+	}
+	return "", "", false
+}
+
+// rowsReturned prefers the actual row count of analyzed plans over the planner estimate
+func rowsReturned(planNode datamodels.QueryExecutionPlanMetrics) int64 {
+	if planNode.ActualRows != nil {
+		return *planNode.ActualRows
+	}
+	return planNode.PlanRows
+}
+
+func filterColumn(filter *string) string {
+	if filter == nil {
+		return ""
+	}
+	if match := filterColumnRegex.FindStringSubmatch(*filter); match != nil {
+		return match[1]
+	}
+	return ""
+}
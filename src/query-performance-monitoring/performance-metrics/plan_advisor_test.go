@@ -0,0 +1,124 @@
+package performancemetrics
+
+import (
+	"testing"
+
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/stretchr/testify/assert"
+)
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func stringPtr(v string) *string {
+	return &v
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func planNode(nodeID int, nodeType string) datamodels.QueryExecutionPlanMetrics {
+	return datamodels.QueryExecutionPlanMetrics{
+		NodeType:     nodeType,
+		NodeID:       nodeID,
+		QueryID:      "queryid1",
+		DatabaseName: "testdb",
+		PlanID:       "planid1",
+	}
+}
+
+func recommendationTypes(recommendations []interface{}) []string {
+	var types []string
+	for _, recommendation := range recommendations {
+		types = append(types, *recommendation.(datamodels.QueryRecommendationMetrics).RecommendationType)
+	}
+	return types
+}
+
+func TestAnalyzeExecutionPlansSeqScan(t *testing.T) {
+	analyzedSeqScan := planNode(0, "Seq Scan")
+	analyzedSeqScan.RelationName = "orders"
+	analyzedSeqScan.Filter = stringPtr("(status = ?::text)")
+	analyzedSeqScan.ActualRows = int64Ptr(10)
+	analyzedSeqScan.RowsRemovedByFilter = 99990
+
+	recommendations := analyzeExecutionPlans([]interface{}{analyzedSeqScan}, databaseQueryInfoMap{"testdb": {"queryid1": "SELECT * FROM orders WHERE status = ?"}})
+	assert.Equal(t, []string{recommendationSeqScanSelectiveFilter}, recommendationTypes(recommendations))
+	recommendation := recommendations[0].(datamodels.QueryRecommendationMetrics)
+	assert.Equal(t, "orders", *recommendation.RelationName)
+	assert.Equal(t, "status", *recommendation.ColumnName)
+	assert.Equal(t, severityHigh, *recommendation.Severity)
+	assert.Equal(t, "SELECT * FROM orders WHERE status = ?", *recommendation.QueryText)
+
+	estimatedSeqScan := planNode(0, "Seq Scan")
+	estimatedSeqScan.Filter = stringPtr("(status = ?::text)")
+	estimatedSeqScan.TotalCost = 25000
+	estimatedSeqScan.PlanRows = 20
+	assert.Equal(t, []string{recommendationSeqScanSelectiveFilter}, recommendationTypes(analyzeExecutionPlans([]interface{}{estimatedSeqScan}, nil)))
+
+	smallSeqScan := planNode(0, "Seq Scan")
+	smallSeqScan.Filter = stringPtr("(status = ?::text)")
+	smallSeqScan.TotalCost = 12
+	smallSeqScan.PlanRows = 20
+	assert.Empty(t, analyzeExecutionPlans([]interface{}{smallSeqScan}, nil))
+}
+
+func TestAnalyzeExecutionPlansRowsRemovedByFilter(t *testing.T) {
+	indexScan := planNode(0, "Index Scan")
+	indexScan.Filter = stringPtr("(total > ?)")
+	indexScan.ActualRows = int64Ptr(5)
+	indexScan.RowsRemovedByFilter = 5000
+
+	recommendations := analyzeExecutionPlans([]interface{}{indexScan}, nil)
+	assert.Equal(t, []string{recommendationRowsRemovedByFilter}, recommendationTypes(recommendations))
+	assert.Equal(t, "total", *recommendations[0].(datamodels.QueryRecommendationMetrics).ColumnName)
+}
+
+func TestAnalyzeExecutionPlansNestedLoop(t *testing.T) {
+	nestedLoop := planNode(0, "Nested Loop")
+	outer := planNode(1, "Seq Scan")
+	outer.ParentNodeID = intPtr(0)
+	outer.ParentRelationship = stringPtr("Outer")
+	outer.PlanRows = 50000
+	inner := planNode(2, "Index Scan")
+	inner.ParentNodeID = intPtr(0)
+	inner.ParentRelationship = stringPtr("Inner")
+
+	recommendations := analyzeExecutionPlans([]interface{}{nestedLoop, outer, inner}, nil)
+	assert.Equal(t, []string{recommendationNestedLoopLargeOuter}, recommendationTypes(recommendations))
+
+	outer.PlanRows = 10
+	assert.Empty(t, analyzeExecutionPlans([]interface{}{nestedLoop, outer, inner}, nil))
+}
+
+func TestAnalyzeExecutionPlansSortSpill(t *testing.T) {
+	sort := planNode(0, "Sort")
+	sort.SortSpaceType = stringPtr("Disk")
+	assert.Equal(t, []string{recommendationSortSpill}, recommendationTypes(analyzeExecutionPlans([]interface{}{sort}, nil)))
+
+	sort.SortSpaceType = stringPtr("Memory")
+	assert.Empty(t, analyzeExecutionPlans([]interface{}{sort}, nil))
+}
+
+func TestAnalyzeExecutionPlansImplicitCast(t *testing.T) {
+	seqScan := planNode(0, "Seq Scan")
+	seqScan.RelationName = "orders"
+	seqScan.Filter = stringPtr("(((email)::text = ?::text) AND ((customer_id)::numeric = ?::numeric))")
+
+	recommendations := analyzeExecutionPlans([]interface{}{seqScan}, nil)
+	assert.Equal(t, []string{recommendationImplicitCast}, recommendationTypes(recommendations))
+	recommendation := recommendations[0].(datamodels.QueryRecommendationMetrics)
+	assert.Equal(t, "customer_id", *recommendation.ColumnName)
+	assert.Equal(t, "orders", *recommendation.RelationName)
+	assert.Contains(t, *recommendation.Message, "cast to numeric in")
+
+	seqScan.Filter = stringPtr("((email)::text = ?::text)")
+	assert.Empty(t, analyzeExecutionPlans([]interface{}{seqScan}, nil), "varchar columns are compared as text without a conversion")
+
+	indexScan := planNode(0, "Index Scan")
+	indexScan.IndexCondition = stringPtr("((customer_id)::numeric = ?::numeric)")
+	indexScan.Filter = stringPtr("((total)::numeric > ?::numeric)")
+	assert.Empty(t, analyzeExecutionPlans([]interface{}{indexScan}, nil), "only sequential scans are checked")
+}
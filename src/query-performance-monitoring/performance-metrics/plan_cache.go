@@ -0,0 +1,68 @@
+package performancemetrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+)
+
+// planCacheEntry is the last execution plan captured for a query along with the statistics it was captured with
+type planCacheEntry struct {
+	ExecPlanJSON    string
+	AvgExecTimeInMs float64
+}
+
+func planCacheKey(databaseName string, queryID string) string {
+	return fmt.Sprintf("plancache:%s:%s", databaseName, queryID)
+}
+
+// cachedExecutionPlan returns the cached plan of the query if it was captured less than interval ago and the
+// average execution time of the query has not changed significantly since
+func cachedExecutionPlan(planStore persist.Storer, individualQuery datamodels.IndividualQueryMetrics, interval time.Duration) (string, bool) {
+	if interval <= 0 {
+		return "", false
+	}
+	var entry planCacheEntry
+	capturedAt, err := planStore.Get(planCacheKey(*individualQuery.DatabaseName, *individualQuery.QueryID), &entry)
+	if err != nil || entry.ExecPlanJSON == "" {
+		return "", false
+	}
+	if time.Since(time.Unix(capturedAt, 0)) >= interval {
+		return "", false
+	}
+	if individualQuery.AvgExecTimeInMs != nil && statsChangedSignificantly(entry.AvgExecTimeInMs, *individualQuery.AvgExecTimeInMs) {
+		return "", false
+	}
+	return entry.ExecPlanJSON, true
+}
+
+// cacheExecutionPlan stores the plan of the query with its literals anonymized, as the plan store is kept on disk.
+// The plan is anonymized in place.
+func cacheExecutionPlan(planStore persist.Storer, individualQuery datamodels.IndividualQueryMetrics, execPlan []map[string]interface{}) {
+	if plan, ok := rootPlan(execPlan); ok {
+		anonymizeExecutionPlan(plan)
+	}
+	execPlanJSON, err := json.Marshal(execPlan)
+	if err != nil {
+		log.Debug("Failed to marshal execution plan: %v", err)
+		return
+	}
+	entry := planCacheEntry{ExecPlanJSON: string(execPlanJSON)}
+	if individualQuery.AvgExecTimeInMs != nil {
+		entry.AvgExecTimeInMs = *individualQuery.AvgExecTimeInMs
+	}
+	planStore.Set(planCacheKey(*individualQuery.DatabaseName, *individualQuery.QueryID), entry)
+}
+
+func statsChangedSignificantly(previous float64, current float64) bool {
+	if previous == 0 {
+		return current != 0
+	}
+	return math.Abs(current-previous)/previous > commonutils.PlanCacheStatsChangeRatio
+}
@@ -0,0 +1,47 @@
+package performancemetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedExecutionPlan(t *testing.T) {
+	queryID := "queryid1"
+	databaseName := "testdb"
+	individualQuery := func(avgExecTimeInMs float64) datamodels.IndividualQueryMetrics {
+		return datamodels.IndividualQueryMetrics{
+			QueryID:         &queryID,
+			DatabaseName:    &databaseName,
+			AvgExecTimeInMs: &avgExecTimeInMs,
+		}
+	}
+	planStore := persist.NewInMemoryStore()
+	execPlan := []map[string]interface{}{{"Plan": map[string]interface{}{"Node Type": "Seq Scan", "Filter": "(email = 'jane@example.com'::text)"}}}
+
+	_, cached := cachedExecutionPlan(planStore, individualQuery(100), time.Minute)
+	assert.False(t, cached, "nothing cached yet")
+
+	cacheExecutionPlan(planStore, individualQuery(100), execPlan)
+	cachedPlanJSON, cached := cachedExecutionPlan(planStore, individualQuery(120), time.Minute)
+	assert.True(t, cached)
+	assert.JSONEq(t, `[{"Plan": {"Node Type": "Seq Scan", "Filter": "(email = ?::text)"}}]`, cachedPlanJSON)
+	assert.NotContains(t, cachedPlanJSON, "jane@example.com", "literals are not written to the plan store")
+
+	_, cached = cachedExecutionPlan(planStore, individualQuery(400), time.Minute)
+	assert.False(t, cached, "execution time changed significantly")
+
+	_, cached = cachedExecutionPlan(planStore, individualQuery(100), 0)
+	assert.False(t, cached, "cache disabled")
+}
+
+func TestStatsChangedSignificantly(t *testing.T) {
+	assert.False(t, statsChangedSignificantly(100, 149))
+	assert.True(t, statsChangedSignificantly(100, 151))
+	assert.True(t, statsChangedSignificantly(100, 40))
+	assert.False(t, statsChangedSignificantly(0, 0))
+	assert.True(t, statsChangedSignificantly(0, 10))
+}
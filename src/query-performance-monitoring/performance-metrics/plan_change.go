@@ -0,0 +1,47 @@
+package performancemetrics
+
+import (
+	"fmt"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+)
+
+// planHistoryEntry is the last plan seen for a query, persisted between runs.
+type planHistoryEntry struct {
+	PlanHash    string
+	StartupCost float64
+	TotalCost   float64
+}
+
+func planHistoryKey(databaseName string, queryID string) string {
+	return fmt.Sprintf("plan:%s:%s", databaseName, queryID)
+}
+
+// recordPlan saves the plan hash and root cost estimates of the query in planStore and returns a plan
+// change when the hash differs from the one recorded by a previous run. It returns nil for unchanged or new plans.
+func recordPlan(planStore persist.Storer, individualQuery datamodels.IndividualQueryMetrics, plan map[string]interface{}) *datamodels.PlanChangeMetrics {
+	planHash := *individualQuery.PlanID
+	startupCost, _ := plan["Startup Cost"].(float64)
+	totalCost, _ := plan["Total Cost"].(float64)
+	key := planHistoryKey(*individualQuery.DatabaseName, *individualQuery.QueryID)
+
+	var previous planHistoryEntry
+	_, err := planStore.Get(key, &previous)
+	planStore.Set(key, planHistoryEntry{PlanHash: planHash, StartupCost: startupCost, TotalCost: totalCost})
+	if err != nil || previous.PlanHash == "" || previous.PlanHash == planHash {
+		return nil
+	}
+
+	return &datamodels.PlanChangeMetrics{
+		QueryID:        individualQuery.QueryID,
+		QueryText:      individualQuery.QueryText,
+		DatabaseName:   individualQuery.DatabaseName,
+		OldPlanHash:    &previous.PlanHash,
+		NewPlanHash:    &planHash,
+		OldStartupCost: &previous.StartupCost,
+		NewStartupCost: &startupCost,
+		OldTotalCost:   &previous.TotalCost,
+		NewTotalCost:   &totalCost,
+	}
+}
@@ -0,0 +1,38 @@
+package performancemetrics
+
+import (
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordPlan(t *testing.T) {
+	queryID := "queryid1"
+	databaseName := "testdb"
+	planStore := persist.NewInMemoryStore()
+	individualQuery := func(planHash string) datamodels.IndividualQueryMetrics {
+		return datamodels.IndividualQueryMetrics{
+			QueryID:      &queryID,
+			DatabaseName: &databaseName,
+			PlanID:       &planHash,
+		}
+	}
+	seqScan := map[string]interface{}{"Node Type": "Seq Scan", "Startup Cost": 0.0, "Total Cost": 1000.0}
+	indexScan := map[string]interface{}{"Node Type": "Index Scan", "Startup Cost": 0.29, "Total Cost": 8.3}
+
+	assert.Nil(t, recordPlan(planStore, individualQuery("hash1"), seqScan), "first plan seen is not a change")
+	assert.Nil(t, recordPlan(planStore, individualQuery("hash1"), seqScan), "same plan is not a change")
+
+	planChange := recordPlan(planStore, individualQuery("hash2"), indexScan)
+	assert.NotNil(t, planChange)
+	assert.Equal(t, "hash1", *planChange.OldPlanHash)
+	assert.Equal(t, "hash2", *planChange.NewPlanHash)
+	assert.Equal(t, 1000.0, *planChange.OldTotalCost)
+	assert.Equal(t, 8.3, *planChange.NewTotalCost)
+	assert.Equal(t, 0.29, *planChange.NewStartupCost)
+	assert.Equal(t, queryID, *planChange.QueryID)
+
+	assert.Nil(t, recordPlan(planStore, individualQuery("hash2"), indexScan))
+}
@@ -0,0 +1,267 @@
+package performancemetrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/capabilities"
+	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
+)
+
+const (
+	queryErrorBucketKey = "pgsm:last_error_bucket_start_time"
+	errorLogOffsetKey   = "errorlog:"
+
+	queryErrorSourcePgStatMonitor = "pg_stat_monitor"
+	queryErrorSourceLog           = "log"
+)
+
+var (
+	// errorLogLineRegex matches error lines with an optional SQLSTATE, logged with log_error_verbosity = verbose
+	errorLogLineRegex = regexp.MustCompile(`\b(?:ERROR|FATAL|PANIC):\s+(?:([0-9A-Z]{5}):\s+)?(.*)$`)
+	// errorLogStatementRegex matches the statement logged after an error
+	errorLogStatementRegex = regexp.MustCompile(`\bSTATEMENT:\s+(.*)$`)
+	// errorLogDatabaseRegex matches the database name of a log_line_prefix containing db=%d
+	errorLogDatabaseRegex = regexp.MustCompile(`\bdb=([^\s,\]]+)`)
+	// sqlCodeCapability is the pg_stat_monitor column the errors are counted from, missing in some forks
+	sqlCodeCapability = capabilities.Column("pg_stat_monitor", "sqlcode")
+)
+
+type queryErrorKey struct {
+	databaseName string
+	sqlState     string
+	queryText    string
+}
+
+// PopulateQueryErrorMetrics reports the number of failed statements per SQLSTATE, query and database. The errors
+// are read from pg_stat_monitor when it is enabled and reports the SQLSTATE of statements, otherwise from the log
+// file configured in ErrorLogPath.
+func PopulateQueryErrorMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, enabledExtensions map[string]bool, errorStore persist.Storer) {
+	isEligible, err := validations.CheckIndividualQueryMetricsFetchEligibility(enabledExtensions)
+	if err != nil {
+		log.Error("Error executing query: %v", err)
+		return
+	}
+	var queryErrorList []interface{}
+	switch {
+	case isEligible && conn.Has(ctx, sqlCodeCapability):
+		queryErrorList, err = getQueryErrorMetrics(ctx, conn, cp, errorStore)
+	case cp.ErrorLogPath != "":
+		log.Debug("Extension 'pg_stat_monitor' is not enabled or lacks %s, counting query errors from %s.", sqlCodeCapability, cp.ErrorLogPath)
+		queryErrorList, err = getLogQueryErrorMetrics(cp, errorStore)
+	default:
+		log.Debug("Extension 'pg_stat_monitor' is not enabled or lacks %s, and no error log path is set.", sqlCodeCapability)
+		return
+	}
+	if err != nil {
+		log.Error("Error fetching query errors: %v", err)
+		selfmetrics.RecordError("PostgresQueryErrors", err)
+		return
+	}
+	if err := errorStore.Save(); err != nil {
+		log.Error("Error saving query error history: %v", err)
+	}
+	if len(queryErrorList) == 0 {
+		log.Debug("No query errors found.")
+		return
+	}
+	err = commonutils.IngestMetric(queryErrorList, "PostgresQueryErrors", pgIntegration, cp)
+	if err != nil {
+		log.Error("Error ingesting query errors: %v", err)
+		return
+	}
+
+	// Increment self-metrics counter
+	selfmetrics.IncQueries()
+}
+
+func getQueryErrorMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters, errorStore persist.Storer) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var lastBucketStartTime int64
+	if _, err := errorStore.Get(queryErrorBucketKey, &lastBucketStartTime); err != nil && err != persist.ErrNotFound {
+		log.Debug("Error reading last ingested pg_stat_monitor error bucket: %v", err)
+	}
+	errorLevel, err := commonutils.FetchVersionSpecificErrorLevel(cp.Version)
+	if err != nil {
+		return nil, err
+	}
+	var queryErrors []datamodels.QueryErrorMetrics
+	// The limit applies to each bucket, so every bucket after the last ingested one is read in full
+	query := fmt.Sprintf(queries.QueryErrors, errorLevel, cp.Databases, lastBucketStartTime, cp.QueryFilter.FetchLimit(cp.QueryMonitoringCountThreshold))
+	if err := conn.QueryContext(ctx, &queryErrors, query); err != nil {
+		return nil, err
+	}
+	source := queryErrorSourcePgStatMonitor
+	var queryErrorList []interface{}
+	reportedByBucket := make(map[int64]int)
+	for _, queryError := range queryErrors {
+		var bucketStartTime int64
+		if queryError.BucketStartTime != nil {
+			bucketStartTime = *queryError.BucketStartTime
+			lastBucketStartTime = max(lastBucketStartTime, bucketStartTime)
+		}
+		if reportedByBucket[bucketStartTime] >= cp.QueryMonitoringCountThreshold {
+			continue
+		}
+		queryError.QueryText = anonymizedQueryText(queryError.QueryText)
+		if !cp.QueryFilter.Allows(commonparameters.QueryAttributes{QueryID: queryError.QueryID, QueryText: queryError.QueryText, Database: queryError.DatabaseName}) {
+			continue
+		}
+		reportedByBucket[bucketStartTime]++
+		queryError.Message = anonymizedErrorMessage(queryError.Message)
+		queryError.SQLStateClass = sqlStateClass(queryError.SQLState)
+		queryError.Source = &source
+		queryErrorList = append(queryErrorList, queryError)
+	}
+	errorStore.Set(queryErrorBucketKey, lastBucketStartTime)
+	return queryErrorList, nil
+}
+
+// getLogQueryErrorMetrics counts the errors written to the log file since the previous run. On the first run
+// the current end of the file is recorded and nothing is reported.
+func getLogQueryErrorMetrics(cp *commonparameters.CommonParameters, errorStore persist.Storer) ([]interface{}, error) {
+	file, err := os.Open(cp.ErrorLogPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	key := errorLogOffsetKey + cp.ErrorLogPath
+	var offset int64
+	if _, err := errorStore.Get(key, &offset); err != nil {
+		errorStore.Set(key, info.Size())
+		return nil, nil
+	}
+	if info.Size() < offset {
+		// The log file was rotated or truncated
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	queryErrors, consumed := parseErrorLog(io.LimitReader(file, commonutils.MaxErrorLogBytesPerRun))
+	errorStore.Set(key, offset+consumed)
+
+	var queryErrorList []interface{}
+	for _, queryError := range queryErrors {
+		if queryError.DatabaseName != nil && !strings.Contains(cp.Databases, fmt.Sprintf("'%s'", *queryError.DatabaseName)) {
+			continue
+		}
+		if !cp.QueryFilter.Allows(commonparameters.QueryAttributes{QueryText: queryError.QueryText, Database: queryError.DatabaseName}) {
+			continue
+		}
+		queryErrorList = append(queryErrorList, queryError)
+	}
+	return queryErrorList, nil
+}
+
+// parseErrorLog counts the error lines of reader by database, SQLSTATE and statement, and returns the number of
+// bytes consumed. A trailing incomplete line is left to be read on the next run.
+func parseErrorLog(reader io.Reader) ([]datamodels.QueryErrorMetrics, int64) {
+	var keys []queryErrorKey
+	queryErrorsByKey := make(map[queryErrorKey]*datamodels.QueryErrorMetrics)
+	var pending *queryErrorKey
+	var pendingMessage string
+	flush := func() {
+		if pending == nil {
+			return
+		}
+		queryError, exists := queryErrorsByKey[*pending]
+		if !exists {
+			queryError = newLogQueryError(*pending, pendingMessage)
+			queryErrorsByKey[*pending] = queryError
+			keys = append(keys, *pending)
+		}
+		*queryError.ErrorCount++
+		pending = nil
+	}
+
+	var consumed int64
+	bufferedReader := bufio.NewReader(reader)
+	for {
+		line, err := bufferedReader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		consumed += int64(len(line))
+		line = strings.TrimRight(line, "\r\n")
+		if match := errorLogLineRegex.FindStringSubmatch(line); match != nil {
+			flush()
+			pending = &queryErrorKey{sqlState: match[1]}
+			pendingMessage = match[2]
+			if database := errorLogDatabaseRegex.FindStringSubmatch(line); database != nil {
+				pending.databaseName = database[1]
+			}
+			continue
+		}
+		if match := errorLogStatementRegex.FindStringSubmatch(line); match != nil && pending != nil {
+			pending.queryText = commonutils.AnonymizeQueryText(match[1])
+			flush()
+		}
+	}
+	flush()
+
+	queryErrors := make([]datamodels.QueryErrorMetrics, 0, len(keys))
+	for _, key := range keys {
+		queryErrors = append(queryErrors, *queryErrorsByKey[key])
+	}
+	return queryErrors, consumed
+}
+
+func newLogQueryError(key queryErrorKey, message string) *datamodels.QueryErrorMetrics {
+	source := queryErrorSourceLog
+	queryError := &datamodels.QueryErrorMetrics{
+		Message:    anonymizedErrorMessage(&message),
+		ErrorCount: new(int64),
+		Source:     &source,
+	}
+	if key.databaseName != "" {
+		queryError.DatabaseName = &key.databaseName
+	}
+	if key.sqlState != "" {
+		queryError.SQLState = &key.sqlState
+		queryError.SQLStateClass = sqlStateClass(queryError.SQLState)
+	}
+	if key.queryText != "" {
+		queryError.QueryText = &key.queryText
+	}
+	return queryError
+}
+
+func anonymizedErrorMessage(message *string) *string {
+	if message == nil {
+		return nil
+	}
+	anonymized := commonutils.AnonymizeErrorMessage(*message)
+	return &anonymized
+}
+
+// sqlStateClass returns the class of a SQLSTATE, its first two characters
+func sqlStateClass(sqlState *string) *string {
+	if sqlState == nil || len(*sqlState) != 5 {
+		return nil
+	}
+	class := (*sqlState)[:2]
+	return &class
+}
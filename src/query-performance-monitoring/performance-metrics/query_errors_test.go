@@ -0,0 +1,142 @@
+package performancemetrics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestGetQueryErrorMetrics(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
+	databaseName := "testdb"
+	cp := common_parameters.SetCommonParameters(args, uint64(16), databaseName)
+	errorStore := persist.NewInMemoryStore()
+
+	columns := []string{"newrelic", "bucket_start_time", "query_id", "query_text", "database_name", "sqlstate", "message", "error_count"}
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(queries.QueryErrors, 21, databaseName, 0, cp.QueryFilter.FetchLimit(10)))).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("newrelic", 1700000000, "1234", "INSERT INTO orders (id) VALUES (7)", databaseName, "23505", "duplicate key value violates unique constraint \"orders_pkey\"", 4))
+
+	queryErrorList, err := getQueryErrorMetrics(context.Background(), conn, cp, errorStore)
+	assert.NoError(t, err)
+	assert.Len(t, queryErrorList, 1)
+	queryError := queryErrorList[0].(datamodels.QueryErrorMetrics)
+	assert.Equal(t, "INSERT INTO orders (id) VALUES (?)", *queryError.QueryText)
+	assert.Equal(t, "23", *queryError.SQLStateClass)
+	assert.Equal(t, int64(4), *queryError.ErrorCount)
+	assert.Equal(t, "pg_stat_monitor", *queryError.Source)
+	assert.Equal(t, "duplicate key value violates unique constraint ?", *queryError.Message)
+
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(queries.QueryErrors, 21, databaseName, 1700000000, cp.QueryFilter.FetchLimit(10)))).WillReturnRows(sqlmock.NewRows(columns))
+	queryErrorList, err = getQueryErrorMetrics(context.Background(), conn, cp, errorStore)
+	assert.NoError(t, err)
+	assert.Empty(t, queryErrorList)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetQueryErrorMetricsV13(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
+	databaseName := "testdb"
+	cp := common_parameters.SetCommonParameters(args, uint64(13), databaseName)
+	errorStore := persist.NewInMemoryStore()
+
+	// ERROR is elevel 20 before PostgreSQL 14
+	columns := []string{"newrelic", "bucket_start_time", "query_id", "query_text", "database_name", "sqlstate", "message", "error_count"}
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(queries.QueryErrors, 20, databaseName, 0, cp.QueryFilter.FetchLimit(10)))).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("newrelic", 1700000000, "1234", "SELECT * FROM missing", databaseName, "42P01", "relation \"missing\" does not exist", 2))
+
+	queryErrorList, err := getQueryErrorMetrics(context.Background(), conn, cp, errorStore)
+	assert.NoError(t, err)
+	assert.Len(t, queryErrorList, 1)
+	assert.Equal(t, "42P01", *queryErrorList[0].(datamodels.QueryErrorMetrics).SQLState)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetQueryErrorMetricsLimitPerBucket(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 1}
+	databaseName := "testdb"
+	cp := common_parameters.SetCommonParameters(args, uint64(16), databaseName)
+	errorStore := persist.NewInMemoryStore()
+
+	columns := []string{"newrelic", "bucket_start_time", "query_id", "query_text", "database_name", "sqlstate", "message", "error_count"}
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(queries.QueryErrors, 21, databaseName, 0, cp.QueryFilter.FetchLimit(1)))).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("newrelic", 1700000000, "1", "SELECT * FROM missing", databaseName, "42P01", "relation \"missing\" does not exist", 9).
+		AddRow("newrelic", 1700000000, "2", "SELECT 1/0", databaseName, "22012", "division by zero", 3).
+		AddRow("newrelic", 1700000060, "2", "SELECT 1/0", databaseName, "22012", "division by zero", 1))
+
+	queryErrorList, err := getQueryErrorMetrics(context.Background(), conn, cp, errorStore)
+	assert.NoError(t, err)
+	assert.Len(t, queryErrorList, 2)
+	assert.Equal(t, int64(1700000000), *queryErrorList[0].(datamodels.QueryErrorMetrics).BucketStartTime)
+	assert.Equal(t, int64(1700000060), *queryErrorList[1].(datamodels.QueryErrorMetrics).BucketStartTime)
+	var lastBucketStartTime int64
+	_, err = errorStore.Get(queryErrorBucketKey, &lastBucketStartTime)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1700000060), lastBucketStartTime)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseErrorLog(t *testing.T) {
+	logLines := strings.Join([]string{
+		"2025-03-01 10:00:00.000 UTC [101] db=testdb,user=app ERROR:  42P01: relation \"missing\" does not exist at character 15",
+		"2025-03-01 10:00:00.000 UTC [101] db=testdb,user=app STATEMENT:  SELECT * FROM missing WHERE id = 1",
+		"2025-03-01 10:00:01.000 UTC [102] db=testdb,user=app ERROR:  42P01: relation \"missing\" does not exist at character 15",
+		"2025-03-01 10:00:01.000 UTC [102] db=testdb,user=app STATEMENT:  SELECT * FROM missing WHERE id = 2",
+		"2025-03-01 10:00:02.000 UTC [103] db=otherdb,user=app FATAL:  28P01: password authentication failed for user \"app\"",
+		"2025-03-01 10:00:03.000 UTC [104] db=testdb,user=app LOG:  duration: 1.000 ms",
+		"2025-03-01 10:00:04.000 UTC [105] db=testdb,user=app ERROR:  22012: division by zero",
+	}, "\n") + "\n2025-03-01 10:00:05.000 UTC [106] db=testdb,user=app ERROR:  incomplete"
+
+	queryErrors, consumed := parseErrorLog(strings.NewReader(logLines))
+	assert.Equal(t, int64(strings.LastIndex(logLines, "\n")+1), consumed)
+	assert.Len(t, queryErrors, 3)
+	assert.Equal(t, "42P01", *queryErrors[0].SQLState)
+	assert.Equal(t, "SELECT * FROM missing WHERE id = ?", *queryErrors[0].QueryText)
+	assert.Equal(t, int64(2), *queryErrors[0].ErrorCount)
+	assert.Equal(t, "otherdb", *queryErrors[1].DatabaseName)
+	assert.Equal(t, "28", *queryErrors[1].SQLStateClass)
+	assert.Equal(t, "password authentication failed for user ?", *queryErrors[1].Message)
+	assert.Nil(t, queryErrors[2].QueryText)
+	assert.Equal(t, "division by zero", *queryErrors[2].Message)
+}
+
+func TestGetLogQueryErrorMetrics(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "postgresql.log")
+	assert.NoError(t, os.WriteFile(logPath, []byte("db=testdb ERROR:  42601: syntax error at or near \"SELEC\"\n"), 0o600))
+	args := args.ArgumentList{QueryMonitoringErrorLogPath: logPath}
+	cp := common_parameters.SetCommonParameters(args, uint64(16), "'testdb'")
+	errorStore := persist.NewInMemoryStore()
+
+	queryErrorList, err := getLogQueryErrorMetrics(cp, errorStore)
+	assert.NoError(t, err)
+	assert.Empty(t, queryErrorList, "errors logged before the first run are not reported")
+
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o600)
+	assert.NoError(t, err)
+	_, err = logFile.WriteString("db=testdb ERROR:  42601: syntax error at or near \"SELEC\"\ndb=otherdb ERROR:  42601: syntax error\n")
+	assert.NoError(t, err)
+	assert.NoError(t, logFile.Close())
+
+	queryErrorList, err = getLogQueryErrorMetrics(cp, errorStore)
+	assert.NoError(t, err)
+	assert.Len(t, queryErrorList, 1)
+	queryError := queryErrorList[0].(datamodels.QueryErrorMetrics)
+	assert.Equal(t, "testdb", *queryError.DatabaseName)
+	assert.Equal(t, int64(1), *queryError.ErrorCount)
+	assert.Equal(t, "log", *queryError.Source)
+}
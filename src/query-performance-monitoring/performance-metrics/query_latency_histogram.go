@@ -0,0 +1,236 @@
+package performancemetrics
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
+)
+
+// histogramBucketKey stores the start time of the last pg_stat_monitor bucket ingested for a query in a database
+func histogramBucketKey(databaseName, queryID string) string {
+	return fmt.Sprintf("pgsm:last_bucket_start_time:%s:%s", databaseName, queryID)
+}
+
+// histogramRangeRegex matches the response time ranges returned by get_histogram_timings, e.g. {0.000 - 3.000}
+var histogramRangeRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*-\s*(\d+(?:\.\d+)?)`)
+
+type histogramRange struct {
+	lower float64
+	upper float64
+}
+
+type histogramKey struct {
+	bucketStartTime int64
+	queryID         string
+	databaseName    string
+}
+
+// PopulateQueryLatencyHistogramMetrics reports the pg_stat_monitor response time histogram of the slow queries for
+// each completed bucket. Buckets are ingested once: the start time of the last ingested bucket of each query and
+// database is kept in bucketStore, so queries entering the slow queries report the buckets they were missing.
+func PopulateQueryLatencyHistogramMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, enabledExtensions map[string]bool, bucketStore persist.Storer) {
+	isEligible, err := validations.CheckIndividualQueryMetricsFetchEligibility(enabledExtensions)
+	if err != nil {
+		log.Error("Error executing query: %v", err)
+		return
+	}
+	if !isEligible {
+		log.Debug("Extension 'pg_stat_monitor' is not enabled.")
+		return
+	}
+	histogramList, err := getQueryLatencyHistogramMetrics(ctx, conn, slowRunningQueries, cp, bucketStore)
+	if err != nil {
+		log.Error("Error fetching query latency histograms: %v", err)
+		selfmetrics.RecordError("PostgresQueryLatencyHistogram", err)
+		return
+	}
+	if err := bucketStore.Save(); err != nil {
+		log.Error("Error saving ingested pg_stat_monitor buckets: %v", err)
+	}
+	if len(histogramList) == 0 {
+		log.Debug("No query latency histograms found.")
+		return
+	}
+	err = commonutils.IngestMetric(histogramList, "PostgresQueryLatencyHistogram", pgIntegration, cp)
+	if err != nil {
+		log.Error("Error ingesting query latency histograms: %v", err)
+		return
+	}
+
+	// Increment self-metrics counter
+	selfmetrics.IncQueries()
+}
+
+func getQueryLatencyHistogramMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, cp *commonparameters.CommonParameters, bucketStore persist.Storer) ([]interface{}, error) {
+	queryIDs := slowQueryIDs(slowRunningQueries)
+	if len(queryIDs) == 0 {
+		log.Debug("No slow running queries found.")
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	lastBucketStartTimes := make(map[string]int64)
+	lastBucketStartTime := func(databaseName, queryID string) int64 {
+		key := histogramBucketKey(databaseName, queryID)
+		if startTime, found := lastBucketStartTimes[key]; found {
+			return startTime
+		}
+		var startTime int64
+		if _, err := bucketStore.Get(key, &startTime); err != nil && err != persist.ErrNotFound {
+			log.Debug("Error reading last ingested pg_stat_monitor bucket: %v", err)
+		}
+		lastBucketStartTimes[key] = startTime
+		return startTime
+	}
+	// Buckets are fetched after the oldest last ingested bucket of the slow queries, and skipped per query
+	var oldestBucketStartTime int64 = -1
+	for _, slowRunningQuery := range slowRunningQueries {
+		if slowRunningQuery.QueryID == nil || slowRunningQuery.DatabaseName == nil {
+			continue
+		}
+		startTime := lastBucketStartTime(*slowRunningQuery.DatabaseName, *slowRunningQuery.QueryID)
+		if oldestBucketStartTime < 0 || startTime < oldestBucketStartTime {
+			oldestBucketStartTime = startTime
+		}
+	}
+	var histogramBins []datamodels.QueryLatencyHistogramBin
+	query := fmt.Sprintf(queries.QueryLatencyHistogram, strings.Join(queryIDs, ", "), cp.Databases, max(oldestBucketStartTime, 0))
+	if err := conn.QueryContext(ctx, &histogramBins, query); err != nil {
+		return nil, err
+	}
+	if len(histogramBins) == 0 {
+		return nil, nil
+	}
+	ranges := getHistogramRanges(ctx, conn)
+
+	var keys []histogramKey
+	binsByKey := make(map[histogramKey][]datamodels.QueryLatencyHistogramBin)
+	ingestedBucketStartTimes := make(map[string]int64)
+	for _, histogramBin := range histogramBins {
+		if histogramBin.BucketStartTime <= lastBucketStartTime(histogramBin.DatabaseName, histogramBin.QueryID) {
+			continue
+		}
+		bucketKey := histogramBucketKey(histogramBin.DatabaseName, histogramBin.QueryID)
+		ingestedBucketStartTimes[bucketKey] = max(ingestedBucketStartTimes[bucketKey], histogramBin.BucketStartTime)
+		key := histogramKey{bucketStartTime: histogramBin.BucketStartTime, queryID: histogramBin.QueryID, databaseName: histogramBin.DatabaseName}
+		if _, exists := binsByKey[key]; !exists {
+			// Rows are ordered by bucket, query and database, so the events keep that order
+			keys = append(keys, key)
+		}
+		binsByKey[key] = append(binsByKey[key], histogramBin)
+	}
+	for bucketKey, startTime := range ingestedBucketStartTimes {
+		bucketStore.Set(bucketKey, startTime)
+	}
+
+	anonymizedQueriesByDB := processForAnonymizeQueryMap(slowRunningQueries)
+	var histogramList []interface{}
+	for _, key := range keys {
+		queryText, found := anonymizedQueriesByDB[key.databaseName][key.queryID]
+		attributes := commonparameters.QueryAttributes{QueryID: &key.queryID, Database: &key.databaseName}
+		if found {
+			attributes.QueryText = &queryText
+		}
+		if !cp.QueryFilter.Allows(attributes) {
+			continue
+		}
+		histogram := newQueryLatencyHistogramMetrics(binsByKey[key], ranges)
+		histogram.QueryText = attributes.QueryText
+		histogramList = append(histogramList, histogram)
+	}
+	return histogramList, nil
+}
+
+// getHistogramRanges returns the response time range of each histogram bin, or nil when it cannot be read
+func getHistogramRanges(ctx context.Context, conn *performancedbconnection.PGSQLConnection) []histogramRange {
+	var timings []string
+	if err := conn.QueryContext(ctx, &timings, queries.QueryLatencyHistogramTimings); err != nil || len(timings) == 0 {
+		log.Debug("Error fetching pg_stat_monitor histogram timings: %v", err)
+		return nil
+	}
+	return parseHistogramRanges(timings[0])
+}
+
+func parseHistogramRanges(timings string) []histogramRange {
+	var ranges []histogramRange
+	for _, match := range histogramRangeRegex.FindAllStringSubmatch(timings, -1) {
+		lower, lowerErr := strconv.ParseFloat(match[1], 64)
+		upper, upperErr := strconv.ParseFloat(match[2], 64)
+		if lowerErr != nil || upperErr != nil {
+			return nil
+		}
+		ranges = append(ranges, histogramRange{lower: lower, upper: upper})
+	}
+	return ranges
+}
+
+// newQueryLatencyHistogramMetrics builds the histogram of a query in a bucket. Bins are named after their upper
+// bound in milliseconds when the ranges are known, and percentiles are interpolated within the bins.
+func newQueryLatencyHistogramMetrics(histogramBins []datamodels.QueryLatencyHistogramBin, ranges []histogramRange) datamodels.QueryLatencyHistogramMetrics {
+	first := histogramBins[0]
+	histogram := datamodels.QueryLatencyHistogramMetrics{
+		QueryID:         &first.QueryID,
+		DatabaseName:    &first.DatabaseName,
+		BucketID:        &first.BucketID,
+		BucketStartTime: &first.BucketStartTime,
+		RespCalls:       make(map[string]int64, len(histogramBins)),
+	}
+	rangesKnown := len(ranges) > 0
+	counts := make([]int64, len(ranges))
+	var calls int64
+	for _, histogramBin := range histogramBins {
+		calls += histogramBin.Calls
+		if histogramBin.Bin >= len(ranges) {
+			rangesKnown = false
+		}
+		if rangesKnown {
+			counts[histogramBin.Bin] += histogramBin.Calls
+		}
+	}
+	for _, histogramBin := range histogramBins {
+		name := fmt.Sprintf("bin_%d", histogramBin.Bin)
+		if rangesKnown {
+			name = "le_" + strconv.FormatFloat(ranges[histogramBin.Bin].upper, 'f', -1, 64)
+		}
+		histogram.RespCalls[name] += histogramBin.Calls
+	}
+	histogram.Calls = &calls
+	if rangesKnown && calls > 0 {
+		histogram.P50InMs = histogramPercentile(counts, ranges, calls, 0.50)
+		histogram.P95InMs = histogramPercentile(counts, ranges, calls, 0.95)
+		histogram.P99InMs = histogramPercentile(counts, ranges, calls, 0.99)
+	}
+	return histogram
+}
+
+func histogramPercentile(counts []int64, ranges []histogramRange, calls int64, percentile float64) *float64 {
+	rank := percentile * float64(calls)
+	var cumulative int64
+	for bin, count := range counts {
+		if count == 0 {
+			continue
+		}
+		if float64(cumulative+count) >= rank {
+			fraction := (rank - float64(cumulative)) / float64(count)
+			value := ranges[bin].lower + fraction*(ranges[bin].upper-ranges[bin].lower)
+			return &value
+		}
+		cumulative += count
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package performancemetrics
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestGetQueryLatencyHistogramMetrics(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
+	databaseName := "testdb"
+	cp := common_parameters.SetCommonParameters(args, uint64(16), databaseName)
+	bucketStore := persist.NewInMemoryStore()
+	queryID := "1234"
+	slowRunningQueries := []datamodels.SlowRunningQueryMetrics{
+		{QueryID: &queryID, DatabaseName: &databaseName, QueryText: stringPtr("SELECT * FROM orders WHERE id = ?")},
+	}
+
+	binColumns := []string{"newrelic", "bucket_id", "bucket_start_time", "query_id", "database_name", "bin", "calls"}
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(queries.QueryLatencyHistogram, queryID, databaseName, 0))).WillReturnRows(sqlmock.NewRows(binColumns).
+		AddRow("newrelic", 3, 1700000000, queryID, databaseName, 0, 60).
+		AddRow("newrelic", 3, 1700000000, queryID, databaseName, 1, 30).
+		AddRow("newrelic", 3, 1700000000, queryID, databaseName, 2, 10))
+	mock.ExpectQuery(regexp.QuoteMeta(queries.QueryLatencyHistogramTimings)).WillReturnRows(sqlmock.NewRows([]string{"get_histogram_timings"}).
+		AddRow("{{0.000 - 10.000}, (10.000 - 100.000), (100.000 - 1000.000}}"))
+
+	histogramList, err := getQueryLatencyHistogramMetrics(context.Background(), conn, slowRunningQueries, cp, bucketStore)
+	assert.NoError(t, err)
+	assert.Len(t, histogramList, 1)
+	histogram := histogramList[0].(datamodels.QueryLatencyHistogramMetrics)
+	assert.Equal(t, int64(100), *histogram.Calls)
+	assert.Equal(t, "SELECT * FROM orders WHERE id = ?", *histogram.QueryText)
+	assert.Equal(t, map[string]int64{"le_10": 60, "le_100": 30, "le_1000": 10}, histogram.RespCalls)
+	assert.InDelta(t, 8.333, *histogram.P50InMs, 0.001)
+	assert.InDelta(t, 550.0, *histogram.P95InMs, 0.001)
+	assert.InDelta(t, 910.0, *histogram.P99InMs, 0.001)
+
+	// The next run only asks for buckets after the ingested one
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(queries.QueryLatencyHistogram, queryID, databaseName, 1700000000))).WillReturnRows(sqlmock.NewRows(binColumns))
+	histogramList, err = getQueryLatencyHistogramMetrics(context.Background(), conn, slowRunningQueries, cp, bucketStore)
+	assert.NoError(t, err)
+	assert.Empty(t, histogramList)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetQueryLatencyHistogramMetricsNewSlowQuery(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
+	databaseName := "testdb"
+	cp := common_parameters.SetCommonParameters(args, uint64(16), databaseName)
+	bucketStore := persist.NewInMemoryStore()
+	bucketStore.Set(histogramBucketKey(databaseName, "1234"), int64(1700000060))
+	slowRunningQueries := []datamodels.SlowRunningQueryMetrics{
+		{QueryID: stringPtr("1234"), DatabaseName: &databaseName},
+		{QueryID: stringPtr("5678"), DatabaseName: &databaseName},
+	}
+
+	// 5678 entered the slow queries in testdb, so its buckets already ingested for 1234 are still reported
+	binColumns := []string{"newrelic", "bucket_id", "bucket_start_time", "query_id", "database_name", "bin", "calls"}
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(queries.QueryLatencyHistogram, "1234, 5678", databaseName, 0))).WillReturnRows(sqlmock.NewRows(binColumns).
+		AddRow("newrelic", 1, 1700000000, "1234", databaseName, 0, 5).
+		AddRow("newrelic", 1, 1700000000, "5678", databaseName, 0, 7).
+		AddRow("newrelic", 2, 1700000060, "1234", databaseName, 0, 3).
+		AddRow("newrelic", 2, 1700000060, "5678", databaseName, 0, 2))
+	mock.ExpectQuery(regexp.QuoteMeta(queries.QueryLatencyHistogramTimings)).WillReturnRows(sqlmock.NewRows([]string{"get_histogram_timings"}))
+
+	histogramList, err := getQueryLatencyHistogramMetrics(context.Background(), conn, slowRunningQueries, cp, bucketStore)
+	assert.NoError(t, err)
+	assert.Len(t, histogramList, 2)
+	for _, histogram := range histogramList {
+		assert.Equal(t, "5678", *histogram.(datamodels.QueryLatencyHistogramMetrics).QueryID)
+	}
+	var lastBucketStartTime int64
+	_, err = bucketStore.Get(histogramBucketKey(databaseName, "5678"), &lastBucketStartTime)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1700000060), lastBucketStartTime)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewQueryLatencyHistogramMetricsWithoutRanges(t *testing.T) {
+	histogram := newQueryLatencyHistogramMetrics([]datamodels.QueryLatencyHistogramBin{
+		{QueryID: "1234", DatabaseName: "testdb", Bin: 0, Calls: 4},
+		{QueryID: "1234", DatabaseName: "testdb", Bin: 5, Calls: 1},
+	}, nil)
+	assert.Equal(t, int64(5), *histogram.Calls)
+	assert.Equal(t, map[string]int64{"bin_0": 4, "bin_5": 1}, histogram.RespCalls)
+	assert.Nil(t, histogram.P50InMs)
+}
@@ -0,0 +1,193 @@
+package performancemetrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
+)
+
+// applicationShareSourceSnapshot labels shares estimated from the active sessions of one pg_stat_activity sample,
+// which is not taken over the interval of the load
+const applicationShareSourceSnapshot = "snapshot"
+
+type userDatabaseKey struct {
+	userName     string
+	databaseName string
+}
+
+// PopulateQueryLoadByUserMetrics reports the execution time, calls and I/O of each role and database since the
+// previous run. The cumulative counters are kept in loadStore between runs.
+func PopulateQueryLoadByUserMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, enabledExtensions map[string]bool, loadStore persist.Storer) {
+	isEligible, err := validations.CheckSlowQueryMetricsFetchEligibility(enabledExtensions)
+	if err != nil {
+		log.Error("Error executing query: %v", err)
+		return
+	}
+	if !isEligible {
+		log.Debug("Extension 'pg_stat_statements' is not enabled.")
+		return
+	}
+	queryLoadList, err := getQueryLoadByUserMetrics(ctx, conn, cp, loadStore)
+	if err != nil {
+		log.Error("Error fetching query load by user: %v", err)
+		selfmetrics.RecordError("PostgresQueryLoadByUser", err)
+		return
+	}
+	if err := loadStore.Save(); err != nil {
+		log.Error("Error saving query load history: %v", err)
+	}
+	if len(queryLoadList) == 0 {
+		log.Debug("No query load by user found.")
+		return
+	}
+	err = commonutils.IngestMetric(queryLoadList, "PostgresQueryLoadByUser", pgIntegration, cp)
+	if err != nil {
+		log.Error("Error ingesting query load by user: %v", err)
+		return
+	}
+
+	// Increment self-metrics counter
+	selfmetrics.IncQueries()
+}
+
+func getQueryLoadByUserMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters, loadStore persist.Storer) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	versionSpecificQuery, err := commonutils.FetchVersionSpecificQueryLoadByUser(cp.Version)
+	if err != nil {
+		return nil, err
+	}
+	var queryLoadTotalsList []datamodels.QueryLoadTotals
+	if err := conn.QueryContext(ctx, &queryLoadTotalsList, fmt.Sprintf(versionSpecificQuery, cp.Databases)); err != nil {
+		return nil, err
+	}
+	applicationSamples := getQueryLoadApplicationSamples(ctx, conn, cp)
+
+	var queryLoadList []interface{}
+	for _, totals := range queryLoadTotalsList {
+		if !cp.QueryFilter.Allows(commonparameters.QueryAttributes{Database: &totals.DatabaseName, User: &totals.UserName}) {
+			continue
+		}
+		delta, intervalSeconds, ok := queryLoadDelta(loadStore, totals)
+		if !ok || delta.Calls == 0 {
+			continue
+		}
+		key := userDatabaseKey{userName: totals.UserName, databaseName: totals.DatabaseName}
+		queryLoadList = append(queryLoadList, attributeQueryLoad(delta, intervalSeconds, applicationSamples[key], cp.QueryFilter)...)
+	}
+	return queryLoadList, nil
+}
+
+// getQueryLoadApplicationSamples returns the active sessions per application of each role and database. It needs
+// the query_id of pg_stat_activity, so it returns nil before version 14.
+func getQueryLoadApplicationSamples(ctx context.Context, conn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters) map[userDatabaseKey][]datamodels.QueryLoadApplicationSample {
+	if !commonutils.ActivityQueryIDVersions.ContainsMajor(cp.Version) {
+		return nil
+	}
+	var samples []datamodels.QueryLoadApplicationSample
+	if err := conn.QueryContext(ctx, &samples, fmt.Sprintf(queries.QueryLoadApplicationSamples, cp.Databases)); err != nil {
+		log.Debug("Error sampling query load by application: %v", err)
+		return nil
+	}
+	samplesByKey := make(map[userDatabaseKey][]datamodels.QueryLoadApplicationSample)
+	for _, sample := range samples {
+		key := userDatabaseKey{userName: sample.UserName, databaseName: sample.DatabaseName}
+		samplesByKey[key] = append(samplesByKey[key], sample)
+	}
+	return samplesByKey
+}
+
+func queryLoadKey(databaseName string, userName string) string {
+	return fmt.Sprintf("load:%s:%s", databaseName, userName)
+}
+
+// queryLoadDelta records totals in loadStore and returns the difference with the totals of the previous run along
+// with the seconds elapsed since. It returns false when the role and database were not seen before.
+func queryLoadDelta(loadStore persist.Storer, totals datamodels.QueryLoadTotals) (datamodels.QueryLoadTotals, float64, bool) {
+	key := queryLoadKey(totals.DatabaseName, totals.UserName)
+	var previous datamodels.QueryLoadTotals
+	capturedAt, err := loadStore.Get(key, &previous)
+	loadStore.Set(key, totals)
+	if err != nil {
+		return totals, 0, false
+	}
+	if totals.Calls < previous.Calls || totals.TotalExecTimeInMs < previous.TotalExecTimeInMs {
+		// Statistics were reset or statements were evicted since the previous run
+		previous = datamodels.QueryLoadTotals{}
+	}
+	delta := totals
+	delta.Calls -= previous.Calls
+	delta.TotalExecTimeInMs -= previous.TotalExecTimeInMs
+	delta.SharedBlksHit -= previous.SharedBlksHit
+	delta.SharedBlksRead -= previous.SharedBlksRead
+	delta.SharedBlksWritten -= previous.SharedBlksWritten
+	delta.TempBlksRead -= previous.TempBlksRead
+	delta.TempBlksWritten -= previous.TempBlksWritten
+	return delta, time.Since(time.Unix(capturedAt, 0)).Seconds(), true
+}
+
+// attributeQueryLoad splits the load of a role between its applications in proportion to their active sessions.
+// The shares are estimates from a single sample of the sessions, and are computed over the applications the query
+// filter keeps so that the reported parts add up to the load of the role. The load is reported without an
+// application when no session was sampled, and not at all when the filter drops every sampled application.
+func attributeQueryLoad(delta datamodels.QueryLoadTotals, intervalSeconds float64, samples []datamodels.QueryLoadApplicationSample, queryFilter *commonparameters.QueryFilter) []interface{} {
+	var sampledSessions, keptSessions int64
+	var keptSamples []datamodels.QueryLoadApplicationSample
+	for _, sample := range samples {
+		sampledSessions += sample.ActiveSessions
+		applicationName := sample.ApplicationName
+		if sample.ActiveSessions <= 0 || !queryFilter.Allows(commonparameters.QueryAttributes{Database: &delta.DatabaseName, User: &delta.UserName, ApplicationName: &applicationName}) {
+			continue
+		}
+		keptSessions += sample.ActiveSessions
+		keptSamples = append(keptSamples, sample)
+	}
+	if sampledSessions == 0 {
+		return []interface{}{newQueryLoadByUserMetrics(delta, intervalSeconds, nil, 1)}
+	}
+	var queryLoadList []interface{}
+	for _, sample := range keptSamples {
+		applicationName := sample.ApplicationName
+		share := float64(sample.ActiveSessions) / float64(keptSessions)
+		queryLoadList = append(queryLoadList, newQueryLoadByUserMetrics(delta, intervalSeconds, &applicationName, share))
+	}
+	return queryLoadList
+}
+
+func newQueryLoadByUserMetrics(delta datamodels.QueryLoadTotals, intervalSeconds float64, applicationName *string, share float64) datamodels.QueryLoadByUserMetrics {
+	scaled := func(value float64) *float64 {
+		scaledValue := value * share
+		return &scaledValue
+	}
+	queryLoad := datamodels.QueryLoadByUserMetrics{
+		UserName:          &delta.UserName,
+		DatabaseName:      &delta.DatabaseName,
+		ApplicationName:   applicationName,
+		IntervalSeconds:   &intervalSeconds,
+		Calls:             scaled(float64(delta.Calls)),
+		ExecTimeInMs:      scaled(delta.TotalExecTimeInMs),
+		SharedBlksHit:     scaled(float64(delta.SharedBlksHit)),
+		SharedBlksRead:    scaled(float64(delta.SharedBlksRead)),
+		SharedBlksWritten: scaled(float64(delta.SharedBlksWritten)),
+		TempBlksRead:      scaled(float64(delta.TempBlksRead)),
+		TempBlksWritten:   scaled(float64(delta.TempBlksWritten)),
+	}
+	if applicationName != nil {
+		shareSource := applicationShareSourceSnapshot
+		queryLoad.ApplicationShare = &share
+		queryLoad.ApplicationShareSource = &shareSource
+	}
+	return queryLoad
+}
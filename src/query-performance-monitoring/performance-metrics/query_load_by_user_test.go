@@ -0,0 +1,107 @@
+package performancemetrics
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestGetQueryLoadByUserMetrics(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
+	databaseName := "testdb"
+	cp := common_parameters.SetCommonParameters(args, uint64(16), databaseName)
+	loadStore := persist.NewInMemoryStore()
+
+	totalsColumns := []string{
+		"newrelic", "user_name", "database_name", "calls", "total_exec_time_ms", "shared_blks_hit",
+		"shared_blks_read", "shared_blks_written", "temp_blks_read", "temp_blks_written",
+	}
+	samplesColumns := []string{"newrelic", "user_name", "database_name", "application_name", "active_sessions"}
+	totalsQuery := regexp.QuoteMeta(fmt.Sprintf(queries.QueryLoadByUserForV13AndAbove, databaseName))
+	samplesQuery := regexp.QuoteMeta(fmt.Sprintf(queries.QueryLoadApplicationSamples, databaseName))
+
+	mock.ExpectQuery(totalsQuery).WillReturnRows(sqlmock.NewRows(totalsColumns).
+		AddRow("newrelic", "app", "testdb", 100, 1000.0, 500, 50, 10, 0, 0))
+	mock.ExpectQuery(samplesQuery).WillReturnRows(sqlmock.NewRows(samplesColumns))
+	queryLoadList, err := getQueryLoadByUserMetrics(context.Background(), conn, cp, loadStore)
+	assert.NoError(t, err)
+	assert.Empty(t, queryLoadList, "first observation has no previous totals")
+
+	mock.ExpectQuery(totalsQuery).WillReturnRows(sqlmock.NewRows(totalsColumns).
+		AddRow("newrelic", "app", "testdb", 140, 1800.0, 700, 90, 10, 4, 8))
+	mock.ExpectQuery(samplesQuery).WillReturnRows(sqlmock.NewRows(samplesColumns).
+		AddRow("newrelic", "app", "testdb", "billing", 3).
+		AddRow("newrelic", "app", "testdb", "reporting", 1))
+	queryLoadList, err = getQueryLoadByUserMetrics(context.Background(), conn, cp, loadStore)
+	assert.NoError(t, err)
+	assert.Len(t, queryLoadList, 2)
+	billing := queryLoadList[0].(datamodels.QueryLoadByUserMetrics)
+	assert.Equal(t, "billing", *billing.ApplicationName)
+	assert.Equal(t, 0.75, *billing.ApplicationShare)
+	assert.Equal(t, "snapshot", *billing.ApplicationShareSource)
+	assert.Equal(t, 30.0, *billing.Calls)
+	assert.Equal(t, 600.0, *billing.ExecTimeInMs)
+	assert.Equal(t, 6.0, *billing.TempBlksWritten)
+	reporting := queryLoadList[1].(datamodels.QueryLoadByUserMetrics)
+	assert.Equal(t, "reporting", *reporting.ApplicationName)
+	assert.Equal(t, 10.0, *reporting.Calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryLoadDeltaAfterReset(t *testing.T) {
+	loadStore := persist.NewInMemoryStore()
+	totals := datamodels.QueryLoadTotals{UserName: "app", DatabaseName: "testdb", Calls: 100, TotalExecTimeInMs: 1000}
+	_, _, ok := queryLoadDelta(loadStore, totals)
+	assert.False(t, ok)
+
+	totals.Calls, totals.TotalExecTimeInMs = 20, 150
+	delta, _, ok := queryLoadDelta(loadStore, totals)
+	assert.True(t, ok)
+	assert.Equal(t, int64(20), delta.Calls)
+	assert.Equal(t, 150.0, delta.TotalExecTimeInMs)
+}
+
+func TestAttributeQueryLoadWithoutSamples(t *testing.T) {
+	delta := datamodels.QueryLoadTotals{UserName: "app", DatabaseName: "testdb", Calls: 5, TotalExecTimeInMs: 50}
+	queryLoadList := attributeQueryLoad(delta, 60, nil, nil)
+	assert.Len(t, queryLoadList, 1)
+	queryLoad := queryLoadList[0].(datamodels.QueryLoadByUserMetrics)
+	assert.Nil(t, queryLoad.ApplicationName)
+	assert.Nil(t, queryLoad.ApplicationShare)
+	assert.Equal(t, 5.0, *queryLoad.Calls)
+}
+
+func TestAttributeQueryLoadFiltered(t *testing.T) {
+	delta := datamodels.QueryLoadTotals{UserName: "app", DatabaseName: "testdb", Calls: 40, TotalExecTimeInMs: 800}
+	samples := []datamodels.QueryLoadApplicationSample{
+		{UserName: "app", DatabaseName: "testdb", ApplicationName: "billing", ActiveSessions: 3},
+		{UserName: "app", DatabaseName: "testdb", ApplicationName: "psql", ActiveSessions: 4},
+		{UserName: "app", DatabaseName: "testdb", ApplicationName: "reporting", ActiveSessions: 1},
+	}
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringFilterRules: `{"exclude": [{"application_name": "^psql$"}]}`}, uint64(14), "testdb")
+
+	queryLoadList := attributeQueryLoad(delta, 60, samples, cp.QueryFilter)
+	assert.Len(t, queryLoadList, 2)
+	var calls, share float64
+	for _, item := range queryLoadList {
+		queryLoad := item.(datamodels.QueryLoadByUserMetrics)
+		calls += *queryLoad.Calls
+		share += *queryLoad.ApplicationShare
+	}
+	assert.Equal(t, 40.0, calls, "the parts add up to the load of the role")
+	assert.Equal(t, 1.0, share)
+
+	cp = common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringFilterRules: `{"include": [{"application_name": "^batch$"}]}`}, uint64(14), "testdb")
+	assert.Empty(t, attributeQueryLoad(delta, 60, samples, cp.QueryFilter))
+}
@@ -12,6 +12,7 @@ import (
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 )
 
 func PopulateSlowRunningMetrics(conn *connpkg.PGSQLConnection, pgInt *integration.Integration, cp *commonparams.CommonParameters, exts map[string]bool) []datamodels.SlowRunningQueryMetrics {
@@ -25,6 +26,7 @@ func PopulateSlowRunningMetrics(conn *connpkg.PGSQLConnection, pgInt *integratio
 	list, iface, err := getSlowRunningMetrics(conn, cp)
 	if err != nil {
 		log.Error("slow query fetch: %v", err)
+		selfmetrics.RecordError("PostgresSlowQueries", err)
 		return nil
 	}
 	if len(list) == 0 {
@@ -46,7 +48,7 @@ func getSlowRunningMetrics(conn *connpkg.PGSQLConnection, cp *commonparams.Commo
 		return nil, nil, err
 	}
 
-	query := fmt.Sprintf(tpl, cp.Databases, cp.QueryMonitoringCountThreshold)
+	query := fmt.Sprintf(tpl, cp.Databases, cp.QueryFilter.FetchLimit(cp.QueryMonitoringCountThreshold))
 	rows, err := conn.QueryxContext(ctx, query)
 	if err != nil {
 		return nil, nil, err
@@ -56,11 +58,17 @@ func getSlowRunningMetrics(conn *connpkg.PGSQLConnection, cp *commonparams.Commo
 	var list []datamodels.SlowRunningQueryMetrics
 	var iface []interface{}
 
-	for rows.Next() {
+	for len(list) < cp.QueryMonitoringCountThreshold && rows.Next() {
 		var m datamodels.SlowRunningQueryMetrics
 		if err := rows.StructScan(&m); err != nil {
 			return nil, nil, err
 		}
+		if !cp.QueryFilter.Allows(commonparams.QueryAttributes{QueryID: m.QueryID, QueryText: m.QueryText, Database: m.DatabaseName, User: m.UserName, StatementType: m.StatementType}) {
+			continue
+		}
+		if m.QueryText != nil {
+			m.QueryTags = commonutils.ExtractQueryTags(*m.QueryText)
+		}
 		list = append(list, m)
 		iface = append(iface, m)
 	}
@@ -20,7 +20,7 @@ func runSlowQueryTest(t *testing.T, query string, version uint64, expectedLength
 	databaseName := "testdb"
 	cp := common_parameters.SetCommonParameters(args, version, databaseName)
 
-	query = fmt.Sprintf(query, "testdb", args.QueryMonitoringCountThreshold)
+	query = fmt.Sprintf(query, "testdb", cp.QueryFilter.FetchLimit(args.QueryMonitoringCountThreshold))
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
 		"newrelic", "query_id", "query_text", "database_name", "schema_name", "execution_count",
 		"avg_elapsed_time_ms", "avg_disk_reads", "avg_disk_writes", "statement_type", "collection_timestamp",
@@ -39,7 +39,12 @@ func TestGetSlowRunningMetrics(t *testing.T) {
 }
 
 func TestGetSlowRunningMetricsV12(t *testing.T) {
-	runSlowQueryTest(t, queries.SlowQueriesForV12, 12, 1)
+	runSlowQueryTest(t, queries.SlowQueriesForV10ToV12, 12, 1)
+}
+
+func TestGetSlowRunningMetricsV10AndV11(t *testing.T) {
+	runSlowQueryTest(t, queries.SlowQueriesForV10ToV12, 10, 1)
+	runSlowQueryTest(t, queries.SlowQueriesForV10ToV12, 11, 1)
 }
 
 func TestGetSlowRunningEmptyMetrics(t *testing.T) {
@@ -49,7 +54,7 @@ func TestGetSlowRunningEmptyMetrics(t *testing.T) {
 	version := uint64(13)
 	cp := common_parameters.SetCommonParameters(args, version, databaseName)
 	expectedQuery := queries.SlowQueriesForV13AndAbove
-	query := fmt.Sprintf(expectedQuery, "testdb", args.QueryMonitoringCountThreshold)
+	query := fmt.Sprintf(expectedQuery, "testdb", cp.QueryFilter.FetchLimit(args.QueryMonitoringCountThreshold))
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
 		"newrelic", "query_id", "query_text", "database_name", "schema_name", "execution_count",
 		"avg_elapsed_time_ms", "avg_disk_reads", "avg_disk_writes", "statement_type", "collection_timestamp",
@@ -65,10 +70,55 @@ func TestGetSlowRunningMetricsUnsupportedVersion(t *testing.T) {
 	conn, mock := connection.CreateMockSQL(t)
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	databaseName := "testdb"
-	version := uint64(11)
+	version := uint64(9)
 	cp := common_parameters.SetCommonParameters(args, version, databaseName)
 	slowQueryList, _, err := getSlowRunningMetrics(conn, cp)
 	assert.EqualError(t, err, commonutils.ErrUnsupportedVersion.Error())
 	assert.Len(t, slowQueryList, 0)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestGetSlowRunningMetricsFiltered(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 10, QueryMonitoringFilterRules: `{"exclude": [{"user": "^batch$"}]}`}
+	cp := common_parameters.SetCommonParameters(args, uint64(13), "testdb")
+
+	query := fmt.Sprintf(queries.SlowQueriesForV13AndAbove, "testdb", cp.QueryFilter.FetchLimit(args.QueryMonitoringCountThreshold))
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
+		"newrelic", "query_id", "query_text", "database_name", "schema_name", "user_name", "execution_count",
+		"avg_elapsed_time_ms", "avg_disk_reads", "avg_disk_writes", "statement_type", "collection_timestamp",
+	}).AddRow(
+		"newrelic_value", "queryid1", "SELECT 1", "testdb", "public", "app", 10,
+		15.0, 5, 2, "SELECT", "2023-01-01T00:00:00Z",
+	).AddRow(
+		"newrelic_value", "queryid2", "SELECT 2", "testdb", "public", "batch", 10,
+		15.0, 5, 2, "SELECT", "2023-01-01T00:00:00Z",
+	))
+	slowQueryList, _, err := getSlowRunningMetrics(conn, cp)
+	assert.NoError(t, err)
+	assert.Len(t, slowQueryList, 1)
+	assert.Equal(t, "queryid1", *slowQueryList[0].QueryID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSlowRunningMetricsFilteredBeforeLimit(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 1}
+	cp := common_parameters.SetCommonParameters(args, uint64(13), "testdb")
+
+	query := fmt.Sprintf(queries.SlowQueriesForV13AndAbove, "testdb", 5)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
+		"newrelic", "query_id", "query_text", "database_name", "execution_count", "avg_elapsed_time_ms",
+	}).AddRow(
+		"newrelic_value", "queryid1", "EXPLAIN (VERBOSE, FORMAT JSON) SELECT * FROM orders", "testdb", 1, 900.0,
+	).AddRow(
+		"newrelic_value", "queryid2", "SELECT * FROM orders", "testdb", 10, 15.0,
+	).AddRow(
+		"newrelic_value", "queryid3", "SELECT * FROM customers", "testdb", 10, 12.0,
+	))
+	slowQueryList, _, err := getSlowRunningMetrics(conn, cp)
+	assert.NoError(t, err)
+	assert.Len(t, slowQueryList, 1)
+	assert.Equal(t, "queryid2", *slowQueryList[0].QueryID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,184 @@
+package performancemetrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
+)
+
+// PopulateTempSpillMetrics reports the queries responsible for most of the temporary file I/O since the previous
+// run, next to the work_mem setting and the temporary bytes written by their database. The cumulative counters are
+// kept in spillStore between runs.
+func PopulateTempSpillMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, enabledExtensions map[string]bool, spillStore persist.Storer) {
+	isEligible, err := validations.CheckSlowQueryMetricsFetchEligibility(enabledExtensions)
+	if err != nil {
+		log.Error("Error executing query: %v", err)
+		return
+	}
+	if !isEligible {
+		log.Debug("Extension 'pg_stat_statements' is not enabled.")
+		return
+	}
+	tempSpillList, err := getTempSpillMetrics(ctx, conn, cp, spillStore)
+	if err != nil {
+		log.Error("Error fetching temporary file spills: %v", err)
+		selfmetrics.RecordError("PostgresTempSpill", err)
+		return
+	}
+	if err := spillStore.Save(); err != nil {
+		log.Error("Error saving temporary file spill history: %v", err)
+	}
+	if len(tempSpillList) == 0 {
+		log.Debug("No temporary file spills found.")
+		return
+	}
+	err = commonutils.IngestMetric(tempSpillList, "PostgresTempSpill", pgIntegration, cp)
+	if err != nil {
+		log.Error("Error ingesting temporary file spills: %v", err)
+		return
+	}
+
+	// Increment self-metrics counter
+	selfmetrics.IncQueries()
+}
+
+func getTempSpillMetrics(ctx context.Context, conn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters, spillStore persist.Storer) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var queryTotalsList []datamodels.TempSpillQueryTotals
+	if err := conn.QueryContext(ctx, &queryTotalsList, fmt.Sprintf(queries.TempSpillByQuery, cp.Databases, commonutils.MaxTempSpillTrackedQueries)); err != nil {
+		return nil, err
+	}
+	var databaseTotalsList []datamodels.TempSpillDatabaseTotals
+	if err := conn.QueryContext(ctx, &databaseTotalsList, fmt.Sprintf(queries.TempSpillByDatabase, cp.Databases)); err != nil {
+		return nil, err
+	}
+	var settings []datamodels.TempSpillSettings
+	if err := conn.QueryContext(ctx, &settings, queries.TempSpillSettings); err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return nil, commonutils.ErrUnExpectedError
+	}
+
+	databaseDeltas := make(map[string]datamodels.TempSpillDatabaseTotals)
+	for _, databaseTotals := range databaseTotalsList {
+		if delta, ok := tempSpillDatabaseDelta(spillStore, databaseTotals); ok {
+			databaseDeltas[databaseTotals.DatabaseName] = delta
+		}
+	}
+
+	type queryDelta struct {
+		delta           datamodels.TempSpillQueryTotals
+		intervalSeconds float64
+	}
+	var queryDeltas []queryDelta
+	for _, queryTotals := range queryTotalsList {
+		delta, intervalSeconds, ok := tempSpillQueryDelta(spillStore, queryTotals)
+		if !ok || delta.TempBlksWritten == 0 {
+			continue
+		}
+		if !cp.QueryFilter.Allows(commonparameters.QueryAttributes{QueryID: &delta.QueryID, QueryText: &delta.QueryText, Database: &delta.DatabaseName}) {
+			continue
+		}
+		queryDeltas = append(queryDeltas, queryDelta{delta: delta, intervalSeconds: intervalSeconds})
+	}
+	sort.SliceStable(queryDeltas, func(i, j int) bool {
+		return queryDeltas[i].delta.TempBlksWritten > queryDeltas[j].delta.TempBlksWritten
+	})
+	if len(queryDeltas) > cp.QueryMonitoringCountThreshold {
+		queryDeltas = queryDeltas[:cp.QueryMonitoringCountThreshold]
+	}
+
+	var tempSpillList []interface{}
+	for _, queryDelta := range queryDeltas {
+		databaseDelta, found := databaseDeltas[queryDelta.delta.DatabaseName]
+		tempSpillList = append(tempSpillList, newTempSpillMetrics(queryDelta.delta, queryDelta.intervalSeconds, settings[0], databaseDelta, found))
+	}
+	return tempSpillList, nil
+}
+
+// tempSpillQueryDelta records the totals of a query in spillStore and returns the difference with the totals of
+// the previous run along with the seconds elapsed since. It returns false when the query was not seen before.
+func tempSpillQueryDelta(spillStore persist.Storer, totals datamodels.TempSpillQueryTotals) (datamodels.TempSpillQueryTotals, float64, bool) {
+	key := fmt.Sprintf("temp:%s:%s", totals.DatabaseName, totals.QueryID)
+	var previous datamodels.TempSpillQueryTotals
+	capturedAt, err := spillStore.Get(key, &previous)
+	spillStore.Set(key, datamodels.TempSpillQueryTotals{Calls: totals.Calls, TempBlksRead: totals.TempBlksRead, TempBlksWritten: totals.TempBlksWritten})
+	if err != nil {
+		return totals, 0, false
+	}
+	if totals.Calls < previous.Calls || totals.TempBlksWritten < previous.TempBlksWritten {
+		// Statistics were reset or the statement was evicted since the previous run
+		previous = datamodels.TempSpillQueryTotals{}
+	}
+	delta := totals
+	delta.Calls -= previous.Calls
+	delta.TempBlksRead -= previous.TempBlksRead
+	delta.TempBlksWritten -= previous.TempBlksWritten
+	return delta, time.Since(time.Unix(capturedAt, 0)).Seconds(), true
+}
+
+func tempSpillDatabaseDelta(spillStore persist.Storer, totals datamodels.TempSpillDatabaseTotals) (datamodels.TempSpillDatabaseTotals, bool) {
+	key := fmt.Sprintf("tempdb:%s", totals.DatabaseName)
+	var previous datamodels.TempSpillDatabaseTotals
+	_, err := spillStore.Get(key, &previous)
+	spillStore.Set(key, totals)
+	if err != nil {
+		return totals, false
+	}
+	if totals.TempFiles < previous.TempFiles || totals.TempBytes < previous.TempBytes {
+		// Statistics were reset since the previous run
+		previous = datamodels.TempSpillDatabaseTotals{}
+	}
+	delta := totals
+	delta.TempFiles -= previous.TempFiles
+	delta.TempBytes -= previous.TempBytes
+	return delta, true
+}
+
+func newTempSpillMetrics(delta datamodels.TempSpillQueryTotals, intervalSeconds float64, settings datamodels.TempSpillSettings, databaseDelta datamodels.TempSpillDatabaseTotals, databaseDeltaFound bool) datamodels.TempSpillMetrics {
+	tempWrittenBytes := delta.TempBlksWritten * settings.BlockSize
+	tempSpill := datamodels.TempSpillMetrics{
+		QueryID:          &delta.QueryID,
+		QueryText:        &delta.QueryText,
+		DatabaseName:     &delta.DatabaseName,
+		IntervalSeconds:  &intervalSeconds,
+		Calls:            &delta.Calls,
+		TempBlksRead:     &delta.TempBlksRead,
+		TempBlksWritten:  &delta.TempBlksWritten,
+		TempWrittenBytes: &tempWrittenBytes,
+		WorkMemInKB:      &settings.WorkMemInKB,
+	}
+	if delta.Calls > 0 {
+		tempWrittenBytesPerCall := float64(tempWrittenBytes) / float64(delta.Calls)
+		tempSpill.TempWrittenBytesPerCall = &tempWrittenBytesPerCall
+		if settings.WorkMemInKB > 0 {
+			// How many times work_mem each execution wrote to temporary files
+			spillToWorkMemRatio := tempWrittenBytesPerCall / float64(settings.WorkMemInKB*1024)
+			tempSpill.SpillToWorkMemRatio = &spillToWorkMemRatio
+		}
+	}
+	if databaseDeltaFound {
+		tempSpill.DatabaseTempFiles = &databaseDelta.TempFiles
+		tempSpill.DatabaseTempBytes = &databaseDelta.TempBytes
+		if databaseDelta.TempBytes > 0 {
+			databaseTempShare := float64(tempWrittenBytes) / float64(databaseDelta.TempBytes)
+			tempSpill.DatabaseTempShare = &databaseTempShare
+		}
+	}
+	return tempSpill
+}
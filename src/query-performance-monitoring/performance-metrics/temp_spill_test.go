@@ -0,0 +1,57 @@
+package performancemetrics
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestGetTempSpillMetrics(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	args := args.ArgumentList{QueryMonitoringCountThreshold: 1}
+	databaseName := "testdb"
+	cp := common_parameters.SetCommonParameters(args, uint64(16), databaseName)
+	spillStore := persist.NewInMemoryStore()
+
+	queryColumns := []string{"newrelic", "query_id", "query_text", "database_name", "calls", "temp_blks_read", "temp_blks_written"}
+	databaseColumns := []string{"database_name", "temp_files", "temp_bytes"}
+	expectRun := func(sortWritten int64, hashWritten int64, databaseTempBytes int64) {
+		mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(queries.TempSpillByQuery, databaseName, commonutils.MaxTempSpillTrackedQueries))).WillReturnRows(sqlmock.NewRows(queryColumns).
+			AddRow("newrelic", "1", "SELECT * FROM orders ORDER BY created_at", databaseName, 10+sortWritten/100, sortWritten, sortWritten).
+			AddRow("newrelic", "2", "SELECT customer_id, COUNT(*) FROM orders GROUP BY customer_id", databaseName, 10+hashWritten/100, hashWritten, hashWritten))
+		mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(queries.TempSpillByDatabase, databaseName))).WillReturnRows(sqlmock.NewRows(databaseColumns).
+			AddRow(databaseName, 5, databaseTempBytes))
+		mock.ExpectQuery(regexp.QuoteMeta(queries.TempSpillSettings)).WillReturnRows(sqlmock.NewRows([]string{"work_mem_kb", "block_size"}).
+			AddRow(4096, 8192))
+	}
+
+	expectRun(1000, 1000, 1000*8192*2)
+	tempSpillList, err := getTempSpillMetrics(context.Background(), conn, cp, spillStore)
+	assert.NoError(t, err)
+	assert.Empty(t, tempSpillList, "first observation has no previous totals")
+
+	expectRun(1200, 2000, 1000*8192*2+1200*8192)
+	tempSpillList, err = getTempSpillMetrics(context.Background(), conn, cp, spillStore)
+	assert.NoError(t, err)
+	assert.Len(t, tempSpillList, 1, "limited to the query count threshold")
+	tempSpill := tempSpillList[0].(datamodels.TempSpillMetrics)
+	assert.Equal(t, "2", *tempSpill.QueryID)
+	assert.Equal(t, int64(1000), *tempSpill.TempBlksWritten)
+	assert.Equal(t, int64(10), *tempSpill.Calls)
+	assert.Equal(t, int64(1000*8192), *tempSpill.TempWrittenBytes)
+	assert.Equal(t, int64(4096), *tempSpill.WorkMemInKB)
+	assert.InDelta(t, 100*8192.0/(4096*1024), *tempSpill.SpillToWorkMemRatio, 0.0001)
+	assert.InDelta(t, 1000.0/1200, *tempSpill.DatabaseTempShare, 0.0001)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
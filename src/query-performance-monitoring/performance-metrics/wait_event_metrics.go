@@ -12,8 +12,8 @@ import (
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
-	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/selfmetrics"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 )
 
 func PopulateWaitEventMetrics(ctx context.Context, conn *connpkg.PGSQLConnection, pgInt *integration.Integration, cp *commonparams.CommonParameters, exts map[string]bool) error {
@@ -30,6 +30,7 @@ func PopulateWaitEventMetrics(ctx context.Context, conn *connpkg.PGSQLConnection
 	iface, err := getWaitEventMetrics(ctx, conn, cp)
 	if err != nil {
 		log.Error("wait-event fetch: %v", err)
+		selfmetrics.RecordError("PostgresWaitEvents", err)
 		return err
 	}
 	if len(iface) == 0 {
@@ -43,7 +44,7 @@ func getWaitEventMetrics(ctx context.Context, conn *connpkg.PGSQLConnection, cp
 	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
 	defer cancel()
 
-	query := fmt.Sprintf(queries.WaitEvents, cp.Databases, cp.QueryMonitoringCountThreshold)
+	query := fmt.Sprintf(queries.WaitEvents, cp.Databases, cp.QueryFilter.FetchLimit(cp.QueryMonitoringCountThreshold))
 	rows, err := conn.QueryxContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -51,11 +52,14 @@ func getWaitEventMetrics(ctx context.Context, conn *connpkg.PGSQLConnection, cp
 	defer rows.Close()
 
 	var out []interface{}
-	for rows.Next() {
+	for len(out) < cp.QueryMonitoringCountThreshold && rows.Next() {
 		var m datamodels.WaitEventMetrics
 		if err := rows.StructScan(&m); err != nil {
 			return nil, err
 		}
+		if !cp.QueryFilter.Allows(commonparams.QueryAttributes{QueryID: m.QueryID, QueryText: m.QueryText, Database: m.DatabaseName}) {
+			continue
+		}
 		out = append(out, m)
 	}
 	return out, nil
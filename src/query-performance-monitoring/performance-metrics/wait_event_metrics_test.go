@@ -21,7 +21,7 @@ func TestGetWaitEventMetrics(t *testing.T) {
 	cp := common_parameters.SetCommonParameters(args, uint64(14), databaseName)
 	ctx := context.Background()
 
-	var query = fmt.Sprintf(queries.WaitEvents, databaseName, args.QueryMonitoringCountThreshold)
+	var query = fmt.Sprintf(queries.WaitEvents, databaseName, cp.QueryFilter.FetchLimit(args.QueryMonitoringCountThreshold))
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
 		"wait_event_name", "wait_category", "total_wait_time_ms", "collection_timestamp", "query_id", "query_text", "database_name",
 	}).AddRow(
@@ -41,7 +41,7 @@ func TestGetWaitEventEmptyMetrics(t *testing.T) {
 	cp := common_parameters.SetCommonParameters(args, uint64(14), databaseName)
 	ctx := context.Background()
 
-	var query = fmt.Sprintf(queries.WaitEvents, databaseName, args.QueryMonitoringCountThreshold)
+	var query = fmt.Sprintf(queries.WaitEvents, databaseName, cp.QueryFilter.FetchLimit(args.QueryMonitoringCountThreshold))
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
 		"wait_event_name", "wait_category", "total_wait_time_ms", "collection_timestamp", "query_id", "query_text", "database_name",
 	}))
@@ -1,12 +1,19 @@
 // Package queries contains the collection methods to parse and build the collection schema
 package queries
 
+import "github.com/newrelic/nri-postgresql/src/connection"
+
+// integrationApplicationName is the quoted application_name the sessions of the integration connect with, which the
+// queries leave out
+const integrationApplicationName = `'` + connection.ApplicationName + `'`
+
 const (
 	SlowQueriesForV13AndAbove = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
 		pss.queryid AS query_id, -- Unique identifier for the query
 		LEFT(pss.query, 4095) AS query_text, -- Query text truncated to 4095 characters
 		pd.datname AS database_name, -- Name of the database
 		current_schema() AS schema_name, -- Name of the current schema
+		pg_get_userbyid(pss.userid) AS user_name, -- Name of the user who executed the query
 		pss.calls AS execution_count, -- Number of times the query was executed
 		ROUND((pss.total_exec_time / pss.calls)::numeric, 3) AS avg_elapsed_time_ms, -- Average execution time in milliseconds
 		pss.shared_blks_read / pss.calls AS avg_disk_reads, -- Average number of disk reads per execution
@@ -25,23 +32,17 @@ const (
 		pg_database pd ON pss.dbid = pd.oid
 	WHERE 
 		pd.datname in (%s) -- List of database names
-		AND pss.query NOT ILIKE 'EXPLAIN (FORMAT JSON)%%' -- Exclude EXPLAIN queries
-		AND pss.query NOT ILIKE 'SELECT $1 as newrelic%%' -- Exclude specific New Relic queries
-		AND pss.query NOT ILIKE 'WITH wait_history AS%%' -- Exclude specific WITH queries
-		AND pss.query NOT ILIKE 'select -- BLOATQUERY%%' -- Exclude BLOATQUERY
-		AND pss.query NOT ILIKE 'select -- INDEXQUERY%%' -- Exclude INDEXQUERY
-		AND pss.query NOT ILIKE 'SELECT -- TABLEQUERY%%' -- Exclude TABLEQUERY
-		AND pss.query NOT ILIKE 'SELECT table_schema%%' -- Exclude table_schema queries
 	ORDER BY
 		avg_elapsed_time_ms DESC -- Order by the average elapsed time in descending order
 	LIMIT %d;`
 
-	// SlowQueriesForV12 retrieves slow queries and their statistics for PostgreSQL version 12
-	SlowQueriesForV12 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+	// SlowQueriesForV10ToV12 retrieves slow queries and their statistics for PostgreSQL versions 10 to 12, where pg_stat_statements reports total_time
+	SlowQueriesForV10ToV12 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
 		pss.queryid AS query_id, -- Unique identifier for the query
 		LEFT(pss.query, 4095) AS query_text, -- Query text truncated to 4095 characters
 		pd.datname AS database_name, -- Name of the database
 		current_schema() AS schema_name, -- Name of the current schema
+		pg_get_userbyid(pss.userid) AS user_name, -- Name of the user who executed the query
 		pss.calls AS execution_count, -- Number of times the query was executed
 		ROUND((pss.total_time / pss.calls)::numeric, 3) AS avg_elapsed_time_ms, -- Average execution time in milliseconds
 		pss.shared_blks_read / pss.calls AS avg_disk_reads, -- Average number of disk reads per execution
@@ -60,14 +61,6 @@ const (
 		pg_database pd ON pss.dbid = pd.oid
 		WHERE 
 		pd.datname in (%s) -- List of database names
-		AND pss.query NOT ILIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
-		AND pss.query NOT ILIKE 'SELECT $1 as newrelic%%' -- Exclude specific New Relic queries
-		AND pss.query NOT ILIKE 'WITH wait_history AS%%' -- Exclude specific WITH queries
-		AND pss.query NOT ILIKE 'select -- BLOATQUERY%%' -- Exclude BLOATQUERY
-		AND pss.query NOT ILIKE 'select -- INDEXQUERY%%' -- Exclude INDEXQUERY
-		AND pss.query NOT ILIKE 'SELECT -- TABLEQUERY%%' -- Exclude TABLEQUERY
-		AND pss.query NOT ILIKE 'SELECT table_schema%%' -- Exclude table_schema queries
-		AND pss.query NOT ILIKE 'SELECT D.datname%%' -- Exclude specific datname queries
 	ORDER BY
 		avg_elapsed_time_ms DESC -- Order by the average elapsed time in descending order
 	LIMIT
@@ -106,7 +99,7 @@ const (
 		query_text, -- Query text
 		database_name -- Name of the database
 	FROM wait_history
-	WHERE query_text NOT LIKE 'EXPLAIN (FORMAT JSON) %%' AND query_id IS NOT NULL AND event_type IS NOT NULL
+	WHERE query_text NOT LIKE 'EXPLAIN (%%FORMAT JSON) %%' AND query_id IS NOT NULL AND event_type IS NOT NULL
 	GROUP BY event_type, event, query_id, query_text, database_name
 	ORDER BY total_wait_time_ms DESC -- Order by the total wait time in descending order
 	LIMIT %d; -- Limit the number of results`
@@ -139,13 +132,13 @@ const (
 		JOIN pg_stat_statements AS blocking_statements ON blocking_activity.query_id = blocking_statements.queryid
 		WHERE NOT blocked_locks.granted
 		  AND blocked_activity.datname IN (%s) -- List of database names
-		  AND blocked_statements.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
-		  AND blocking_statements.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
+		  AND blocked_activity.application_name <> ` + integrationApplicationName + ` -- Exclude the sessions of the integration
+		  AND blocking_activity.application_name <> ` + integrationApplicationName + ` -- Exclude the sessions of the integration
 		ORDER BY blocked_activity.query_start ASC -- Order by the start time of the blocked query in ascending order
 		LIMIT %d; -- Limit the number of results`
 
-	// BlockingQueriesForV12AndV13 retrieves information about blocking and blocked queries for PostgreSQL versions 12 and 13
-	BlockingQueriesForV12AndV13 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+	// BlockingQueriesForV10ToV13 retrieves information about blocking and blocked queries for PostgreSQL versions 10 to 13, where pg_stat_activity has no query_id
+	BlockingQueriesForV10ToV13 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
 		blocked_activity.pid AS blocked_pid, -- Process ID of the blocked query
 		LEFT(blocked_activity.query, 4095) AS blocked_query, -- Blocked query text truncated to 4095 characters
 		blocked_activity.query_start AS blocked_query_start, -- Start time of the blocked query
@@ -168,8 +161,8 @@ const (
 	JOIN pg_stat_activity AS blocking_activity ON blocking_locks.pid = blocking_activity.pid
 	WHERE NOT blocked_locks.granted
 		AND blocked_activity.datname IN (%s) -- List of database names
-		AND blocked_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
-		AND blocking_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
+		AND blocked_activity.application_name <> ` + integrationApplicationName + ` -- Exclude the sessions of the integration
+		AND blocking_activity.application_name <> ` + integrationApplicationName + ` -- Exclude the sessions of the integration
 		ORDER BY blocked_activity.query_start ASC -- Order by the start time of the blocked query in ascending order
 		LIMIT %d; -- Limit the number of results`
 
@@ -187,15 +180,15 @@ const (
 		 queryid = %s -- Query identifier
 		 AND datname IN (%s) -- List of database names
 		 AND (total_exec_time / NULLIF(calls, 0)) > %d -- Minimum average execution time
-		 AND bucket_start_time >= NOW() - INTERVAL '60 seconds' -- Time interval
+		 AND bucket_start_time >= (SELECT MAX(bucket_start_time) FROM pg_stat_monitor) - current_setting('pg_stat_monitor.pgsm_bucket_time')::int * INTERVAL '1 second' -- Current and previous buckets
 		GROUP BY
 		 query, queryid, datname, planid, cpu_user_time, cpu_sys_time, calls, total_exec_time
 		ORDER BY
 		 exec_time_ms DESC -- Order by average execution time in descending order
 		LIMIT %d; -- Limit the number of results`
 
-	// IndividualQuerySearchV12 retrieves individual query statistics for PostgreSQL version 12
-	IndividualQuerySearchV12 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+	// IndividualQuerySearchV11AndV12 retrieves individual query statistics for PostgreSQL versions 11 and 12. pg_stat_monitor does not support older versions
+	IndividualQuerySearchV11AndV12 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
 		 LEFT(query, 4095) as query, -- Query text truncated to 4095 characters
 		 queryid, -- Unique identifier for the query
 		 datname, -- Name of the database
@@ -208,10 +201,206 @@ const (
 		 queryid = %s -- Query identifier
 		 AND datname IN (%s) -- List of database names
 		 AND (total_time / NULLIF(calls, 0)) > %d -- Minimum average execution time
-		 AND bucket_start_time >= NOW() - INTERVAL '60 seconds' -- Time interval
+		 AND bucket_start_time >= (SELECT MAX(bucket_start_time) FROM pg_stat_monitor) - current_setting('pg_stat_monitor.pgsm_bucket_time')::int * INTERVAL '1 second' -- Current and previous buckets
 		GROUP BY
 		 query, queryid, datname, planid, cpu_user_time, cpu_sys_time, calls, total_time
 		ORDER BY
 		 exec_time_ms DESC -- Order by average execution time in descending order
 		LIMIT %d; -- Limit the number of results`
+
+	// IndividualQuerySearchFromActivity samples the running statements of slow queries from pg_stat_activity for PostgreSQL version 14 and above
+	IndividualQuerySearchFromActivity = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+		 LEFT(query, 4095) as query, -- Query text truncated to 4095 characters
+		 query_id::text AS queryid, -- Unique identifier for the query
+		 datname, -- Name of the database
+		 ROUND((EXTRACT(EPOCH FROM (clock_timestamp() - query_start)) * 1000)::numeric, 3) AS duration_ms, -- Time the statement has been running in milliseconds
+		 wait_event_type, -- Type of the wait event the backend is waiting on
+		 wait_event, -- Wait event the backend is waiting on
+		 state -- State of the backend
+		FROM
+		 pg_stat_activity
+		WHERE
+		 query_id IN (%s) -- Query identifiers
+		 AND datname IN (%s) -- List of database names
+		 AND state = 'active' -- Running statements only
+		 AND application_name <> ` + integrationApplicationName + ` -- Exclude the sessions of the integration
+		ORDER BY
+		 duration_ms DESC -- Order by running time in descending order
+		LIMIT %d; -- Limit the number of results`
+
+	// QueryLatencyHistogram retrieves the response time histogram of queries per pg_stat_monitor bucket, one row per histogram bin, for the buckets completed since the last ingested one
+	QueryLatencyHistogram = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+		 pgsm.bucket AS bucket_id, -- Identifier of the bucket
+		 EXTRACT(EPOCH FROM pgsm.bucket_start_time)::bigint AS bucket_start_time, -- Start time of the bucket in seconds since the epoch
+		 pgsm.queryid::text AS query_id, -- Unique identifier for the query
+		 pgsm.datname AS database_name, -- Name of the database
+		 resp.bin - 1 AS bin, -- Index of the histogram bin
+		 SUM(resp.calls::bigint) AS calls -- Number of calls with a response time within the bin
+		FROM
+		 pg_stat_monitor AS pgsm,
+		 unnest(pgsm.resp_calls) WITH ORDINALITY AS resp(calls, bin)
+		WHERE
+		 pgsm.queryid IN (%s) -- Query identifiers
+		 AND pgsm.datname IN (%s) -- List of database names
+		 AND pgsm.bucket_done -- Completed buckets only
+		 AND pgsm.bucket_start_time > to_timestamp(%d) -- Buckets after the oldest last ingested one of the queries
+		GROUP BY
+		 pgsm.bucket, pgsm.bucket_start_time, pgsm.queryid, pgsm.datname, resp.bin
+		ORDER BY
+		 bucket_start_time, query_id, database_name, bin;`
+
+	// QueryLatencyHistogramTimings retrieves the response time ranges of the pg_stat_monitor histogram bins
+	QueryLatencyHistogramTimings = `SELECT get_histogram_timings();`
+
+	// QueryErrors retrieves the failed statements per query, database and SQLSTATE recorded by pg_stat_monitor for the buckets completed since the last ingested one, limited per bucket
+	QueryErrors = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+		 bucket_start_time, query_id, query_text, database_name, sqlstate, message, error_count
+		FROM (
+		 SELECT
+		  EXTRACT(EPOCH FROM bucket_start_time)::bigint AS bucket_start_time, -- Start time of the bucket in seconds since the epoch
+		  queryid::text AS query_id, -- Unique identifier for the query
+		  LEFT(MIN(query), 4095) AS query_text, -- Query text truncated to 4095 characters
+		  datname AS database_name, -- Name of the database
+		  sqlcode AS sqlstate, -- SQLSTATE of the error
+		  LEFT(MIN(message), 4095) AS message, -- Sample error message truncated to 4095 characters
+		  SUM(calls) AS error_count, -- Number of failed executions
+		  ROW_NUMBER() OVER (PARTITION BY bucket_start_time ORDER BY SUM(calls) DESC) AS bucket_rank -- Rank by number of errors within the bucket
+		 FROM
+		  pg_stat_monitor
+		 WHERE
+		  elevel >= %d -- Statements that raised an ERROR or a more severe level
+		  AND sqlcode IS NOT NULL
+		  AND datname IN (%s) -- List of database names
+		  AND application_name <> ` + integrationApplicationName + ` -- Exclude the statements of the integration
+		  AND bucket_done -- Completed buckets only
+		  AND bucket_start_time > to_timestamp(%d) -- Buckets after the last ingested one
+		 GROUP BY
+		  bucket_start_time, queryid, datname, sqlcode
+		) AS bucket_errors
+		WHERE
+		 bucket_rank <= %d -- Limit the number of results of each bucket
+		ORDER BY
+		 bucket_start_time, error_count DESC; -- Order by bucket and number of errors in descending order`
+
+	// TempSpillByQuery retrieves the cumulative temporary file I/O of the queries that spilled to disk, per query and database
+	TempSpillByQuery = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+		pss.queryid::text AS query_id, -- Unique identifier for the query
+		LEFT(MIN(pss.query), 4095) AS query_text, -- Query text truncated to 4095 characters
+		pd.datname AS database_name, -- Name of the database
+		SUM(pss.calls) AS calls, -- Number of executions
+		SUM(pss.temp_blks_read) AS temp_blks_read, -- Temporary blocks read
+		SUM(pss.temp_blks_written) AS temp_blks_written -- Temporary blocks written
+	FROM
+		pg_stat_statements pss
+	JOIN
+		pg_database pd ON pss.dbid = pd.oid
+	WHERE
+		pd.datname IN (%s) -- List of database names
+		AND pss.temp_blks_written > 0 -- Queries that spilled to disk
+		AND pss.query NOT ILIKE 'EXPLAIN (%%FORMAT JSON) %%' -- Exclude EXPLAIN queries
+	GROUP BY
+		pss.queryid, pd.datname
+	ORDER BY
+		temp_blks_written DESC -- Order by temporary blocks written in descending order
+	LIMIT %d; -- Limit the number of results`
+
+	// TempSpillByDatabase retrieves the cumulative temporary files and bytes written per database
+	TempSpillByDatabase = `SELECT
+		datname AS database_name, -- Name of the database
+		temp_files, -- Number of temporary files created
+		temp_bytes -- Bytes written to temporary files
+	FROM
+		pg_stat_database
+	WHERE
+		datname IN (%s); -- List of database names`
+
+	// TempSpillSettings retrieves the work_mem setting in kilobytes and the block size in bytes. work_mem is the
+	// pg_settings value the inventory reports as work_mem/setting, read again because the inventory is published
+	// before query monitoring runs, on its own interval in daemon mode, and not at all when it is disabled
+	TempSpillSettings = `SELECT
+		(SELECT setting::bigint FROM pg_settings WHERE name = 'work_mem') AS work_mem_kb, -- Memory available to each sort or hash operation in kilobytes
+		current_setting('block_size')::bigint AS block_size; -- Size of a block in bytes`
+
+	// LongRunningSessions retrieves client sessions whose current statement or open transaction exceeds a running time threshold
+	LongRunningSessions = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+		 pid, -- Process ID of the backend
+		 usename AS user_name, -- Name of the user
+		 application_name, -- Name of the application connected to the backend
+		 host(client_addr) AS client_address, -- Address of the client
+		 datname AS database_name, -- Name of the database
+		 state, -- State of the backend
+		 wait_event_type, -- Type of the wait event the backend is waiting on
+		 wait_event, -- Wait event the backend is waiting on
+		 age(backend_xmin) AS backend_xmin_age, -- Age in transactions of the xmin horizon held by the backend
+		 ROUND((EXTRACT(EPOCH FROM (clock_timestamp() - query_start)) * 1000)::numeric, 3) AS query_duration_ms, -- Running time of the current or last statement in milliseconds
+		 ROUND((EXTRACT(EPOCH FROM (clock_timestamp() - xact_start)) * 1000)::numeric, 3) AS transaction_duration_ms, -- Running time of the open transaction in milliseconds
+		 LEFT(query, 4095) AS query_text -- Query text truncated to 4095 characters
+		FROM
+		 pg_stat_activity
+		WHERE
+		 datname IN (%s) -- List of database names
+		 AND backend_type = 'client backend' -- Client sessions only
+		 AND state <> 'idle' -- Exclude idle sessions
+		 AND application_name <> ` + integrationApplicationName + ` -- Exclude the sessions of the integration
+		 AND (clock_timestamp() - query_start > make_interval(secs => %d) -- Statements running longer than the threshold
+		   OR clock_timestamp() - xact_start > make_interval(secs => %[2]d)) -- Transactions open longer than the threshold
+		ORDER BY
+		 LEAST(xact_start, query_start) ASC -- Order by the oldest transaction or statement
+		LIMIT %d; -- Limit the number of results`
+
+	// QueryLoadByUserForV13AndAbove retrieves the cumulative statement statistics per role and database for PostgreSQL version 13 and above
+	QueryLoadByUserForV13AndAbove = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+		pg_get_userbyid(pss.userid) AS user_name, -- Name of the user who executed the queries
+		pd.datname AS database_name, -- Name of the database
+		SUM(pss.calls) AS calls, -- Number of executions
+		SUM(pss.total_exec_time) AS total_exec_time_ms, -- Total execution time in milliseconds
+		SUM(pss.shared_blks_hit) AS shared_blks_hit, -- Shared blocks found in the buffer cache
+		SUM(pss.shared_blks_read) AS shared_blks_read, -- Shared blocks read from disk
+		SUM(pss.shared_blks_written) AS shared_blks_written, -- Shared blocks written
+		SUM(pss.temp_blks_read) AS temp_blks_read, -- Temporary blocks read
+		SUM(pss.temp_blks_written) AS temp_blks_written -- Temporary blocks written
+	FROM
+		pg_stat_statements pss
+	JOIN
+		pg_database pd ON pss.dbid = pd.oid
+	WHERE
+		pd.datname IN (%s) -- List of database names
+	GROUP BY
+		pss.userid, pd.datname;`
+
+	// QueryLoadByUserForV10ToV12 retrieves the cumulative statement statistics per role and database for PostgreSQL versions 10 to 12
+	QueryLoadByUserForV10ToV12 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+		pg_get_userbyid(pss.userid) AS user_name, -- Name of the user who executed the queries
+		pd.datname AS database_name, -- Name of the database
+		SUM(pss.calls) AS calls, -- Number of executions
+		SUM(pss.total_time) AS total_exec_time_ms, -- Total execution time in milliseconds
+		SUM(pss.shared_blks_hit) AS shared_blks_hit, -- Shared blocks found in the buffer cache
+		SUM(pss.shared_blks_read) AS shared_blks_read, -- Shared blocks read from disk
+		SUM(pss.shared_blks_written) AS shared_blks_written, -- Shared blocks written
+		SUM(pss.temp_blks_read) AS temp_blks_read, -- Temporary blocks read
+		SUM(pss.temp_blks_written) AS temp_blks_written -- Temporary blocks written
+	FROM
+		pg_stat_statements pss
+	JOIN
+		pg_database pd ON pss.dbid = pd.oid
+	WHERE
+		pd.datname IN (%s) -- List of database names
+	GROUP BY
+		pss.userid, pd.datname;`
+
+	// QueryLoadApplicationSamples counts the active sessions running tracked statements per role, database and application for PostgreSQL version 14 and above
+	QueryLoadApplicationSamples = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+		sa.usename AS user_name, -- Name of the user
+		sa.datname AS database_name, -- Name of the database
+		sa.application_name, -- Name of the application connected to the backend
+		COUNT(*) AS active_sessions -- Number of active sessions
+	FROM
+		pg_stat_activity sa
+	WHERE
+		sa.datname IN (%s) -- List of database names
+		AND sa.state = 'active' -- Running statements only
+		AND sa.query_id IS NOT NULL -- Statements tracked by query identifier
+		AND sa.application_name <> ` + integrationApplicationName + ` -- Exclude the sessions of the integration
+	GROUP BY
+		sa.usename, sa.datname, sa.application_name;`
 )
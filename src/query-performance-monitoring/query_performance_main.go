@@ -6,78 +6,185 @@ import (
 
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
 	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/capabilities"
 	"github.com/newrelic/nri-postgresql/src/collection"
 	connpkg "github.com/newrelic/nri-postgresql/src/connection"
 	"github.com/newrelic/nri-postgresql/src/metrics"
+	"github.com/newrelic/nri-postgresql/src/scheduler"
 
 	commonparams "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 	performancemetrics "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/performance-metrics"
-	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/selfmetrics"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
+	"github.com/newrelic/nri-postgresql/src/selfmetrics"
 )
 
-func QueryPerformanceMain(a args.ArgumentList, pgInt *integration.Integration, dbMap collection.DatabaseList) {
+func QueryPerformanceMain(ctx context.Context, a args.ArgumentList, pgInt *integration.Integration, dbMap collection.DatabaseList, connInfo connpkg.Info) {
+	qp := NewQueryPerformance(ctx, a, pgInt, dbMap, connInfo)
+	if qp == nil {
+		return
+	}
+	qp.Collect(ctx)
+}
+
+// QueryPerformance holds what query monitoring discovers about the server, so a long-running process can collect
+// repeatedly without detecting the version and extensions again
+type QueryPerformance struct {
+	pgInt     *integration.Integration
+	connInfo  connpkg.Info
+	cp        *commonparams.CommonParameters
+	exts      map[string]bool
+	planStore persist.Storer
+}
+
+// NewQueryPerformance detects the server version and enabled extensions. It returns nil when query monitoring is
+// disabled or not supported by the server.
+func NewQueryPerformance(ctx context.Context, a args.ArgumentList, pgInt *integration.Integration, dbMap collection.DatabaseList, connInfo connpkg.Info) *QueryPerformance {
 	if !a.EnableQueryMonitoring {
 		log.Debug("query monitoring disabled by flag")
-		return
+		return nil
 	}
 	if len(dbMap) == 0 {
 		log.Debug("no databases found")
-		return
+		return nil
 	}
 
-	connInfo := connpkg.DefaultConnectionInfo(&a)
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	db, err := connInfo.NewConnection(connInfo.DatabaseName())
 	if err != nil {
 		log.Error("connection error: %v", err)
-		return
+		return nil
 	}
 	defer db.Close()
 
 	ver, err := metrics.CollectVersion(ctx, db)
 	if err != nil {
 		log.Error("version detect: %v", err)
-		return
+		return nil
 	}
 	if !validations.CheckPostgresVersionSupportForQueryMonitoring(ver.Major) {
 		log.Debug("Postgres %d not supported", ver.Major)
-		return
+		return nil
+	}
+
+	exts, err := validations.FetchAllExtensions(db)
+	if err != nil {
+		log.Error("extension scan: %v", err)
+		return nil
+	}
+
+	planStore, err := persist.NewFileStore(persist.DefaultPath(commonutils.PlanHistoryStoreName), log.NewStdErr(a.Verbose), commonutils.PlanHistoryTTL)
+	if err != nil {
+		log.Warn("plan history store: %v, plan changes will not be detected across runs", err)
+		planStore = persist.NewInMemoryStore()
 	}
 
 	cp := commonparams.SetCommonParameters(a, ver.Major, commonutils.GetDatabaseListInString(dbMap))
-	populateQueryPerformance(ctx, db, pgInt, cp, connInfo)
+	performancemetrics.SetExplainRate(cp.ExplainRateLimit)
+	return &QueryPerformance{pgInt: pgInt, connInfo: connInfo, cp: cp, exts: exts, planStore: planStore}
 }
 
-func populateQueryPerformance(ctx context.Context, db *connpkg.PGSQLConnection, pgInt *integration.Integration, cp *commonparams.CommonParameters, info connpkg.Info) {
-	exts, err := validations.FetchAllExtensions(db)
+// Collect runs every query monitoring collector once, for at most 30 seconds or the time one plan may take to be
+// captured when longer
+func (qp *QueryPerformance) Collect(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, max(30*time.Second, performancemetrics.ExplainTimeout(qp.cp)))
+	defer cancel()
+
+	db, err := qp.connInfo.NewConnection(qp.connInfo.DatabaseName())
 	if err != nil {
-		log.Error("extension scan: %v", err)
+		log.Error("connection error: %v", err)
 		return
 	}
+	defer db.Close()
 
-	start := time.Now()
-	slow := performancemetrics.PopulateSlowRunningMetrics(db, pgInt, cp, exts)
-	selfmetrics.IncQueries()
-	log.Debug("slow-running metrics in", time.Since(start))
+	populateQueryPerformance(ctx, db, qp.pgInt, qp.cp, qp.connInfo, qp.planStore, qp.exts)
+}
 
-	start = time.Now()
-	_ = performancemetrics.PopulateWaitEventMetrics(ctx, db, pgInt, cp, exts)
-	log.Debug("wait-event metrics in", time.Since(start))
+func populateQueryPerformance(ctx context.Context, db *connpkg.PGSQLConnection, pgInt *integration.Integration, cp *commonparams.CommonParameters, info connpkg.Info, planStore persist.Storer, exts map[string]bool) {
+	// Collectors run in parallel, except the ones that build on the slow queries
+	collectorScheduler := scheduler.New(cp.Concurrency)
+	collectorScheduler.Go(func() {
+		var slow []datamodels.SlowRunningQueryMetrics
+		timed(ctx, db, "PostgresSlowQueries", func() {
+			slow = performancemetrics.PopulateSlowRunningMetrics(db, pgInt, cp, exts)
+			selfmetrics.IncQueries()
+		})
+		collectorScheduler.Go(func() {
+			timed(ctx, db, "PostgresQueryLatencyHistogram", func() {
+				performancemetrics.PopulateQueryLatencyHistogramMetrics(ctx, db, slow, pgInt, cp, exts, planStore)
+			})
+		})
 
-	start = time.Now()
-	performancemetrics.PopulateBlockingMetrics(ctx, db, pgInt, cp, exts)
-	log.Debug("blocking metrics in", time.Since(start))
+		var iq []datamodels.IndividualQueryMetrics
+		timed(ctx, db, "PostgresIndividualQueries", func() {
+			iq = performancemetrics.PopulateIndividualQueryMetrics(db, slow, cp, exts)
+		})
+		timed(ctx, db, "PostgresExecutionPlanMetrics", func() {
+			performancemetrics.PopulateExecutionPlanMetrics(ctx, iq, pgInt, cp, info, planStore, exts)
+		})
+		// the individual queries are ingested after their plans are hashed, to carry the plan_id of the plan events
+		performancemetrics.IngestIndividualQueryMetrics(iq, pgInt, cp)
+	})
+	collectorScheduler.Go(func() {
+		timed(ctx, db, "PostgresQueryLoadByUser", func() {
+			performancemetrics.PopulateQueryLoadByUserMetrics(ctx, db, pgInt, cp, exts, planStore)
+		})
+	})
+	collectorScheduler.Go(func() {
+		timed(ctx, db, "PostgresTempSpill", func() {
+			performancemetrics.PopulateTempSpillMetrics(ctx, db, pgInt, cp, exts, planStore)
+		})
+	})
+	collectorScheduler.Go(func() {
+		timed(ctx, db, "PostgresWaitEvents", func() {
+			_ = performancemetrics.PopulateWaitEventMetrics(ctx, db, pgInt, cp, exts)
+		})
+	})
+	collectorScheduler.Go(func() {
+		timed(ctx, db, "PostgresBlockingSessions", func() {
+			performancemetrics.PopulateBlockingMetrics(ctx, db, pgInt, cp, exts)
+		})
+	})
+	collectorScheduler.Go(func() {
+		timed(ctx, db, "PostgresLongRunningSession", func() {
+			performancemetrics.PopulateLongRunningSessionMetrics(ctx, db, pgInt, cp)
+		})
+	})
+	collectorScheduler.Go(func() {
+		timed(ctx, db, "PostgresQueryErrors", func() {
+			performancemetrics.PopulateQueryErrorMetrics(ctx, db, pgInt, cp, exts, planStore)
+		})
+	})
+	collectorScheduler.Wait()
+}
 
-	start = time.Now()
-	iq := performancemetrics.PopulateIndividualQueryMetrics(db, slow, pgInt, cp, exts)
-	log.Debug("individual-query metrics in", time.Since(start))
+// collectorCapabilities are the catalog capabilities the collector of each event type requires. Forks and managed
+// services may report a supported version but lack or rename them.
+var collectorCapabilities = map[string][]capabilities.Capability{
+	"PostgresSlowQueries":           {capabilities.Relation("pg_stat_statements")},
+	"PostgresQueryLatencyHistogram": {capabilities.Column("pg_stat_monitor", "resp_calls"), capabilities.Function("get_histogram_timings")},
+	"PostgresQueryLoadByUser":       {capabilities.Column("pg_stat_statements", "temp_blks_written")},
+	"PostgresTempSpill":             {capabilities.Column("pg_stat_statements", "temp_blks_written")},
+	"PostgresWaitEvents":            {capabilities.Relation("pg_wait_sampling_history")},
+	"PostgresBlockingSessions":      {capabilities.Relation("pg_locks")},
+	"PostgresLongRunningSession":    {capabilities.Column("pg_stat_activity", "backend_type")},
+}
 
-	start = time.Now()
-	performancemetrics.PopulateExecutionPlanMetrics(ctx, iq, pgInt, cp, info)
-	log.Debug("execution-plan metrics in", time.Since(start))
+// timed runs collect, logs how long it took and records it in the self-metrics of the event type it collects.
+// collect is skipped when db lacks a capability its collector requires.
+func timed(ctx context.Context, db *connpkg.PGSQLConnection, eventType string, collect func()) {
+	if capability, missing := capabilities.Missing(ctx, db, collectorCapabilities[eventType]); missing {
+		log.Debug("Skipping %s metrics: %s is missing", eventType, capability)
+		return
+	}
+	start := time.Now()
+	collect()
+	duration := time.Since(start)
+	selfmetrics.Observe(eventType, duration)
+	log.Debug("%s metrics in %s", eventType, duration)
 }
@@ -0,0 +1,52 @@
+package queryperformancemonitoring
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	commonparams "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestPopulateQueryPerformanceErrorLogFallback(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "postgresql.log")
+	require.NoError(t, os.WriteFile(logPath, []byte("db=testdb ERROR:  42601: syntax error at or near \"SELEC\"\n"), 0o600))
+
+	conn, mock := connection.CreateMockSQL(t)
+	// the other collectors fail their capability probes and are skipped
+	mock.MatchExpectationsInOrder(false)
+	for range 2 {
+		mock.ExpectQuery(".*CAPABILITY_COLUMN.*").WithArgs("pg_stat_monitor", "sqlcode").
+			WillReturnRows(sqlmock.NewRows([]string{"found"}).AddRow(0))
+	}
+
+	var output bytes.Buffer
+	pgIntegration, err := integration.New("test", "1.0.0", integration.Writer(&output))
+	require.NoError(t, err)
+	cp := commonparams.SetCommonParameters(args.ArgumentList{QueryMonitoringCountThreshold: 10, QueryMonitoringErrorLogPath: logPath}, uint64(16), "'testdb'")
+	exts := map[string]bool{"pg_stat_monitor": true}
+	store := persist.NewInMemoryStore()
+
+	populateQueryPerformance(context.Background(), conn, pgIntegration, cp, &connection.MockInfo{}, store, exts)
+	assert.NotContains(t, output.String(), "PostgresQueryErrors", "errors logged before the first run are not reported")
+
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = logFile.WriteString("db=testdb ERROR:  42601: syntax error at or near \"SELEC\"\n")
+	require.NoError(t, err)
+	require.NoError(t, logFile.Close())
+
+	populateQueryPerformance(context.Background(), conn, pgIntegration, cp, &connection.MockInfo{}, store, exts)
+	assert.Contains(t, output.String(), `"event_type":"PostgresQueryErrors"`)
+	assert.Contains(t, output.String(), `"source":"log"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
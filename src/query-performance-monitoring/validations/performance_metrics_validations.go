@@ -34,8 +34,8 @@ func CheckWaitEventMetricsFetchEligibility(enabledExtensions map[string]bool) (b
 }
 
 func CheckBlockingSessionMetricsFetchEligibility(enabledExtensions map[string]bool, version uint64) (bool, error) {
-	// Version 12 and 13 do not require the pg_stat_statements extension
-	if version == commonutils.PostgresVersion12 || version == commonutils.PostgresVersion13 {
+	// Versions 10 to 13 do not require the pg_stat_statements extension
+	if commonutils.ActivityBlockingVersions.ContainsMajor(version) {
 		return true, nil
 	}
 	return enabledExtensions["pg_stat_statements"], nil
@@ -45,6 +45,16 @@ func CheckIndividualQueryMetricsFetchEligibility(enabledExtensions map[string]bo
 	return enabledExtensions["pg_stat_monitor"], nil
 }
 
+// CheckActivityIndividualQueryMetricsFetchEligibility reports whether individual queries can be sampled from
+// pg_stat_activity, which has a query_id from version 14
+func CheckActivityIndividualQueryMetricsFetchEligibility(version uint64) (bool, error) {
+	return commonutils.ActivityQueryIDVersions.ContainsMajor(version), nil
+}
+
+func CheckHypotheticalIndexFetchEligibility(enabledExtensions map[string]bool) (bool, error) {
+	return enabledExtensions["hypopg"], nil
+}
+
 func CheckPostgresVersionSupportForQueryMonitoring(version uint64) bool {
-	return version >= commonutils.PostgresVersion12
+	return commonutils.QueryMonitoringVersions.ContainsMajor(version)
 }
@@ -101,3 +101,34 @@ func TestCheckSlowQueryMetricsFetchEligibilitySupportedVersionFail(t *testing.T)
 	assert.Equal(t, isExtensionEnabledTest, false)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestCheckHypotheticalIndexFetchEligibility(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	validationQuery := "SELECT extname FROM pg_extension"
+	mock.ExpectQuery(regexp.QuoteMeta(validationQuery)).WillReturnRows(sqlmock.NewRows([]string{"extname"}).AddRow("pg_stat_statements").AddRow("hypopg"))
+	enabledExtensions, _ := FetchAllExtensions(conn)
+	isExtensionEnabledTest, _ := CheckHypotheticalIndexFetchEligibility(enabledExtensions)
+	assert.Equal(t, isExtensionEnabledTest, true)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckBlockingSessionMetricsFetchEligibilityV10AndV11(t *testing.T) {
+	for _, version := range []uint64{10, 11} {
+		isExtensionEnabledTest, _ := CheckBlockingSessionMetricsFetchEligibility(map[string]bool{}, version)
+		assert.Equal(t, isExtensionEnabledTest, true)
+	}
+}
+
+func TestCheckPostgresVersionSupportForQueryMonitoring(t *testing.T) {
+	assert.False(t, CheckPostgresVersionSupportForQueryMonitoring(9))
+	assert.True(t, CheckPostgresVersionSupportForQueryMonitoring(10))
+	assert.True(t, CheckPostgresVersionSupportForQueryMonitoring(11))
+	assert.True(t, CheckPostgresVersionSupportForQueryMonitoring(16))
+}
+
+func TestCheckActivityIndividualQueryMetricsFetchEligibility(t *testing.T) {
+	isEligible, _ := CheckActivityIndividualQueryMetricsFetchEligibility(13)
+	assert.False(t, isEligible)
+	isEligible, _ = CheckActivityIndividualQueryMetricsFetchEligibility(14)
+	assert.True(t, isEligible)
+}
@@ -0,0 +1,35 @@
+// Package scheduler runs independent collection tasks in parallel with a bounded number of workers
+package scheduler
+
+import "sync"
+
+// Scheduler runs the tasks it is given on at most a fixed number of goroutines at a time
+type Scheduler struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// New returns a Scheduler running up to concurrency tasks at a time. A concurrency below 1 runs one task at a time.
+func New(concurrency int) *Scheduler {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Scheduler{slots: make(chan struct{}, concurrency)}
+}
+
+// Go schedules task to run as soon as a worker is free. Tasks may schedule further tasks, which start once
+// their parent or another task has returned.
+func (s *Scheduler) Go(task func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.slots <- struct{}{}
+		defer func() { <-s.slots }()
+		task()
+	}()
+}
+
+// Wait blocks until every scheduled task, including the ones scheduled by other tasks, has returned
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
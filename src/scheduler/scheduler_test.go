@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_BoundsConcurrency(t *testing.T) {
+	s := New(2)
+	var running, maxRunning int32
+	var mu sync.Mutex
+	for i := 0; i < 10; i++ {
+		s.Go(func() {
+			current := atomic.AddInt32(&running, 1)
+			mu.Lock()
+			maxRunning = max(maxRunning, current)
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		})
+	}
+	s.Wait()
+
+	assert.Equal(t, int32(2), maxRunning)
+}
+
+func TestScheduler_WaitsForNestedTasks(t *testing.T) {
+	s := New(1)
+	var completed int32
+	s.Go(func() {
+		atomic.AddInt32(&completed, 1)
+		s.Go(func() {
+			atomic.AddInt32(&completed, 1)
+		})
+	})
+	s.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&completed))
+}
+
+func TestNew_InvalidConcurrency(t *testing.T) {
+	assert.Equal(t, 1, cap(New(0).slots))
+}
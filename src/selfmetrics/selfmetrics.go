@@ -0,0 +1,210 @@
+// Package selfmetrics instruments the collectors of the integration and reports them as PostgresIntegrationSample
+package selfmetrics
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/newrelic/infra-integrations-sdk/v3/data/attribute"
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+)
+
+const (
+	sampleName = "PostgresIntegrationSample"
+	// runCollector is the collector attribute of the sample summarizing the whole run
+	runCollector = "run"
+	// queryCanceledCode is the SQLSTATE of statements cancelled by statement_timeout
+	queryCanceledCode = "57014"
+)
+
+// collectorStats accumulates what a collector did during a run. A collector is a metrics definition, such as
+// table or bloat, a query monitoring event type, or inventory.
+type collectorStats struct {
+	duration time.Duration
+	rows     int
+	errors   int
+	timeouts int
+}
+
+// Stats holds the statistics of the collectors of one run. The package functions record into a default Stats that
+// the daemon and the one-shot run report, while callers that must not mix their statistics with it, such as the
+// exporter, carry their own in the context.
+type Stats struct {
+	mu         sync.Mutex
+	collectors map[string]*collectorStats
+
+	queriesScanned uint64
+	execPlans      uint64
+}
+
+// NewStats returns an empty Stats
+func NewStats() *Stats {
+	return &Stats{collectors: map[string]*collectorStats{}}
+}
+
+var defaultStats = NewStats()
+
+type statsKey struct{}
+
+// WithStats returns a copy of ctx whose collectors record into stats
+func WithStats(ctx context.Context, stats *Stats) context.Context {
+	return context.WithValue(ctx, statsKey{}, stats)
+}
+
+// FromContext returns the Stats carried by ctx, or the default one
+func FromContext(ctx context.Context) *Stats {
+	if stats, ok := ctx.Value(statsKey{}).(*Stats); ok {
+		return stats
+	}
+	return defaultStats
+}
+
+// IncQueries counts a query monitoring query
+func IncQueries() { defaultStats.IncQueries() }
+
+// IncPlans counts an execution plan fetched from the server
+func IncPlans() { defaultStats.IncPlans() }
+
+// Observe adds the time spent by collector
+func Observe(collector string, duration time.Duration) { defaultStats.Observe(collector, duration) }
+
+// AddRows adds the rows read by collector
+func AddRows(collector string, rows int) { defaultStats.AddRows(collector, rows) }
+
+// RecordError counts a failure of collector, as a timeout when err is a cancelled query
+func RecordError(collector string, err error) { defaultStats.RecordError(collector, err) }
+
+// RecordTimeout counts a query of collector that did not finish in time
+func RecordTimeout(collector string) { defaultStats.RecordTimeout(collector) }
+
+// Reset clears the statistics, so the next run is reported on its own
+func Reset() { defaultStats.Reset() }
+
+// Populate reports the default statistics to entity, see Stats.Populate
+func Populate(entity *integration.Entity, runDuration time.Duration, connections int) error {
+	return defaultStats.Populate(entity, runDuration, connections)
+}
+
+// IncQueries counts a query monitoring query
+func (s *Stats) IncQueries() { atomic.AddUint64(&s.queriesScanned, 1) }
+
+// IncPlans counts an execution plan fetched from the server
+func (s *Stats) IncPlans() { atomic.AddUint64(&s.execPlans, 1) }
+
+// Observe adds the time spent by collector
+func (s *Stats) Observe(collector string, duration time.Duration) {
+	s.update(collector, func(c *collectorStats) { c.duration += duration })
+}
+
+// AddRows adds the rows read by collector
+func (s *Stats) AddRows(collector string, rows int) {
+	s.update(collector, func(c *collectorStats) { c.rows += rows })
+}
+
+// RecordError counts a failure of collector, as a timeout when err is a cancelled query
+func (s *Stats) RecordError(collector string, err error) {
+	if isTimeout(err) {
+		s.RecordTimeout(collector)
+		return
+	}
+	s.update(collector, func(c *collectorStats) { c.errors++ })
+}
+
+// RecordTimeout counts a query of collector that did not finish in time
+func (s *Stats) RecordTimeout(collector string) {
+	s.update(collector, func(c *collectorStats) { c.timeouts++ })
+}
+
+// Reset clears the statistics, so the next run is reported on its own
+func (s *Stats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collectors = map[string]*collectorStats{}
+	atomic.StoreUint64(&s.queriesScanned, 0)
+	atomic.StoreUint64(&s.execPlans, 0)
+}
+
+// Totals returns the rows, errors and timeouts of every collector
+func (s *Stats) Totals() (rows, errs, timeouts int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stats := range s.collectors {
+		rows += stats.rows
+		errs += stats.errors
+		timeouts += stats.timeouts
+	}
+	return rows, errs, timeouts
+}
+
+// Populate adds one PostgresIntegrationSample per collector to entity, and a run sample with the duration of the
+// run, the open connections and the totals of every collector
+func (s *Stats) Populate(entity *integration.Entity, runDuration time.Duration, connections int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.collectors))
+	for name := range s.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var total collectorStats
+	for _, name := range names {
+		stats := s.collectors[name]
+		total.rows += stats.rows
+		total.errors += stats.errors
+		total.timeouts += stats.timeouts
+		if err := setMetrics(entity.NewMetricSet(sampleName, attribute.Attribute{Key: "collector", Value: name}), map[string]interface{}{
+			"collector.durationMs": stats.duration.Milliseconds(),
+			"collector.rows":       stats.rows,
+			"collector.errors":     stats.errors,
+			"collector.timeouts":   stats.timeouts,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return setMetrics(entity.NewMetricSet(sampleName, attribute.Attribute{Key: "collector", Value: runCollector}), map[string]interface{}{
+		"run.durationMs":     runDuration.Milliseconds(),
+		"run.connections":    connections,
+		"run.rows":           total.rows,
+		"run.errors":         total.errors,
+		"run.timeouts":       total.timeouts,
+		"run.queriesScanned": atomic.LoadUint64(&s.queriesScanned),
+		"run.executionPlans": atomic.LoadUint64(&s.execPlans),
+	})
+}
+
+func setMetrics(ms *metric.Set, values map[string]interface{}) error {
+	for name, value := range values {
+		if err := ms.SetMetric(name, value, metric.GAUGE); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Stats) update(collector string, apply func(*collectorStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.collectors[collector]
+	if !ok {
+		stats = &collectorStats{}
+		s.collectors[collector] = stats
+	}
+	apply(stats)
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == queryCanceledCode
+}
@@ -0,0 +1,91 @@
+package selfmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPopulate(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Observe("table", 40*time.Millisecond)
+	Observe("table", 2*time.Millisecond)
+	AddRows("table", 12)
+	RecordError("table", errors.New("relation does not exist"))
+	RecordError("bloat", fmt.Errorf("bloat query: %w", context.DeadlineExceeded))
+	RecordError("PostgresSlowQueries", &pq.Error{Code: "57014"})
+	IncQueries()
+	IncPlans()
+
+	pgIntegration, err := integration.New("test", "test", integration.InMemoryStore())
+	require.NoError(t, err)
+	instance, err := pgIntegration.Entity("localhost:5432", "pg-instance")
+	require.NoError(t, err)
+
+	require.NoError(t, Populate(instance, 3*time.Second, 4))
+
+	samples := map[string]map[string]interface{}{}
+	for _, ms := range instance.Metrics {
+		samples[ms.Metrics["collector"].(string)] = ms.Metrics
+	}
+	require.Len(t, samples, 4)
+	assert.Equal(t, "PostgresIntegrationSample", samples["table"]["event_type"])
+	assert.Equal(t, float64(42), samples["table"]["collector.durationMs"])
+	assert.Equal(t, float64(12), samples["table"]["collector.rows"])
+	assert.Equal(t, float64(1), samples["table"]["collector.errors"])
+	assert.Equal(t, float64(1), samples["bloat"]["collector.timeouts"])
+	assert.Equal(t, float64(1), samples["PostgresSlowQueries"]["collector.timeouts"])
+
+	run := samples["run"]
+	assert.Equal(t, float64(3000), run["run.durationMs"])
+	assert.Equal(t, float64(4), run["run.connections"])
+	assert.Equal(t, float64(12), run["run.rows"])
+	assert.Equal(t, float64(1), run["run.errors"])
+	assert.Equal(t, float64(2), run["run.timeouts"])
+	assert.Equal(t, float64(1), run["run.queriesScanned"])
+	assert.Equal(t, float64(1), run["run.executionPlans"])
+}
+
+func TestReset(t *testing.T) {
+	AddRows("table", 1)
+	IncPlans()
+	Reset()
+
+	pgIntegration, err := integration.New("test", "test", integration.InMemoryStore())
+	require.NoError(t, err)
+	instance, err := pgIntegration.Entity("localhost:5432", "pg-instance")
+	require.NoError(t, err)
+
+	require.NoError(t, Populate(instance, time.Second, 0))
+	require.Len(t, instance.Metrics, 1, "only the run sample is left")
+	assert.Equal(t, float64(0), instance.Metrics[0].Metrics["run.executionPlans"])
+}
+
+func TestWithStats(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	stats := NewStats()
+	ctx := WithStats(context.Background(), stats)
+	FromContext(ctx).AddRows("table", 3)
+	FromContext(ctx).RecordTimeout("table")
+	FromContext(context.Background()).AddRows("table", 1)
+
+	rows, errs, timeouts := stats.Totals()
+	assert.Equal(t, 3, rows)
+	assert.Equal(t, 0, errs)
+	assert.Equal(t, 1, timeouts)
+
+	rows, _, timeouts = defaultStats.Totals()
+	assert.Equal(t, 1, rows, "the default statistics only see what was recorded without a Stats in the context")
+	assert.Equal(t, 0, timeouts)
+}
@@ -0,0 +1,140 @@
+// Package versions selects the queries and definitions that apply to a PostgreSQL server, by inclusive version
+// ranges and the capabilities of its catalog
+package versions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/nri-postgresql/src/capabilities"
+)
+
+// Range is an inclusive range of server versions. A bound only compares the parts it has, so a Max of "16" includes
+// every 16.x release. An empty bound leaves the range open on that side.
+type Range struct {
+	Min string `yaml:"min_version"`
+	Max string `yaml:"max_version"`
+}
+
+// Validate checks the bounds of the range parse and are in order
+func (r Range) Validate() error {
+	lower, err := parseBound(r.Min)
+	if err != nil {
+		return fmt.Errorf("min_version: %w", err)
+	}
+	upper, err := parseBound(r.Max)
+	if err != nil {
+		return fmt.Errorf("max_version: %w", err)
+	}
+	if lower != nil && upper != nil && compare(lower, upper) > 0 {
+		return fmt.Errorf("min_version %s is greater than max_version %s", r.Min, r.Max)
+	}
+	return nil
+}
+
+// Contains returns whether version is within the range. A nil version, such as the one of PgBouncer, is within any
+// range. Invalid bounds contain no version.
+func (r Range) Contains(version *semver.Version) bool {
+	if version == nil {
+		return true
+	}
+	lower, err := parseBound(r.Min)
+	if err != nil {
+		return false
+	}
+	upper, err := parseBound(r.Max)
+	if err != nil {
+		return false
+	}
+	parts := []uint64{version.Major, version.Minor, version.Patch}
+	if lower != nil && compare(parts[:len(lower)], lower) < 0 {
+		return false
+	}
+	if upper != nil && compare(parts[:len(upper)], upper) > 0 {
+		return false
+	}
+	return true
+}
+
+// ContainsMajor returns whether the first release of the major version is within the range
+func (r Range) ContainsMajor(major uint64) bool {
+	return r.Contains(Major(major))
+}
+
+// Major returns the first release of the major version, for callers that only know the major version
+func Major(major uint64) *semver.Version {
+	return &semver.Version{Major: major}
+}
+
+// Variant is a value that applies to the servers within Range that have every capability of Requires
+type Variant[T any] struct {
+	Range    Range
+	Requires []capabilities.Capability
+	Value    T
+}
+
+// Matches returns whether the variant applies to a server of version, probed with prober
+func (v Variant[T]) Matches(ctx context.Context, version *semver.Version, prober capabilities.Prober) bool {
+	if !v.Range.Contains(version) {
+		return false
+	}
+	_, missing := capabilities.Missing(ctx, prober, v.Requires)
+	return !missing
+}
+
+// All returns the values of the variants that apply, in order
+func All[T any](ctx context.Context, variants []Variant[T], version *semver.Version, prober capabilities.Prober) []T {
+	var values []T
+	for _, variant := range variants {
+		if variant.Matches(ctx, version, prober) {
+			values = append(values, variant.Value)
+		}
+	}
+	return values
+}
+
+// First returns the value of the first variant that applies, false if none does
+func First[T any](ctx context.Context, variants []Variant[T], version *semver.Version, prober capabilities.Prober) (T, bool) {
+	for _, variant := range variants {
+		if variant.Matches(ctx, version, prober) {
+			return variant.Value, true
+		}
+	}
+	var none T
+	return none, false
+}
+
+func parseBound(bound string) ([]uint64, error) {
+	if bound == "" {
+		return nil, nil
+	}
+	fields := strings.Split(bound, ".")
+	if len(fields) > 3 {
+		return nil, fmt.Errorf("invalid version %q", bound)
+	}
+	parts := make([]uint64, len(fields))
+	for i, field := range fields {
+		part, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", bound)
+		}
+		parts[i] = part
+	}
+	return parts, nil
+}
+
+// compare compares a and b part by part, as far as the shortest of them goes
+func compare(a, b []uint64) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package versions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/nri-postgresql/src/capabilities"
+	"github.com/stretchr/testify/assert"
+)
+
+type testProber map[string]bool
+
+func (p testProber) Has(_ context.Context, c capabilities.Capability) bool {
+	return p[c.String()]
+}
+
+func TestRange_Contains(t *testing.T) {
+	r := Range{Min: "9.2", Max: "16"}
+	for version, expected := range map[string]bool{
+		"9.1.24": false,
+		"9.2.0":  true,
+		"12.3.0": true,
+		"16.9.1": true,
+		"17.0.0": false,
+	} {
+		v := semver.MustParse(version)
+		assert.Equal(t, expected, r.Contains(&v), version)
+	}
+	assert.True(t, r.Contains(nil))
+	assert.True(t, Range{}.ContainsMajor(18))
+	assert.False(t, Range{Min: "x"}.ContainsMajor(18))
+}
+
+func TestRange_Validate(t *testing.T) {
+	assert.NoError(t, Range{}.Validate())
+	assert.NoError(t, Range{Min: "9.6", Max: "9"}.Validate())
+	assert.Error(t, Range{Min: "12", Max: "9.6"}.Validate())
+	assert.Error(t, Range{Min: "9.x"}.Validate())
+	assert.Error(t, Range{Max: "1.2.3.4"}.Validate())
+}
+
+func TestSelect(t *testing.T) {
+	variants := []Variant[string]{
+		{Range: Range{Max: "12"}, Value: "old"},
+		{Range: Range{Min: "13"}, Value: "new"},
+		{Range: Range{Min: "13"}, Requires: []capabilities.Capability{capabilities.Column("pg_stat_io", "evictions")}, Value: "io"},
+	}
+	prober := testProber{"column pg_stat_io.evictions": true}
+
+	value, ok := First(context.Background(), variants, Major(12), prober)
+	assert.True(t, ok)
+	assert.Equal(t, "old", value)
+
+	assert.Equal(t, []string{"new", "io"}, All(context.Background(), variants, Major(18), prober))
+	assert.Equal(t, []string{"new"}, All(context.Background(), variants, Major(18), testProber{}))
+	assert.Equal(t, []string{"new"}, All(context.Background(), variants, Major(18), nil), "capabilities are missing without a prober")
+
+	_, ok = First(context.Background(), variants[1:], Major(9), prober)
+	assert.False(t, ok)
+}